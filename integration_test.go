@@ -0,0 +1,119 @@
+//go:build integration
+
+// Integration tests run runOnce against a real API server started by envtest.
+// They need the envtest control plane binaries (etcd, kube-apiserver):
+//
+//	go install sigs.k8s.io/controller-runtime/tools/setup-envtest@latest
+//	export KUBEBUILDER_ASSETS=$(setup-envtest use -p path 1.25.x)
+//	go test -tags integration -v .
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestRunOnceIntegration(t *testing.T) {
+	testEnv := &envtest.Environment{}
+	_, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+
+	// runOnce authenticates with a kubeconfig file, like pod-restarter running outside the cluster
+	user, err := testEnv.AddUser(envtest.User{Name: "pod-restarter", Groups: []string{"system:masters"}}, nil)
+	require.NoError(t, err)
+	kubeconfigData, err := user.KubeConfig()
+	require.NoError(t, err)
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfigPath, kubeconfigData, 0o600))
+
+	clientSet, err := kubernetes.NewForConfig(user.Config())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	testNamespace := "integration"
+	_, err = clientSet.CoreV1().Namespaces().Create(ctx, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Pods are never scheduled (there is no scheduler or kubelet), so they stay Pending
+	createPod := func(name string, hasOwner bool) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "nginx", Image: "nginx"}},
+			},
+		}
+		if hasOwner {
+			pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo-rs", UID: "foo-rs-uid"},
+			}
+		}
+		created, err := clientSet.CoreV1().Pods(testNamespace).Create(ctx, pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+		return created
+	}
+	createEvent := func(pod *v1.Pod, reason, message string) {
+		event := &v1.Event{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: pod.Name + ".", Namespace: testNamespace},
+			InvolvedObject: v1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: testNamespace,
+				Name:      pod.Name,
+				UID:       pod.UID,
+			},
+			Reason:         reason,
+			Message:        message,
+			Type:           v1.EventTypeWarning,
+			Source:         v1.EventSource{Component: "kubelet"},
+			FirstTimestamp: metav1.Now(),
+			LastTimestamp:  metav1.Now(),
+			Count:          1,
+		}
+		_, err := clientSet.CoreV1().Events(testNamespace).Create(ctx, event, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	matchedPod := createPod("matched", true)
+	createEvent(matchedPod, "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	orphanPod := createPod("orphan", false)
+	createEvent(orphanPod, "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	unmatchedPod := createPod("unmatched", true)
+	createEvent(unmatchedPod, "BackOff", "Back-off pulling image")
+
+	// the real API server supports the status.phase field selector, the fake clientset ignores it
+	pendingPods, err := clientSet.CoreV1().Pods(testNamespace).List(ctx, metav1.ListOptions{FieldSelector: "status.phase=Pending"})
+	require.NoError(t, err)
+	assert.Len(t, pendingPods.Items, 3)
+
+	kubeconfig = &kubeconfigPath
+	namespace = testNamespace
+	eventReason = "FailedCreatePodSandBox"
+	errorMessage = "container veth name provided (eth0) already exists"
+	deleteConcurrency = 1
+	healTime = 0
+	clientOptions = k8s.Options{UserAgent: "pod-restarter/integration"}
+
+	require.NoError(t, runOnce(ctx, 0))
+
+	_, err = clientSet.CoreV1().Pods(testNamespace).Get(ctx, matchedPod.Name, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "matched Pod should have been deleted, got: %v", err)
+	_, err = clientSet.CoreV1().Pods(testNamespace).Get(ctx, orphanPod.Name, metav1.GetOptions{})
+	assert.NoError(t, err, "orphan Pod should not have been deleted")
+	_, err = clientSet.CoreV1().Pods(testNamespace).Get(ctx, unmatchedPod.Name, metav1.GetOptions{})
+	assert.NoError(t, err, "unmatched Pod should not have been deleted")
+}
@@ -0,0 +1,127 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+)
+
+// apiCallCounter counts the kubernetes API calls made by a client by verb, eg: list, get, delete
+// the client is created every cycle, so the counts are the API calls made in a cycle
+type apiCallCounter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// countingTransport counts API calls before passing them to the wrapped transport
+type countingTransport struct {
+	counter *apiCallCounter
+	next    http.RoundTripper
+}
+
+// newAPICallCounter returns an API call counter, its wrap method wraps client-go transports
+func newAPICallCounter() *apiCallCounter {
+	return &apiCallCounter{calls: make(map[string]int)}
+}
+
+// wrap returns a transport that counts API calls before passing them to rt
+func (a *apiCallCounter) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &countingTransport{counter: a, next: rt}
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, resource := requestVerb(req)
+	t.counter.mu.Lock()
+	t.counter.calls[verb]++
+	t.counter.mu.Unlock()
+	metrics.APICall(verb, resource)
+	return t.next.RoundTrip(req)
+}
+
+// requestVerb returns the kubernetes API verb and resource of a request, eg: list and pods
+// the verb is the lower case HTTP method for requests that are not for API resources
+func requestVerb(req *http.Request) (string, string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return strings.ToLower(req.Method), ""
+	}
+	// namespaced resources, eg: namespaces/default/pods
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	resource, named := parts[0], len(parts) > 1
+
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("watch") == "true" {
+			return "watch", resource
+		} else if named {
+			return "get", resource
+		}
+		return "list", resource
+	case http.MethodPost:
+		return "create", resource
+	case http.MethodPut:
+		return "update", resource
+	case http.MethodPatch:
+		return "patch", resource
+	case http.MethodDelete:
+		if named {
+			return "delete", resource
+		}
+		return "deletecollection", resource
+	}
+	return strings.ToLower(req.Method), resource
+}
+
+// APICalls returns the kubernetes API calls made by the client by verb
+func (c *kubeClient) APICalls() map[string]int {
+	calls := make(map[string]int)
+	if c.apiCalls == nil {
+		return calls
+	}
+	c.apiCalls.mu.Lock()
+	defer c.apiCalls.mu.Unlock()
+	for verb, count := range c.apiCalls.calls {
+		calls[verb] = count
+	}
+	return calls
+}
+
+// FormatAPICalls returns API call counts as text, eg: 3 list, 5 get, 1 delete
+// list, get and delete come first, other verbs follow in alphabetical order
+func FormatAPICalls(calls map[string]int) string {
+	var verbs []string
+	for verb := range calls {
+		verbs = append(verbs, verb)
+	}
+	rank := map[string]int{"list": 1, "get": 2, "delete": 3}
+	sort.Slice(verbs, func(i, j int) bool {
+		ri, rj := rank[verbs[i]], rank[verbs[j]]
+		if ri == 0 {
+			ri = len(rank) + 1
+		}
+		if rj == 0 {
+			rj = len(rank) + 1
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return verbs[i] < verbs[j]
+	})
+
+	var counts []string
+	for _, verb := range verbs {
+		counts = append(counts, fmt.Sprintf("%d %s", calls[verb], verb))
+	}
+	return strings.Join(counts, ", ")
+}
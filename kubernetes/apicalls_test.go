@@ -0,0 +1,99 @@
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestVerb(t *testing.T) {
+	tests := map[string]struct {
+		method           string
+		url              string
+		expectedVerb     string
+		expectedResource string
+	}{
+		"Verify namespaced list": {
+			method: http.MethodGet, url: "/api/v1/namespaces/default/pods?limit=500",
+			expectedVerb: "list", expectedResource: "pods",
+		},
+		"Verify list in all namespaces": {
+			method: http.MethodGet, url: "/api/v1/events",
+			expectedVerb: "list", expectedResource: "events",
+		},
+		"Verify get": {
+			method: http.MethodGet, url: "/api/v1/namespaces/default/pods/foo",
+			expectedVerb: "get", expectedResource: "pods",
+		},
+		"Verify get of a cluster scoped resource": {
+			method: http.MethodGet, url: "/api/v1/nodes/node1",
+			expectedVerb: "get", expectedResource: "nodes",
+		},
+		"Verify get of a namespace": {
+			method: http.MethodGet, url: "/api/v1/namespaces/default",
+			expectedVerb: "get", expectedResource: "namespaces",
+		},
+		"Verify watch": {
+			method: http.MethodGet, url: "/api/v1/namespaces/default/pods?watch=true",
+			expectedVerb: "watch", expectedResource: "pods",
+		},
+		"Verify delete": {
+			method: http.MethodDelete, url: "/api/v1/namespaces/default/pods/foo",
+			expectedVerb: "delete", expectedResource: "pods",
+		},
+		"Verify patch in an API group": {
+			method: http.MethodPatch, url: "/apis/apps/v1/namespaces/default/deployments/foo",
+			expectedVerb: "patch", expectedResource: "deployments",
+		},
+		"Verify create": {
+			method: http.MethodPost, url: "/api/v1/namespaces/default/events",
+			expectedVerb: "create", expectedResource: "events",
+		},
+		"Verify requests that are not for API resources": {
+			method: http.MethodGet, url: "/version",
+			expectedVerb: "get",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			verb, resource := requestVerb(httptest.NewRequest(tc.method, tc.url, nil))
+			assert.Equal(t, tc.expectedVerb, verb)
+			assert.Equal(t, tc.expectedResource, resource)
+		})
+	}
+}
+
+// roundTripperFunc is a http.RoundTripper implemented by a function
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestAPICallCounter(t *testing.T) {
+	counter := newAPICallCounter()
+	rt := counter.wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/events", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/foo", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/bar", nil),
+		httptest.NewRequest(http.MethodDelete, "/api/v1/namespaces/default/pods/foo", nil),
+	} {
+		_, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+	}
+
+	client := kubeClient{apiCalls: counter}
+	assert.Equal(t, map[string]int{"list": 1, "get": 2, "delete": 1}, client.APICalls())
+	assert.Equal(t, "1 list, 2 get, 1 delete", FormatAPICalls(client.APICalls()))
+	assert.Equal(t, "2 list, 1 get, 1 create, 3 patch", FormatAPICalls(map[string]int{"patch": 3, "create": 1, "get": 1, "list": 2}))
+
+	// clients not created by NewK8sClient do not count API calls
+	assert.Empty(t, (&kubeClient{}).APICalls())
+}
@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reasons of the Events cluster-autoscaler records on Pending Pods
+const (
+	triggeredScaleUpReason  = "TriggeredScaleUp"
+	notTriggerScaleUpReason = "NotTriggerScaleUp"
+)
+
+// scaleUpTimeout is how long a triggered scale-up is considered in progress
+// it matches the default --max-node-provision-time of cluster-autoscaler, a node that is not ready by then is not coming
+const scaleUpTimeout = 15 * time.Minute
+
+// getAutoscalerEvents returns the cluster-autoscaler scale-up Events of a Pod
+// the Event filters are not applied, scale-up Events are Normal Events recorded by cluster-autoscaler
+func (c *kubeClient) getAutoscalerEvents(ctx context.Context, pod, namespace string) ([]PodEvent, error) {
+	var eventList *v1.EventList
+	err := c.retryThrottled(ctx, func() (err error) {
+		eventList, err = c.clientSet.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod),
+			TypeMeta:      metav1.TypeMeta{Kind: "Pod"},
+		})
+		return err
+	})
+	if IsCanceled(ctx, err) {
+		return nil, fmt.Errorf("Shutting down: stopped listing Pod's autoscaler Events: %s/%s: %w", namespace, pod, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not go through Pod's autoscaler Events: %s/%s\n%w", namespace, pod, err)
+	}
+
+	var podEvents []PodEvent
+	for _, item := range eventList.Items {
+		if item.Reason == triggeredScaleUpReason || item.Reason == notTriggerScaleUpReason {
+			podEvents = append(podEvents, newPodEvent(&item))
+		}
+	}
+	return podEvents, nil
+}
+
+// activeScaleUp returns the TriggeredScaleUp Event of a scale-up in progress, if the latest scale-up Event is one
+// a later NotTriggerScaleUp Event means cluster-autoscaler gave up, a scale-up older than scaleUpTimeout is not in progress anymore
+func activeScaleUp(events []PodEvent, now time.Time) (PodEvent, bool) {
+	var latest PodEvent
+	for _, event := range events {
+		if latest.Reason == "" || eventTime(event).After(eventTime(latest)) {
+			latest = event
+		}
+	}
+	if latest.Reason != triggeredScaleUpReason || now.Sub(eventTime(latest)) >= scaleUpTimeout {
+		return PodEvent{}, false
+	}
+	return latest, true
+}
+
+// eventTime returns when an Event last occurred
+func eventTime(event PodEvent) time.Time {
+	if event.LastTimestamp.IsZero() {
+		return event.FirstTimestamp
+	}
+	return event.LastTimestamp
+}
+
+// verifyNoScaleUp returns error if Pod is Pending on a scale-up cluster-autoscaler is provisioning nodes for
+// the Pod is expected to be scheduled on the new node, deleting it fights the autoscaler
+func (c *kubeClient) verifyNoScaleUp(ctx context.Context, p *PodDetails) error {
+	if p.Phase != v1.PodPending {
+		return nil
+	}
+	events, err := c.getAutoscalerEvents(ctx, p.PodName, p.PodNamespace)
+	if err != nil {
+		return err
+	}
+	now := c.clock().Now()
+	event, ok := activeScaleUp(events, now)
+	if !ok {
+		return nil
+	}
+	msg := fmt.Sprintf(
+		"Pod is Pending on a scale-up cluster-autoscaler triggered %v ago (%s): %s/%s",
+		now.Sub(eventTime(event)).Truncate(time.Second), event.Message, p.PodNamespace, p.PodName,
+	)
+	return errors.New(msg)
+}
@@ -0,0 +1,81 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestActiveScaleUp(t *testing.T) {
+	now := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+	triggered := PodEvent{Reason: triggeredScaleUpReason, Message: "pod triggered scale-up: [{pool-a 1->2 (max: 5)}]", LastTimestamp: now.Add(-2 * time.Minute)}
+
+	tests := map[string]struct {
+		events   []PodEvent
+		expected bool
+	}{
+		"Verify no scale-up without autoscaler Events": {
+			events:   nil,
+			expected: false,
+		},
+		"Verify a recent TriggeredScaleUp is in progress": {
+			events:   []PodEvent{triggered},
+			expected: true,
+		},
+		"Verify a TriggeredScaleUp older than the timeout is not in progress": {
+			events:   []PodEvent{{Reason: triggeredScaleUpReason, LastTimestamp: now.Add(-scaleUpTimeout)}},
+			expected: false,
+		},
+		"Verify a later NotTriggerScaleUp ends the scale-up": {
+			events:   []PodEvent{triggered, {Reason: notTriggerScaleUpReason, LastTimestamp: now.Add(-time.Minute)}},
+			expected: false,
+		},
+		"Verify a later TriggeredScaleUp starts a new scale-up": {
+			events:   []PodEvent{{Reason: notTriggerScaleUpReason, FirstTimestamp: now.Add(-5 * time.Minute)}, triggered},
+			expected: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, ok := activeScaleUp(tc.events, now)
+			assert.Equal(t, tc.expected, ok)
+			if tc.expected {
+				assert.Equal(t, triggered, event)
+			}
+		})
+	}
+}
+
+func TestVerifyNoScaleUp(t *testing.T) {
+	var ctx = context.TODO()
+	now := time.Now()
+	scaleUp := makeEvent("foo", "default", triggeredScaleUpReason, "pod triggered scale-up: [{pool-a 1->2 (max: 5)}]", v1.EventTypeNormal, 1, "uid1")
+	scaleUp.Source.Component = "cluster-autoscaler"
+	scaleUp.LastTimestamp = metav1.NewTime(now.Add(-3 * time.Minute))
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(scaleUp),
+		// scale-up Events are Normal Events, they are found regardless of the Event filters
+		opts: Options{EventType: v1.EventTypeWarning, Clock: clocktesting.NewFakeClock(now)},
+	}
+	pod := &PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending}
+
+	err := client.verifyNoScaleUp(ctx, pod)
+	assert.EqualError(t, err, "Pod is Pending on a scale-up cluster-autoscaler triggered 3m0s ago (pod triggered scale-up: [{pool-a 1->2 (max: 5)}]): default/foo")
+
+	// Pods that are not Pending are not waiting for a node
+	pod.Phase = v1.PodRunning
+	require.NoError(t, client.verifyNoScaleUp(ctx, pod))
+
+	// the scale-up is over once the node should have been provisioned
+	pod.Phase = v1.PodPending
+	client.opts.Clock = clocktesting.NewFakeClock(now.Add(scaleUpTimeout))
+	require.NoError(t, client.verifyNoScaleUp(ctx, pod))
+}
@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	"k8s.io/utils/clock"
+)
+
+// CircuitBreaker pauses deletions when too many Pods are deleted within a rolling window
+// this protects the cluster from a matching rule that is too broad
+type CircuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	deletions []time.Time // deletion timestamps within the rolling window
+	openUntil time.Time
+	clock     clock.PassiveClock
+}
+
+// CircuitBreakerState holds the circuit breaker state
+type CircuitBreakerState struct {
+	Open            bool      `json:"open"`
+	OpenUntil       time.Time `json:"openUntil,omitempty"`
+	RecentDeletions int       `json:"recentDeletions"`
+	Threshold       int       `json:"threshold"`
+	Window          string    `json:"window"`
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that trips open for cooldown after threshold deletions within window
+// the window and the cooldown are measured with clk
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration, clk clock.PassiveClock) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		clock:     clk,
+	}
+}
+
+// Allow returns true and records a deletion if the breaker is closed and the deletion is within the threshold
+// if the deletion would exceed the threshold, the breaker trips open and Allow returns false until cooldown has passed
+// the deletion must be given back with Release if the Pod is not deleted after all
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if now.Before(b.openUntil) {
+		return false
+	}
+
+	// drop deletions that are outside the rolling window
+	windowStart := now.Add(-b.window)
+	recent := b.deletions[:0]
+	for _, t := range b.deletions {
+		if t.After(windowStart) {
+			recent = append(recent, t)
+		}
+	}
+	b.deletions = recent
+
+	if len(b.deletions) >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+		b.deletions = nil
+		log.Printf(
+			"WARNING: CIRCUIT BREAKER OPEN: %d Pods deleted within %v, pausing all deletions until %s",
+			b.threshold, b.window, b.openUntil.Format(time.RFC3339),
+		)
+		metrics.CircuitBreakerTrips.Inc()
+		return false
+	}
+	b.deletions = append(b.deletions, now)
+	return true
+}
+
+// Release gives back the latest deletion recorded by Allow, eg: when the deletion failed
+// only Pods actually deleted count toward tripping the breaker
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.deletions) > 0 {
+		b.deletions = b.deletions[:len(b.deletions)-1]
+	}
+}
+
+// State returns the circuit breaker state
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := CircuitBreakerState{
+		RecentDeletions: len(b.deletions),
+		Threshold:       b.threshold,
+		Window:          b.window.String(),
+	}
+	if b.clock.Now().Before(b.openUntil) {
+		state.Open = true
+		state.OpenUntil = b.openUntil
+	}
+	return state
+}
@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	e "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	breaker := NewCircuitBreaker(2, time.Minute, 10*time.Minute, fakeClock)
+
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, 2, breaker.State().RecentDeletions)
+
+	// third deletion within the window trips the breaker
+	assert.False(t, breaker.Allow())
+	assert.True(t, breaker.State().Open)
+
+	// breaker stays open during cooldown
+	fakeClock.Step(5 * time.Minute)
+	assert.False(t, breaker.Allow())
+
+	// breaker closes after cooldown
+	fakeClock.Step(6 * time.Minute)
+	assert.False(t, breaker.State().Open)
+	assert.True(t, breaker.Allow())
+
+	// deletions outside the rolling window do not count
+	fakeClock.Step(2 * time.Minute)
+	assert.True(t, breaker.Allow())
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, 2, breaker.State().RecentDeletions)
+}
+
+func TestDeletePodCircuitBreakerOpen(t *testing.T) {
+	var ctx = context.TODO()
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			makePod("pod_1", "default", 1, corev1.PodPending, "uid1"),
+			makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+		),
+		opts: Options{CircuitBreaker: NewCircuitBreaker(1, time.Minute, time.Minute, clock.RealClock{})},
+	}
+
+	require.NoError(t, client.DeletePod(ctx, "pod_1", "default"))
+	err := client.DeletePod(ctx, "pod_2", "default")
+	assert.Error(t, err)
+
+	_, err = client.GetPodDetails(ctx, "pod_2", "default")
+	assert.NoError(t, err, "Pod should not have been deleted while the circuit breaker is open")
+}
+
+func TestDeletePodCircuitBreakerFailedDeletions(t *testing.T) {
+	var ctx = context.TODO()
+	clientSet := fake.NewSimpleClientset(
+		makePod("pod_1", "default", 1, corev1.PodPending, "uid1"),
+		makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+	)
+	// every deletion conflicts with a controller
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, e.NewConflict(schema.GroupResource{Resource: "pods"}, action.(k8stesting.DeleteAction).GetName(), nil)
+	})
+	breaker := NewCircuitBreaker(1, time.Minute, time.Minute, clock.RealClock{})
+	client := kubeClient{clientSet: clientSet, opts: Options{CircuitBreaker: breaker}}
+
+	// failed deletions do not count toward tripping the breaker
+	assert.Error(t, client.DeletePod(ctx, "pod_1", "default"))
+	assert.NotEqual(t, DecisionSkippedCircuitBreaker, DecisionOf(client.DeletePod(ctx, "pod_2", "default")))
+	assert.False(t, breaker.State().Open)
+	assert.Equal(t, 0, breaker.State().RecentDeletions)
+
+	// neither do deletions skipped by a later gate
+	client = kubeClient{
+		clientSet: fake.NewSimpleClientset(makePod("pod_1", "default", 1, corev1.PodPending, "uid1")),
+		opts:      Options{CircuitBreaker: breaker, DeletionBudget: NewDeletionBudget(0)},
+	}
+	assert.Equal(t, DecisionSkippedBudgetExhausted, DecisionOf(client.DeletePod(ctx, "pod_1", "default")))
+	assert.Equal(t, 0, breaker.State().RecentDeletions)
+}
@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"log"
+	"sync"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+)
+
+// DeletionBudget limits the number of Pods deleted over the process lifetime
+// this is a hard safety limit for cautious first deployments
+type DeletionBudget struct {
+	limit int
+
+	mu   sync.Mutex
+	used int
+}
+
+// DeletionBudgetState holds the deletion budget state
+type DeletionBudgetState struct {
+	Limit     int  `json:"limit"`
+	Used      int  `json:"used"`
+	Remaining int  `json:"remaining"`
+	Exhausted bool `json:"exhausted"`
+}
+
+// NewDeletionBudget returns a DeletionBudget that allows at most limit deletions
+func NewDeletionBudget(limit int) *DeletionBudget {
+	metrics.DeletionBudgetRemaining.Set(float64(limit))
+	return &DeletionBudget{limit: limit}
+}
+
+// Reserve returns true and takes one deletion from the budget if the budget is not exhausted
+// the deletion must be given back with Release if it fails
+func (b *DeletionBudget) Reserve() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used >= b.limit {
+		return false
+	}
+	b.used++
+	metrics.DeletionBudgetRemaining.Set(float64(b.limit - b.used))
+	if b.used == b.limit {
+		log.Printf("WARNING: DELETION BUDGET EXHAUSTED: %d Pods deleted, no more Pods will be deleted", b.limit)
+	}
+	return true
+}
+
+// Release gives back a deletion taken with Reserve, eg: when the deletion failed
+func (b *DeletionBudget) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used > 0 {
+		b.used--
+	}
+	metrics.DeletionBudgetRemaining.Set(float64(b.limit - b.used))
+}
+
+// State returns the deletion budget state
+func (b *DeletionBudget) State() DeletionBudgetState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return DeletionBudgetState{
+		Limit:     b.limit,
+		Used:      b.used,
+		Remaining: b.limit - b.used,
+		Exhausted: b.used >= b.limit,
+	}
+}
@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeletionBudget(t *testing.T) {
+	budget := NewDeletionBudget(2)
+
+	assert.True(t, budget.Reserve())
+	assert.True(t, budget.Reserve())
+	assert.Equal(t, DeletionBudgetState{Limit: 2, Used: 2, Remaining: 0, Exhausted: true}, budget.State())
+	assert.False(t, budget.Reserve())
+
+	// failed deletions are given back to the budget
+	budget.Release()
+	assert.Equal(t, 1, budget.State().Remaining)
+	assert.True(t, budget.Reserve())
+	assert.False(t, budget.Reserve())
+}
+
+func TestDeletePodDeletionBudget(t *testing.T) {
+	var ctx = context.TODO()
+	clientSet := fake.NewSimpleClientset(
+		makePod("pod_1", "default", 1, corev1.PodPending, "uid1"),
+		makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+		makePod("pod_3", "default", 1, corev1.PodPending, "uid3"),
+	)
+	client := kubeClient{
+		clientSet: clientSet,
+		opts:      Options{DeletionBudget: NewDeletionBudget(1)},
+	}
+
+	// a failed deletion does not use the budget
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() == "pod_1" {
+			return true, nil, errors.New("etcdserver: request timed out")
+		}
+		return false, nil, nil
+	})
+	assert.Error(t, client.DeletePod(ctx, "pod_1", "default"))
+	require.NoError(t, client.DeletePod(ctx, "pod_2", "default"))
+
+	err := client.DeletePod(ctx, "pod_3", "default")
+	assert.Equal(t, DecisionSkippedBudgetExhausted, DecisionOf(err))
+	_, err = client.GetPodDetails(ctx, "pod_3", "default")
+	assert.NoError(t, err, "Pod should not have been deleted once the deletion budget is exhausted")
+}
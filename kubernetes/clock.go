@@ -0,0 +1,13 @@
+package kubernetes
+
+import (
+	"k8s.io/utils/clock"
+)
+
+// clock returns the clock time-based checks and waits use, the real clock unless Options.Clock is set (eg: a fake clock in tests)
+func (c *kubeClient) clock() clock.WithTicker {
+	if c.opts.Clock != nil {
+		return c.opts.Clock
+	}
+	return clock.RealClock{}
+}
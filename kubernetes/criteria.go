@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigMap keys holding matching criteria
+const (
+	criteriaReasonKey       = "reason"
+	criteriaErrorMessageKey = "error-message"
+)
+
+// Criteria holds the Event Reason and Error Message Pods are matched by
+type Criteria struct {
+	Reason       string
+	ErrorMessage string
+}
+
+// ParseConfigMapRef returns namespace and name of a ConfigMap referenced as namespace/name
+func ParseConfigMapRef(ref string) (string, string, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("ConfigMap reference %q is not valid, use namespace/name", ref)
+	}
+	return namespace, name, nil
+}
+
+// GetCriteria returns the matching criteria stored in a ConfigMap
+// returns error if the ConfigMap cannot be read or its contents are not valid
+func (c *kubeClient) GetCriteria(ctx context.Context, namespace, name string) (*Criteria, error) {
+	var cm *v1.ConfigMap
+	err := c.retryThrottled(ctx, func() (err error) {
+		cm, err = c.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not get criteria ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	criteria, err := parseCriteria(cm.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Criteria ConfigMap %s/%s is not valid: %w", namespace, name, err)
+	}
+	return criteria, nil
+}
+
+// parseCriteria returns the matching criteria stored in ConfigMap data
+// both reason and error-message keys are required, surrounding whitespace is trimmed
+func parseCriteria(data map[string]string) (*Criteria, error) {
+	criteria := &Criteria{
+		Reason:       strings.TrimSpace(data[criteriaReasonKey]),
+		ErrorMessage: strings.TrimSpace(data[criteriaErrorMessageKey]),
+	}
+	if criteria.Reason == "" {
+		return nil, fmt.Errorf("key %s is missing or empty", criteriaReasonKey)
+	}
+	if criteria.ErrorMessage == "" {
+		return nil, fmt.Errorf("key %s is missing or empty", criteriaErrorMessageKey)
+	}
+	return criteria, nil
+}
@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetCriteria(t *testing.T) {
+	tests := map[string]struct {
+		data        map[string]string
+		expected    *Criteria
+		expectError bool
+	}{
+		"Verify criteria are loaded from ConfigMap": {
+			data:     map[string]string{"reason": "BackOff", "error-message": "Back-off pulling image\n"},
+			expected: &Criteria{Reason: "BackOff", ErrorMessage: "Back-off pulling image"},
+		},
+		"Verify error is returned when error message is missing": {
+			data:        map[string]string{"reason": "BackOff"},
+			expectError: true,
+		},
+		"Verify error is returned when reason is empty": {
+			data:        map[string]string{"reason": " ", "error-message": "Back-off pulling image"},
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ctx = context.TODO()
+			clt := kubeClient{clientSet: fake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "criteria", Namespace: "pod-restarter"},
+				Data:       tc.data,
+			})}
+
+			criteria, err := clt.GetCriteria(ctx, "pod-restarter", "criteria")
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, criteria)
+		})
+	}
+}
+
+func TestParseConfigMapRef(t *testing.T) {
+	namespace, name, err := ParseConfigMapRef("pod-restarter/criteria")
+	require.NoError(t, err)
+	assert.Equal(t, "pod-restarter", namespace)
+	assert.Equal(t, "criteria", name)
+
+	_, _, err = ParseConfigMapRef("criteria")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"errors"
+)
+
+// Decision is the reason code of the outcome of evaluating a matched Pod
+type Decision string
+
+// decisions for matched Pods
+const (
+	DecisionDeleted                     Decision = "DELETED"
+	DecisionDryRun                      Decision = "DRY_RUN"
+	DecisionObserved                    Decision = "OBSERVED"
+	DecisionSkippedActiveWindow         Decision = "SKIPPED_ACTIVE_WINDOW"
+	DecisionSkippedNotFound             Decision = "SKIPPED_NOT_FOUND"
+	DecisionSkippedNamespaceTerminating Decision = "SKIPPED_NAMESPACE_TERMINATING"
+	DecisionSkippedDuplicate            Decision = "SKIPPED_DUPLICATE"
+	DecisionSkippedMirrorPod            Decision = "SKIPPED_MIRROR_POD"
+	DecisionSkippedRequiredAnnotation   Decision = "SKIPPED_REQUIRED_ANNOTATION"
+	DecisionSkippedNoOwner              Decision = "SKIPPED_NO_OWNER"
+	DecisionSkippedTerminating          Decision = "SKIPPED_TERMINATING"
+	DecisionSkippedFinalizers           Decision = "SKIPPED_FINALIZERS"
+	DecisionSkippedPriority             Decision = "SKIPPED_PRIORITY"
+	DecisionSkippedNode                 Decision = "SKIPPED_NODE"
+	DecisionSkippedBoundPending         Decision = "SKIPPED_BOUND_PENDING"
+	DecisionSkippedNodeCondition        Decision = "SKIPPED_NODE_CONDITION"
+	DecisionSkippedPVCPending           Decision = "SKIPPED_PVC_PENDING"
+	DecisionSkippedScaleUp              Decision = "SKIPPED_SCALE_UP"
+	DecisionSkippedPendingAge           Decision = "SKIPPED_PENDING_AGE"
+	DecisionSkippedScheduleMessage      Decision = "SKIPPED_SCHEDULE_MESSAGE"
+	DecisionSkippedRecentlyChanged      Decision = "SKIPPED_RECENTLY_CHANGED"
+	DecisionSkippedCrashLooping         Decision = "SKIPPED_CRASHLOOPING"
+	DecisionSelfHealed                  Decision = "SELF_HEALED"
+	DecisionSkippedCircuitBreaker       Decision = "SKIPPED_CIRCUIT_BREAKER"
+	DecisionSkippedBudgetExhausted      Decision = "SKIPPED_BUDGET_EXHAUSTED"
+	DecisionSkippedPersistentFailure    Decision = "SKIPPED_PERSISTENT_FAILURE"
+	DecisionSkippedAdmissionRejected    Decision = "SKIPPED_ADMISSION_REJECTED"
+	DecisionDeleteScheduled             Decision = "DELETE_SCHEDULED"
+	DecisionSkippedDeleteTTL            Decision = "SKIPPED_DELETE_TTL"
+	DecisionSkippedAnnotation           Decision = "SKIPPED_ANNOTATION"
+	DecisionCanceled                    Decision = "CANCELED"
+	DecisionErrorGetPod                 Decision = "ERROR_GET_POD"
+	DecisionError                       Decision = "ERROR"
+)
+
+// decisionError is returned by PodChecks and DeletePod when a Pod is not deleted
+type decisionError struct {
+	decision Decision
+	err      error
+}
+
+func (e *decisionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *decisionError) Unwrap() error {
+	return e.err
+}
+
+// skip returns err annotated with the decision not to delete a Pod
+func skip(decision Decision, err error) error {
+	return &decisionError{decision: decision, err: err}
+}
+
+// DecisionOf returns the decision for an error returned by PodChecks or DeletePod
+// errors without a decision are DecisionError
+func DecisionOf(err error) Decision {
+	var de *decisionError
+	if errors.As(err, &de) {
+		return de.decision
+	}
+	return DecisionError
+}
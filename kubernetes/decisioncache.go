@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+)
+
+// decisionCacheRetention is how long a cached decision is reused while the Pod does not change
+// decisions are re-evaluated after that, so changes outside the Pod (eg: a Node that is gone) are picked up eventually
+const decisionCacheRetention = 15 * time.Minute
+
+// cacheableDecisions are the decisions that only depend on the Pod object, they stay valid while its resourceVersion does not change
+// decisions depending on time (eg: SKIPPED_PENDING_AGE) or on other objects (eg: SKIPPED_NODE_CONDITION) are not cached
+var cacheableDecisions = map[Decision]bool{
+	DecisionSkippedMirrorPod:          true,
+	DecisionSkippedRequiredAnnotation: true,
+	DecisionSkippedNoOwner:            true,
+	DecisionSkippedTerminating:        true,
+	DecisionSkippedFinalizers:         true,
+	DecisionSkippedNode:               true,
+	DecisionSkippedBoundPending:       true,
+	DecisionSkippedPVCPending:         true,
+	DecisionSkippedScheduleMessage:    true,
+	DecisionSkippedCrashLooping:       true,
+	DecisionSelfHealed:                true,
+}
+
+// cachedDecision is the decision of a Pod at a resourceVersion
+type cachedDecision struct {
+	resourceVersion string
+	err             error
+	cachedAt        time.Time
+}
+
+// DecisionCache remembers the decision not to delete a Pod, by Pod UID
+// matched Pods that did not change since they were last evaluated get the same decision without being checked again
+type DecisionCache struct {
+	mu        sync.Mutex
+	decisions map[types.UID]cachedDecision
+	clock     clock.PassiveClock
+}
+
+// NewDecisionCache returns an empty DecisionCache, the retention is measured with clk
+func NewDecisionCache(clk clock.PassiveClock) *DecisionCache {
+	return &DecisionCache{
+		decisions: make(map[types.UID]cachedDecision),
+		clock:     clk,
+	}
+}
+
+// Lookup returns the cached decision of a Pod, nil if the Pod changed since it was cached or was not cached
+func (d *DecisionCache) Lookup(p *PodDetails) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prune()
+	cached, ok := d.decisions[p.UID]
+	hit := ok && cached.resourceVersion == p.ResourceVersion
+	metrics.DecisionCacheLookup(hit)
+	if !hit {
+		return nil
+	}
+	return cached.err
+}
+
+// Store caches the decision of a Pod, decisions that do not only depend on the Pod are not cached
+func (d *DecisionCache) Store(p *PodDetails, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err == nil || !cacheableDecisions[DecisionOf(err)] {
+		delete(d.decisions, p.UID)
+		return
+	}
+	d.decisions[p.UID] = cachedDecision{
+		resourceVersion: p.ResourceVersion,
+		err:             err,
+		cachedAt:        d.clock.Now(),
+	}
+}
+
+// prune drops decisions older than the retention, so the cache does not grow forever with Pods that are gone
+func (d *DecisionCache) prune() {
+	retentionStart := d.clock.Now().Add(-decisionCacheRetention)
+	for uid, cached := range d.decisions {
+		if cached.cachedAt.Before(retentionStart) {
+			delete(d.decisions, uid)
+		}
+	}
+}
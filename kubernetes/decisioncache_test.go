@@ -0,0 +1,62 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDecisionCache(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	cache := NewDecisionCache(fakeClock)
+
+	pod := &PodDetails{UID: "uid1", ResourceVersion: "1"}
+	assert.Nil(t, cache.Lookup(pod))
+
+	// decisions that only depend on the Pod are cached while the Pod does not change
+	noOwner := skip(DecisionSkippedNoOwner, assert.AnError)
+	cache.Store(pod, noOwner)
+	assert.Equal(t, noOwner, cache.Lookup(pod))
+	assert.Nil(t, cache.Lookup(&PodDetails{UID: "uid1", ResourceVersion: "2"}))
+
+	// time-based decisions are not cached, and replace the cached decision
+	cache.Store(pod, skip(DecisionSkippedPendingAge, assert.AnError))
+	assert.Nil(t, cache.Lookup(pod))
+
+	// cached decisions expire
+	cache.Store(pod, noOwner)
+	fakeClock.Step(decisionCacheRetention + time.Second)
+	assert.Nil(t, cache.Lookup(pod))
+}
+
+func TestPodChecksDecisionCache(t *testing.T) {
+	var ctx = context.TODO()
+	pod := makePod("foo", "default", 1, corev1.PodPending, "uid1")
+	clientSet := fake.NewSimpleClientset(pod)
+	client := kubeClient{clientSet: clientSet, opts: Options{DecisionCache: NewDecisionCache(clock.RealClock{})}}
+
+	// the owner-less Pod is skipped and its decision cached
+	err := client.PodChecks(ctx, "foo", "default")
+	assert.Equal(t, DecisionSkippedNoOwner, DecisionOf(err))
+
+	// the unchanged Pod gets the cached decision, without being checked again
+	client.checkedPods = nil
+	client.opts.DeleteOrphans = true
+	cached := client.PodChecks(ctx, "foo", "default")
+	assert.Equal(t, err, cached)
+
+	// the Pod changed, it is checked again
+	pod.ResourceVersion = "2"
+	_, err = clientSet.CoreV1().Pods("default").Update(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	client.checkedPods = nil
+	require.NoError(t, client.PodChecks(ctx, "foo", "default"))
+}
@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// NewFixturesClient returns a client serving Pods, Events and other objects loaded from the YAML/JSON files in dir
+// instead of a cluster, so matching rules can be tested offline
+// files can hold multiple documents and Lists, eg: the output of kubectl get pods,events -o yaml
+func NewFixturesClient(dir string, opts Options) (*kubeClient, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, file := range files {
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read fixtures file %s: %w", file, err)
+		}
+		fileObjects, err := decodeFixtures(data)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode fixtures file %s: %w", file, err)
+		}
+		objects = append(objects, fileObjects...)
+	}
+	log.Printf("Loaded %d objects from fixtures directory %s", len(objects), dir)
+
+	return &kubeClient{
+		clientSet: fake.NewSimpleClientset(objects...),
+		opts:      opts,
+	}, nil
+}
+
+// decodeFixtures decodes all objects in a YAML/JSON stream, List items are decoded as separate objects
+func decodeFixtures(data []byte) ([]runtime.Object, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	deserializer := scheme.Codecs.UniversalDeserializer()
+
+	var objects []runtime.Object
+	for {
+		var raw runtime.RawExtension
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(raw.Raw)) == 0 {
+			continue
+		}
+
+		obj, _, err := deserializer.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := obj.(*v1.List)
+		if !ok {
+			objects = append(objects, obj)
+			continue
+		}
+		for _, item := range list.Items {
+			itemObj, _, err := deserializer.Decode(item.Raw, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, itemObj)
+		}
+	}
+	return objects, nil
+}
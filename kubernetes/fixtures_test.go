@@ -0,0 +1,28 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFixturesClient(t *testing.T) {
+	var ctx = context.TODO()
+	client, err := NewFixturesClient("testdata/fixtures", Options{})
+	require.NoError(t, err)
+
+	entries, err := client.GenerateReport(ctx, "", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	require.NoError(t, err)
+
+	// bar is Running with healthy containers, only foo would be deleted
+	require.Len(t, entries, 1)
+	assert.Equal(t, "foo-7d9f8b6c5d-abcde", entries[0].PodName)
+	assert.Equal(t, "ReplicaSet", entries[0].OwnerKind)
+}
+
+func TestDecodeFixturesInvalid(t *testing.T) {
+	_, err := decodeFixtures([]byte("apiVersion: v1\nkind: NotAKind\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	e "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ParseLeaseRef returns namespace and name of a Lease referenced as namespace/name
+func ParseLeaseRef(ref string) (string, string, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("Lease reference %q is not valid, use namespace/name", ref)
+	}
+	return namespace, name, nil
+}
+
+// RenewHeartbeat sets the renew time of a Lease to now, the Lease is created if it does not exist
+// external monitoring can tell a pod-restarter that stopped cycling by a renew time older than the lease duration
+func (c *kubeClient) RenewHeartbeat(ctx context.Context, namespace, name, holder string, duration time.Duration) error {
+	api := c.clientSet.CoordinationV1().Leases(namespace)
+	now := metav1.NewMicroTime(c.clock().Now())
+	durationSeconds := int32(duration.Seconds())
+
+	var lease *coordinationv1.Lease
+	err := c.retryThrottled(ctx, func() (err error) {
+		lease, err = api.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if e.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		err = c.retryThrottled(ctx, func() error {
+			_, err := api.Create(ctx, lease, metav1.CreateOptions{})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("Could not create heartbeat Lease %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Could not get heartbeat Lease %s/%s: %w", namespace, name, err)
+	}
+
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+	err = c.retryThrottled(ctx, func() error {
+		_, err := api.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not renew heartbeat Lease %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
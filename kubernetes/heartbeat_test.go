@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestParseLeaseRef(t *testing.T) {
+	namespace, name, err := ParseLeaseRef("pod-restarter/heartbeat")
+	require.NoError(t, err)
+	assert.Equal(t, "pod-restarter", namespace)
+	assert.Equal(t, "heartbeat", name)
+
+	for _, ref := range []string{"", "heartbeat", "/heartbeat", "pod-restarter/"} {
+		_, _, err := ParseLeaseRef(ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestRenewHeartbeat(t *testing.T) {
+	var ctx = context.TODO()
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+	clientSet := fake.NewSimpleClientset()
+	client := kubeClient{
+		clientSet: clientSet,
+		opts:      Options{Clock: fakeClock},
+	}
+
+	// the Lease is created if it does not exist
+	require.NoError(t, client.RenewHeartbeat(ctx, "pod-restarter", "heartbeat", "node1", time.Minute))
+	lease, err := clientSet.CoordinationV1().Leases("pod-restarter").Get(ctx, "heartbeat", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "node1", *lease.Spec.HolderIdentity)
+	assert.Equal(t, int32(60), *lease.Spec.LeaseDurationSeconds)
+	assert.True(t, lease.Spec.RenewTime.Time.Equal(fakeClock.Now()))
+	acquired := lease.Spec.AcquireTime.Time
+
+	// every cycle renews the Lease
+	fakeClock.SetTime(fakeClock.Now().Add(30 * time.Second))
+	require.NoError(t, client.RenewHeartbeat(ctx, "pod-restarter", "heartbeat", "node1", time.Minute))
+	lease, err = clientSet.CoordinationV1().Leases("pod-restarter").Get(ctx, "heartbeat", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, lease.Spec.RenewTime.Time.Equal(fakeClock.Now()))
+	assert.True(t, lease.Spec.AcquireTime.Time.Equal(acquired), "acquire time should not change on renewal")
+}
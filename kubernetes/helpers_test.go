@@ -57,3 +57,33 @@ func makeEvent(name, namespace, eventReason, eventMessage, eventType string,
 		Type:           eventType, // v1.EventTypeNormal, v1.EventTypeWarning
 	}
 }
+
+func makeWaitingPod(name, namespace, reason, message string) *v1.Pod {
+	pod := makePod(name, namespace, 1, v1.PodPending, types.UID(name))
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{
+			Name: "mycontainer",
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{
+					Reason:  reason,
+					Message: message,
+				},
+			},
+		},
+	}
+	return pod
+}
+
+func makeOwnedPod(name, namespace string, phase v1.PodPhase, containerStatuses []v1.ContainerStatus) *v1.Pod {
+	pod := makePod(name, namespace, 1, phase, types.UID(name))
+	pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Name:       fmt.Sprintf("%s-rs", name),
+			UID:        types.UID(fmt.Sprintf("%s-rs", name)),
+		},
+	}
+	pod.Status.ContainerStatuses = containerStatuses
+	return pod
+}
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"k8s.io/utils/clock"
+	"sync"
+	"time"
+)
+
+// deletionHistoryRetention is how long deletions are remembered
+// the kubernetes API server keeps Events for 1 hour by default, older Events are gone anyway
+const deletionHistoryRetention = time.Hour
+
+// DeletionHistory remembers when Pods were last deleted, by namespace/name
+// Pods recreated with the same name (eg: StatefulSet Pods) can still have Events from before their deletion,
+// these Events are about the deleted Pod and must not trigger the deletion of its replacement
+type DeletionHistory struct {
+	mu      sync.Mutex
+	deleted map[string]time.Time // last deletion time by namespace/name
+	clock   clock.PassiveClock
+}
+
+// NewDeletionHistory returns an empty DeletionHistory, deletions are timestamped with clk
+func NewDeletionHistory(clk clock.PassiveClock) *DeletionHistory {
+	return &DeletionHistory{
+		deleted: make(map[string]time.Time),
+		clock:   clk,
+	}
+}
+
+// Record records a deletion of a Pod
+func (h *DeletionHistory) Record(namespace, pod string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.prune()
+	h.deleted[namespace+"/"+pod] = h.clock.Now()
+}
+
+// LastDeleted returns when a Pod was last deleted, false if it was not deleted within the retention
+func (h *DeletionHistory) LastDeleted(namespace, pod string) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.prune()
+	deletedAt, ok := h.deleted[namespace+"/"+pod]
+	return deletedAt, ok
+}
+
+// prune drops deletions older than the retention, so the history does not grow forever
+func (h *DeletionHistory) prune() {
+	retentionStart := h.clock.Now().Add(-deletionHistoryRetention)
+	for key, deletedAt := range h.deleted {
+		if deletedAt.Before(retentionStart) {
+			delete(h.deleted, key)
+		}
+	}
+}
+
+// removeEventsBeforeDeletion returns a slice of Events that happened after their Pod was last deleted
+// Events of Pods that were not deleted are kept
+// Events synthesized from Pod status or by matchers are timestamped now, they are kept only if their Pod was created after the deletion
+func removeEventsBeforeDeletion(events []PodEvent, history *DeletionHistory) []PodEvent {
+	var newerEvents []PodEvent
+	for _, event := range events {
+		deletedAt, ok := history.LastDeleted(event.PodNamespace, event.PodName)
+		happened := event.LastTimestamp
+		if event.Count == 0 && !event.FirstTimestamp.IsZero() {
+			happened = event.FirstTimestamp
+		}
+		if ok && !happened.After(deletedAt) {
+			continue
+		}
+		newerEvents = append(newerEvents, event)
+	}
+	return newerEvents
+}
@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDeletionHistory(t *testing.T) {
+	now := time.Now()
+	fakeClock := clocktesting.NewFakeClock(now)
+	history := NewDeletionHistory(fakeClock)
+
+	history.Record("default", "db-0")
+	deletedAt, ok := history.LastDeleted("default", "db-0")
+	assert.True(t, ok)
+	assert.Equal(t, now, deletedAt)
+
+	// Pods are tracked by namespace and name
+	_, ok = history.LastDeleted("test", "db-0")
+	assert.False(t, ok)
+
+	// deletions are forgotten after the retention
+	fakeClock.Step(deletionHistoryRetention + time.Second)
+	_, ok = history.LastDeleted("default", "db-0")
+	assert.False(t, ok)
+}
+
+func TestGenerateToBeDeletedPodListDeletionHistory(t *testing.T) {
+	var ctx = context.TODO()
+	eventReason := "FailedCreatePodSandBox"
+	errorMessage := "container veth name provided (eth0) already exists"
+	now := time.Now()
+	fakeClock := clocktesting.NewFakeClock(now)
+	history := NewDeletionHistory(fakeClock)
+
+	oldEvent := makeEvent("db-0", "default", eventReason, errorMessage, "Warning", 1, "uid-db-0")
+	oldEvent.LastTimestamp = metav1.NewTime(now.Add(-10 * time.Second))
+	clientSet := fake.NewSimpleClientset(makePod("db-0", "default", 1, corev1.PodPending, "uid-db-0"), oldEvent)
+	client := kubeClient{
+		clientSet: clientSet,
+		opts:      Options{DeletionHistory: history},
+	}
+
+	require.NoError(t, client.DeletePod(ctx, "db-0", "default"))
+
+	// the replacement Pod has the same name, Events from before the deletion do not trigger its deletion
+	_, err := clientSet.CoreV1().Pods("default").Create(ctx, makePod("db-0", "default", 1, corev1.PodPending, types.UID("uid-db-0-new")), metav1.CreateOptions{})
+	require.NoError(t, err)
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", eventReason, errorMessage, 1, 30)
+	require.NoError(t, err)
+	assert.Empty(t, uniquePodList)
+	_, err = client.getPodEvents(ctx, "db-0", "default")
+	assert.ErrorIs(t, err, errNoPodEvents, "Events from before the deletion should have been ignored")
+
+	// Events newer than the deletion are matched
+	newEvent := makeEvent("db-0", "default", eventReason, errorMessage, "Warning", 1, "uid-db-0-new")
+	newEvent.LastTimestamp = metav1.NewTime(now.Add(5 * time.Second))
+	_, err = clientSet.CoreV1().Events("default").Create(ctx, newEvent, metav1.CreateOptions{})
+	require.NoError(t, err)
+	uniquePodList, err = client.GenerateToBeDeletedPodList(ctx, "default", eventReason, errorMessage, 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"db-0": "default"}, uniquePodList)
+}
@@ -0,0 +1,43 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ParseJSONPath returns the parsed JSONPath expression, eg: {.status.containerStatuses[?(@.restartCount>5)].name}
+// the expression is parsed for every evaluation because a parsed JSONPath is not safe for concurrent use
+func ParseJSONPath(expression string) (*jsonpath.JSONPath, error) {
+	j := jsonpath.New("match-jsonpath").AllowMissingKeys(true)
+	err := j.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse JSONPath %q: %w", expression, err)
+	}
+	return j, nil
+}
+
+// matchJSONPath returns the result of the JSONPath expression evaluated against the Pod
+// an empty result (or "false") means the Pod does not match
+func matchJSONPath(expression string, item *v1.Pod) string {
+	j, err := ParseJSONPath(expression)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	var buf bytes.Buffer
+	err = j.Execute(&buf, item)
+	if err != nil {
+		log.Printf("Could not evaluate JSONPath %q on Pod %s/%s: %v", expression, item.Namespace, item.Name, err)
+		return ""
+	}
+	result := strings.TrimSpace(buf.String())
+	if result == "false" {
+		return ""
+	}
+	return result
+}
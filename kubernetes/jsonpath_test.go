@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMatchJSONPath(t *testing.T) {
+	pod := makePod("pod_1", "default", 1, corev1.PodRunning, "uid1")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "nginx", RestartCount: 7},
+		{Name: "sidecar", RestartCount: 0},
+	}
+
+	tests := map[string]struct {
+		expression string
+		expected   string
+	}{
+		"Verify filter result is returned":          {expression: `{.status.containerStatuses[?(@.restartCount>5)].name}`, expected: "nginx"},
+		"Verify empty filter result does not match": {expression: `{.status.containerStatuses[?(@.restartCount>10)].name}`, expected: ""},
+		"Verify missing keys do not match":          {expression: `{.metadata.labels.app}`, expected: ""},
+		"Verify false does not match":               {expression: `{.spec.hostNetwork}`, expected: ""},
+		"Verify invalid expression does not match":  {expression: `{.status[`, expected: ""},
+		"Verify scalar result is returned":          {expression: `{.status.phase}`, expected: "Running"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, matchJSONPath(tc.expression, pod))
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	_, err := ParseJSONPath(`{.status.containerStatuses[?(@.restartCount>5)].name}`)
+	assert.NoError(t, err)
+	_, err = ParseJSONPath(`{.status[`)
+	assert.Error(t, err)
+}
+
+func TestGenerateToBeDeletedPodListMatchJSONPath(t *testing.T) {
+	var ctx = context.TODO()
+	restarted := makePod("pod_2", "default", 1, corev1.PodRunning, "uid2")
+	restarted.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "nginx", RestartCount: 7}}
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			makePod("pod_1", "default", 1, corev1.PodPending, "uid1"),
+			restarted,
+			makePod("pod_3", "default", 1, corev1.PodRunning, "uid3"),
+			makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+		),
+		opts: Options{MatchJSONPath: `{.status.containerStatuses[?(@.restartCount>5)].name}`},
+	}
+
+	// Pods matching Events or the JSONPath expression are matched
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default", "pod_2": "default"}, uniquePodList)
+}
@@ -2,28 +2,41 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
 	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	e "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// eventsForbiddenWarning makes sure the missing Events RBAC warning is logged only once
+var eventsForbiddenWarning sync.Once
+
 type K8sClient interface {
 	DeletePod(ctx context.Context, pod, namespace string) error
 	GenerateToBeDeletedPodList(ctx context.Context, namespace, eventReason, errorMessage string, counter, pollingInterval int) (map[string]string, error)
 	PodChecks(ctx context.Context, podName, podNamespace string) error
+	DeletedPodOwner(pod, namespace string) string
+	MatchedPodCategory(pod, namespace string) string
 }
 
 // NewK8sClient discover if kubeconfig creds are inside a Pod or outside the cluster and return a clientSet
-func NewK8sClient(kubeconfig string) (*kubeClient, error) {
+func NewK8sClient(kubeconfig string, opts Options) (*kubeClient, error) {
 	// read and parse kubeconfig
 	config, err := rest.InClusterConfig() // creates the in-cluster config
 	if err != nil {
@@ -37,6 +50,18 @@ func NewK8sClient(kubeconfig string) (*kubeClient, error) {
 		log.Println("Running from INSIDE the cluster")
 	}
 
+	// set a custom user agent, otherwise client-go default is used
+	if opts.UserAgent != "" {
+		config.UserAgent = opts.UserAgent
+	}
+
+	// count API calls, to size client rate limits and concurrency
+	apiCalls := newAPICallCounter()
+	config.Wrap(apiCalls.wrap)
+
+	// log throttled requests, client-go retries them silently
+	config.Wrap(logThrottled)
+
 	// create the clientset for in-cluster/out-cluster config
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -46,125 +71,230 @@ func NewK8sClient(kubeconfig string) (*kubeClient, error) {
 
 	return &kubeClient{
 		clientSet: clientset,
+		opts:      opts,
+		apiCalls:  apiCalls,
 	}, nil
 }
 
 // listPods returns a list with all the Pods in the Cluster
+// Pods are listed in pages of ListPageSize items to bound response size in large clusters
 func (c *kubeClient) listPods(ctx context.Context, namespace string) (*[]PodDetails, error) {
 	api := c.clientSet.CoreV1()
 	var podData PodDetails
 	var podsData []PodDetails
 
-	// list all Pods in Pending state
-	pods, err := api.Pods(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			TypeMeta: metav1.TypeMeta{Kind: "Pod"},
-			// FieldSelector: "status.phase=Pending",
-		},
-	)
-	if err != nil {
-		msg := fmt.Sprintf("Could not get a list of Pods: \n%v", err)
-		return &podsData, errors.New(msg)
-	}
-
-	for _, pod := range pods.Items {
-		podData = PodDetails{
-			UID:               pod.ObjectMeta.UID,
-			PodName:           pod.ObjectMeta.Name,
-			PodNamespace:      pod.ObjectMeta.Namespace,
-			ResourceVersion:   pod.ObjectMeta.ResourceVersion,
-			Phase:             pod.Status.Phase,
-			ContainerStatuses: pod.Status.ContainerStatuses,
-			OwnerReferences:   pod.ObjectMeta.OwnerReferences,
-			CreationTimestamp: pod.ObjectMeta.CreationTimestamp.Time,
-			DeletionTimestamp: pod.ObjectMeta.DeletionTimestamp,
-		}
-		podsData = append(podsData, podData)
+	listOptions := metav1.ListOptions{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod"},
+		Limit:    c.opts.ListPageSize,
+		// FieldSelector: "status.phase=Pending",
+	}
+	if c.opts.NodeName != "" {
+		listOptions.FieldSelector = fmt.Sprintf("spec.nodeName=%s", c.opts.NodeName)
+	}
+	for {
+		var pods *v1.PodList
+		list := func() (err error) {
+			pods, err = api.Pods(namespace).List(ctx, listOptions)
+			return err
+		}
+		err := c.retryThrottled(ctx, list)
+		if c.useCachedList(err, &listOptions) {
+			log.Printf("WARNING: listing Pods timed out, listing Pods from the API server cache: %v", err)
+			err = c.retryThrottled(ctx, list)
+		}
+		if IsCanceled(ctx, err) {
+			return &podsData, fmt.Errorf("Shutting down: stopped listing Pods: %w", err)
+		} else if err != nil {
+			msg := fmt.Sprintf("Could not get a list of Pods: \n%v", err)
+			return &podsData, errors.New(msg)
+		}
+
+		for _, pod := range pods.Items {
+			podData = c.podDetails(&pod)
+			podsData = append(podsData, podData)
+		}
+
+		if pods.Continue == "" {
+			break
+		}
+		listOptions.Continue = pods.Continue
 	}
 	log.Printf("There is a TOTAL of %d Pods in the cluster\n", len(podsData))
 	return &podsData, nil
 }
 
-// GetEvents returns a list of namespaced Events that match Reason
-func (c *kubeClient) GetEvents(ctx context.Context, namespace, eventReason, errorMessage string) ([]PodEvent, error) {
-	api := c.clientSet.CoreV1()
-	var podEvents []PodEvent
+// namespacePods lists the Pods of a namespace at most once, for all matching steps that need them
+type namespacePods struct {
+	namespace string
+	pods      *[]PodDetails
+}
 
-	eventList, err := api.Events(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			TypeMeta: metav1.TypeMeta{Kind: "Pod"},
-			// ResourceVersion: "46641835",
-		})
+// list returns the Pods of the namespace, they are listed on the first call
+func (n *namespacePods) list(ctx context.Context, c *kubeClient) (*[]PodDetails, error) {
+	if n.pods == nil {
+		pods, err := c.listPods(ctx, n.namespace)
+		if err != nil {
+			return nil, err
+		}
+		n.pods = pods
+	}
+	return n.pods, nil
+}
 
+// creationTimes returns the creation times of the Pods of the namespace, by Pod UID
+func (n *namespacePods) creationTimes(ctx context.Context, c *kubeClient) (map[types.UID]time.Time, error) {
+	podList, err := n.list(ctx, c)
 	if err != nil {
-		msg := fmt.Sprintf("Could not get Events in namespace: %s\n%s", namespace, err)
-		return podEvents, errors.New(msg)
+		return nil, err
+	}
+	podCreation := make(map[types.UID]time.Time, len(*podList))
+	for _, pod := range *podList {
+		podCreation[pod.UID] = pod.CreationTimestamp
+	}
+	return podCreation, nil
+}
+
+// useCachedList returns true if a List that timed out should be retried from the API server cache
+// if so, listOptions are changed to serve the List from the cache (resourceVersion 0), which does not support pagination
+// only a List that timed out on its first page is retried, so a List never mixes consistent and cached pages
+func (c *kubeClient) useCachedList(err error, listOptions *metav1.ListOptions) bool {
+	if !c.opts.AllowCachedList || !isTimeout(err) || listOptions.Continue != "" || listOptions.ResourceVersion == "0" {
+		return false
 	}
+	listOptions.ResourceVersion = "0"
+	listOptions.Limit = 0
+	return true
+}
 
+// GetEvents returns a list of namespaced Events that match Reason
+func (c *kubeClient) GetEvents(ctx context.Context, namespace, eventReason, errorMessage string) ([]PodEvent, error) {
 	// keep only Events that match event Reason (eg: FailedCreatePodSandBox)
 	// keep only Events that have errorMessage
-	// TO ADD filter out Events older than polling interval
-	for _, item := range eventList.Items {
-		if item.Reason == eventReason && strings.Contains(item.Message, errorMessage) {
-			podEventData := PodEvent{
-				UID:             item.InvolvedObject.UID,
-				PodName:         item.InvolvedObject.Name,
-				PodNamespace:    item.InvolvedObject.Namespace,
-				ResourceVersion: item.InvolvedObject.ResourceVersion,
-				Reason:          item.Reason,
-				EventType:       item.Type,
-				Message:         item.Message,
-				FirstTimestamp:  item.FirstTimestamp.Time,
-				LastTimestamp:   item.LastTimestamp.Time,
+	// keep at most MaxEventsPerPod Events per Pod, matching only needs the first occurrence
+	matchedEventsPerPod := make(map[string]int)
+	return c.listEvents(ctx, namespace, func(event PodEvent) bool {
+		if c.opts.MaxEventsPerPod > 0 && matchedEventsPerPod[string(event.UID)] >= c.opts.MaxEventsPerPod {
+			return false
+		}
+		if event.Reason == eventReason && c.matchesMessage(event.Message, errorMessage) {
+			matchedEventsPerPod[string(event.UID)]++
+			return true
+		}
+		return false
+	})
+}
+
+// listEvents returns a list of namespaced Events for which keep returns true
+// Events are listed in pages of ListPageSize items and filtered page by page to bound memory in large clusters
+func (c *kubeClient) listEvents(ctx context.Context, namespace string, keep func(PodEvent) bool) ([]PodEvent, error) {
+	api := c.clientSet.CoreV1()
+	var podEvents []PodEvent
+
+	listOptions := metav1.ListOptions{
+		TypeMeta:      metav1.TypeMeta{Kind: "Pod"},
+		Limit:         c.opts.ListPageSize,
+		FieldSelector: c.eventFieldSelector(),
+	}
+	for {
+		var eventList *v1.EventList
+		list := func() (err error) {
+			eventList, err = api.Events(namespace).List(ctx, listOptions)
+			return err
+		}
+		err := c.retryThrottled(ctx, list)
+		if c.useCachedList(err, &listOptions) {
+			log.Printf("WARNING: listing Events timed out, listing Events from the API server cache: %v", err)
+			err = c.retryThrottled(ctx, list)
+		}
+		if IsCanceled(ctx, err) {
+			return podEvents, fmt.Errorf("Shutting down: stopped listing Events in namespace: %s: %w", namespace, err)
+		} else if err != nil {
+			return podEvents, fmt.Errorf("Could not get Events in namespace: %s\n%w", namespace, err)
+		}
+
+		for _, item := range eventList.Items {
+			podEventData := newPodEvent(&item)
+			if c.matchesEventFilters(podEventData) && keep(podEventData) {
+				podEvents = append(podEvents, podEventData)
 			}
-			podEvents = append(podEvents, podEventData)
 		}
+
+		if eventList.Continue == "" {
+			break
+		}
+		listOptions.Continue = eventList.Continue
 	}
 	return podEvents, nil
 }
 
+// newPodEvent returns the PodEvent of an Event
+func newPodEvent(item *v1.Event) PodEvent {
+	return PodEvent{
+		UID:             item.InvolvedObject.UID,
+		PodName:         item.InvolvedObject.Name,
+		PodNamespace:    item.InvolvedObject.Namespace,
+		ResourceVersion: item.InvolvedObject.ResourceVersion,
+		Reason:          item.Reason,
+		EventType:       item.Type,
+		Message:         item.Message,
+		SourceComponent: item.Source.Component,
+		FieldPath:       item.InvolvedObject.FieldPath,
+		Count:           item.Count,
+		FirstTimestamp:  item.FirstTimestamp.Time,
+		LastTimestamp:   item.LastTimestamp.Time,
+	}
+}
+
+// errNoPodEvents is returned by getPodEvents for Pods without Events, this is not a match rather than a failure
+var errNoPodEvents = errors.New("Pod has 0 Events")
+
 // getPodEvents returns Pod Events
+// the error wraps errNoPodEvents if the Pod has no Events, or the API error if Events could not be listed
 func (c *kubeClient) getPodEvents(ctx context.Context, pod, namespace string) ([]PodEvent, error) {
 
 	api := c.clientSet.CoreV1()
 
 	var podEvents []PodEvent
+	// Events are namespaced, an empty namespace would list Events of Pods with the same name in every namespace
+	if namespace == "" {
+		return podEvents, fmt.Errorf("Could not go through Pod's Events: %s: namespace is empty", pod)
+	}
 	// get Pod events
-	eventsStruct, err := api.Events(namespace).List(
-		ctx,
-		metav1.ListOptions{
-			FieldSelector: fmt.Sprintf("involvedObject.name=%s", pod),
-			TypeMeta:      metav1.TypeMeta{Kind: "Pod"},
-		})
+	var eventsStruct *v1.EventList
+	err := c.retryThrottled(ctx, func() (err error) {
+		eventsStruct, err = api.Events(namespace).List(
+			ctx,
+			metav1.ListOptions{
+				FieldSelector: c.eventFieldSelector(fmt.Sprintf("involvedObject.name=%s", pod)),
+				TypeMeta:      metav1.TypeMeta{Kind: "Pod"},
+				Limit:         int64(c.opts.MaxEventsPerPod),
+			})
+		return err
+	})
 
-	if err != nil {
-		msg := fmt.Sprintf("Could not go through Pod's Events: %s/%s\n%s", namespace, pod, err)
-		return podEvents, errors.New(msg)
+	if IsCanceled(ctx, err) {
+		return podEvents, fmt.Errorf("Shutting down: stopped listing Pod's Events: %s/%s: %w", namespace, pod, err)
+	} else if err != nil {
+		// errors are wrapped so callers can branch on the API error (eg: e.IsForbidden)
+		return podEvents, fmt.Errorf("Could not go through Pod's Events: %s/%s\n%w", namespace, pod, err)
 	}
 
 	for _, item := range eventsStruct.Items {
-		podEventData := PodEvent{
-			UID:             item.InvolvedObject.UID,
-			PodName:         item.InvolvedObject.Name,
-			PodNamespace:    item.InvolvedObject.Namespace,
-			ResourceVersion: item.InvolvedObject.ResourceVersion,
-			Reason:          item.Reason,
-			EventType:       item.Type,
-			Message:         item.Message,
-			FirstTimestamp:  item.FirstTimestamp.Time,
-			LastTimestamp:   item.LastTimestamp.Time,
+		podEventData := newPodEvent(&item)
+		if !c.matchesEventFilters(podEventData) {
+			continue
 		}
 		podEvents = append(podEvents, podEventData)
 	}
+	if c.opts.DeletionHistory != nil {
+		podEvents = removeEventsBeforeDeletion(podEvents, c.opts.DeletionHistory)
+	}
 
 	if len(podEvents) == 0 {
-		msg := fmt.Sprintf(
-			"Pod has 0 Events. Probably it does not exist or it does not have any events in the last hour: %s/%s",
-			namespace, pod,
+		return podEvents, fmt.Errorf(
+			"%w. Probably it does not exist or it does not have any events in the last hour: %s/%s",
+			errNoPodEvents, namespace, pod,
 		)
-		return podEvents, errors.New(msg)
 	}
 	return podEvents, nil
 }
@@ -177,49 +307,477 @@ func (c *kubeClient) GetPodDetails(ctx context.Context, pod, namespace string) (
 	var podData PodDetails
 	var err error
 
-	item, err = api.Pods(namespace).Get(
-		ctx,
-		pod,
-		metav1.GetOptions{},
-	)
-	if e.IsNotFound(err) {
-		msg := fmt.Sprintf("Pod %s/%s does not exist anymore", namespace, pod)
-		return &podData, errors.New(msg)
-	} else if statusError, isStatus := err.(*e.StatusError); isStatus {
-		msg := fmt.Sprintf("Error getting pod %s/%s: %v",
-			namespace, pod, statusError.ErrStatus.Message)
-		return &podData, errors.New(msg)
+	// Pods are namespaced, callers pass the namespace of the matched Pod, not the scanned namespace (empty for all namespaces)
+	if namespace == "" {
+		return &podData, fmt.Errorf("Could not get Pod %s: namespace is empty", pod)
+	}
+
+	err = c.retryThrottled(ctx, func() (err error) {
+		item, err = api.Pods(namespace).Get(
+			ctx,
+			pod,
+			metav1.GetOptions{},
+		)
+		return err
+	})
+	// errors are wrapped so callers can branch on the API error (eg: e.IsNotFound)
+	if isNamespaceTerminating(err) {
+		return &podData, fmt.Errorf("Skipping Pod %s/%s: namespace is terminating: %w", namespace, pod, err)
+	} else if e.IsNotFound(err) {
+		return &podData, fmt.Errorf("Pod %s/%s does not exist anymore: %w", namespace, pod, err)
+	} else if IsCanceled(ctx, err) {
+		return &podData, fmt.Errorf("Shutting down: stopped getting Pod %s/%s: %w", namespace, pod, err)
+	} else if _, isStatus := err.(*e.StatusError); isStatus {
+		return &podData, fmt.Errorf("Error getting pod %s/%s: %w", namespace, pod, err)
 	} else if err != nil {
-		msg := fmt.Sprintf("Pod %s/%s has a problem: %v", namespace, pod, err)
-		return &podData, errors.New(msg)
-	}
-	podData = PodDetails{
-		UID:               item.ObjectMeta.UID,
-		PodName:           item.ObjectMeta.Name,
-		PodNamespace:      item.ObjectMeta.Namespace,
-		ResourceVersion:   item.ObjectMeta.ResourceVersion,
-		Phase:             item.Status.Phase,
-		ContainerStatuses: item.Status.ContainerStatuses,
-		OwnerReferences:   item.ObjectMeta.OwnerReferences,
-		CreationTimestamp: item.ObjectMeta.CreationTimestamp.Time,
-		DeletionTimestamp: item.ObjectMeta.DeletionTimestamp,
+		return &podData, fmt.Errorf("Pod %s/%s has a problem: %w", namespace, pod, err)
 	}
+	podData = c.podDetails(item)
 	return &podData, nil
 }
 
+// podDetails returns the PodDetails of a Pod, including the MatchJSONPath result
+func (c *kubeClient) podDetails(item *v1.Pod) PodDetails {
+	podData := newPodDetails(item)
+	if c.opts.MatchJSONPath != "" {
+		podData.JSONPathMatch = matchJSONPath(c.opts.MatchJSONPath, item)
+	}
+	return podData
+}
+
+// newPodDetails returns the PodDetails of a Pod
+func newPodDetails(item *v1.Pod) PodDetails {
+	_, mirrorPod := item.ObjectMeta.Annotations[v1.MirrorPodAnnotationKey]
+	return PodDetails{
+		UID:                   item.ObjectMeta.UID,
+		PodName:               item.ObjectMeta.Name,
+		PodNamespace:          item.ObjectMeta.Namespace,
+		ResourceVersion:       item.ObjectMeta.ResourceVersion,
+		Phase:                 item.Status.Phase,
+		ContainerStatuses:     item.Status.ContainerStatuses,
+		InitContainerStatuses: item.Status.InitContainerStatuses,
+		Conditions:            item.Status.Conditions,
+		OwnerReferences:       item.ObjectMeta.OwnerReferences,
+		CreationTimestamp:     item.ObjectMeta.CreationTimestamp.Time,
+		LastChangeTimestamp:   lastChangeTime(item),
+		DeletionTimestamp:     item.ObjectMeta.DeletionTimestamp,
+		Finalizers:            item.ObjectMeta.Finalizers,
+		Priority:              item.Spec.Priority,
+		PriorityClassName:     item.Spec.PriorityClassName,
+		NodeName:              item.Spec.NodeName,
+		Annotations:           item.ObjectMeta.Annotations,
+		MirrorPod:             mirrorPod,
+	}
+}
+
+// lastChangeTime returns the most recent managed field or status condition transition time of a Pod
+// Pods that were never changed after creation return the creation time
+func lastChangeTime(item *v1.Pod) time.Time {
+	last := item.ObjectMeta.CreationTimestamp.Time
+	for _, field := range item.ObjectMeta.ManagedFields {
+		if field.Time != nil && field.Time.Time.After(last) {
+			last = field.Time.Time
+		}
+	}
+	for _, cond := range item.Status.Conditions {
+		if cond.LastTransitionTime.Time.After(last) {
+			last = cond.LastTransitionTime.Time
+		}
+	}
+	return last
+}
+
+// VerifyNodeExists returns error if Node does not exist
+func (c *kubeClient) VerifyNodeExists(ctx context.Context, node string) error {
+	api := c.clientSet.CoreV1()
+
+	err := c.retryThrottled(ctx, func() error {
+		_, err := api.Nodes().Get(ctx, node, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not get Node %s: %w", node, err)
+	}
+	return nil
+}
+
+// getNodeConditions returns the conditions of a Node, Nodes are fetched once per client
+func (c *kubeClient) getNodeConditions(ctx context.Context, node string) ([]v1.NodeCondition, error) {
+	c.nodeConditionsMu.Lock()
+	defer c.nodeConditionsMu.Unlock()
+
+	if conditions, ok := c.nodeConditions[node]; ok {
+		return conditions, nil
+	}
+	if err, ok := c.missingNodes[node]; ok {
+		return nil, err
+	}
+	var item *v1.Node
+	err := c.retryThrottled(ctx, func() (err error) {
+		item, err = c.clientSet.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{})
+		return err
+	})
+	if e.IsNotFound(err) {
+		if c.missingNodes == nil {
+			c.missingNodes = make(map[string]error)
+		}
+		c.missingNodes[node] = fmt.Errorf("Could not get Node %s: %w", node, err)
+		return nil, c.missingNodes[node]
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not get Node %s: %w", node, err)
+	}
+	if c.nodeConditions == nil {
+		c.nodeConditions = make(map[string][]v1.NodeCondition)
+	}
+	c.nodeConditions[node] = item.Status.Conditions
+	return item.Status.Conditions, nil
+}
+
+// nodeExists returns false if a Node does not exist, Nodes are fetched once per client
+func (c *kubeClient) nodeExists(ctx context.Context, node string) (bool, error) {
+	_, err := c.getNodeConditions(ctx, node)
+	if e.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReadyNodeFraction returns the fraction of Nodes with a Ready condition that is True
+// returns 0 if the cluster has no Nodes
+func (c *kubeClient) ReadyNodeFraction(ctx context.Context) (float64, error) {
+	api := c.clientSet.CoreV1()
+
+	var nodes *v1.NodeList
+	err := c.retryThrottled(ctx, func() (err error) {
+		nodes, err = api.Nodes().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Could not get a list of Nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		return 0, nil
+	}
+
+	ready := 0
+	for _, node := range nodes.Items {
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == v1.NodeReady && cond.Status == v1.ConditionTrue {
+				ready++
+				break
+			}
+		}
+	}
+	return float64(ready) / float64(len(nodes.Items)), nil
+}
+
+// ListNamespaces returns the names of all namespaces, sorted
+func (c *kubeClient) ListNamespaces(ctx context.Context) ([]string, error) {
+	api := c.clientSet.CoreV1()
+
+	var namespaces *v1.NamespaceList
+	err := c.retryThrottled(ctx, func() (err error) {
+		namespaces, err = api.Namespaces().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not get a list of namespaces: %w", err)
+	}
+	var names []string
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// getPriorityClassValue returns the value of a PriorityClass
+func (c *kubeClient) getPriorityClassValue(ctx context.Context, name string) (int32, error) {
+	api := c.clientSet.SchedulingV1()
+
+	var priorityClass *schedulingv1.PriorityClass
+	err := c.retryThrottled(ctx, func() (err error) {
+		priorityClass, err = api.PriorityClasses().Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Could not get PriorityClass %s: %w", name, err)
+	}
+	return priorityClass.Value, nil
+}
+
+// verifyDeletionInterval is the time between Pod details checks when verifying a deletion
+const verifyDeletionInterval = 2 * time.Second
+
 // DeletePod deletes a Pod
+// the Pod is deleted only if it passes the decision pipeline of deletionGates, that runs them in order
+// the Pod details fetched by PodChecks are reused, the Pod is fetched only if it was not checked by this client
 func (c *kubeClient) DeletePod(ctx context.Context, pod, namespace string) error {
 	api := c.clientSet.CoreV1()
 
-	err := api.Pods(namespace).Delete(
-		ctx,
-		pod,
-		metav1.DeleteOptions{},
-	)
+	// run the decision pipeline
+	d := &podDeletion{pod: pod, namespace: namespace}
+	if details := c.takeCheckedDetails(namespace, pod); details != nil {
+		d.details, d.fetched = details, true
+	}
+	err := c.runDeletionGates(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	// remember Pod UID, so a replacement Pod with the same name is not mistaken for the deleted one
+	// and the top-level Pod owner, so the workload generating restarts is logged and its deletions tracked
+	var uid types.UID
+	annotateOwner := c.opts.AnnotateOwner || c.opts.ReasonAnnotation != ""
+	var nodeName string
+	if podInfo := d.podDetails(ctx, c); podInfo != nil {
+		uid = podInfo.UID
+		nodeName = podInfo.NodeName
+	}
+	owner := d.podOwner(ctx, c)
+
+	// save Pod manifest before deletion, errors do not block deletion
+	if c.opts.DumpDir != "" {
+		err := c.dumpPod(ctx, pod, namespace)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	// Pods on a Node that does not exist anymore are force deleted, there is no kubelet to confirm their termination
+	deleteOptions := metav1.DeleteOptions{}
+	if c.opts.OrphanedNodePods && nodeName != "" {
+		if exists, err := c.nodeExists(ctx, nodeName); err == nil && !exists {
+			gracePeriod := int64(0)
+			deleteOptions.GracePeriodSeconds = &gracePeriod
+			log.Printf("Force deleting Pod %s/%s, Node %s does not exist anymore", namespace, pod, nodeName)
+		}
+	}
+
+	err = c.retryThrottled(ctx, func() error {
+		return api.Pods(namespace).Delete(
+			ctx,
+			pod,
+			deleteOptions,
+		)
+	})
 	if err != nil {
+		// Pods that are not deleted do not use up the budget nor count toward tripping the circuit breaker
+		if c.opts.DeletionBudget != nil {
+			c.opts.DeletionBudget.Release()
+		}
+		d.release(c)
+	}
+	if isNamespaceTerminating(err) {
+		// nothing to remediate in a namespace that is being torn down
+		return skip(DecisionSkippedNamespaceTerminating, fmt.Errorf("Skipping Pod %s/%s: namespace is terminating", namespace, pod))
+	} else if IsCanceled(ctx, err) {
+		// expected during shutdown, the request might have reached the API server before it was cancelled
+		return skip(DecisionCanceled, fmt.Errorf("Shutting down: deletion of Pod %s/%s was cancelled, the Pod might not be deleted: %w", namespace, pod, err))
+	} else if e.IsConflict(err) {
+		// the Pod changed while it was deleted (eg: a controller is updating it), callers can check it again
+		c.forgetPodChecked(namespace, pod)
+		return fmt.Errorf("Deletion of Pod %s/%s conflicted: %w", namespace, pod, err)
+	} else if err != nil && c.opts.DeleteDryRunCheck {
+		// eg: Pod was deleted or admission policies changed since the dry-run
+		return fmt.Errorf("Deletion of Pod %s/%s failed after server-side dry-run was admitted: %w", namespace, pod, err)
+	} else if err != nil {
+		return err
+	}
+	ownerName := ""
+	if owner != nil {
+		ownerName = owner.String()
+		c.recordDeletedOwner(pod, namespace, ownerName)
+		log.Printf("DELETED Pod %s/%s owned by %s", namespace, pod, ownerName)
+	} else {
+		log.Printf("DELETED Pod %s/%s", namespace, pod)
+	}
+	metrics.PodDeleted(namespace)
+	if category := c.MatchedPodCategory(pod, namespace); category != "" {
+		metrics.PodDeletedWithCategory(namespace, category)
+	}
+	if c.opts.PersistentFailures != nil && owner != nil {
+		c.opts.PersistentFailures.Record(ownerName)
+	}
+	if c.opts.DeletionHistory != nil {
+		c.opts.DeletionHistory.Record(namespace, pod)
+	}
+	if c.opts.TerminatingTracker != nil {
+		c.opts.TerminatingTracker.Record(namespace, pod, uid)
+	}
+
+	// notifications are best effort, errors do not fail the deletion
+	if c.opts.Notifier != nil {
+		err := c.opts.Notifier.Notify(ctx, notify.Event{Namespace: namespace, Pod: pod, Owner: ownerName, Timestamp: c.clock().Now().UTC()})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	// annotating the owner is best effort, errors do not fail the deletion
+	if annotateOwner && owner != nil {
+		err := c.annotateOwner(ctx, owner, c.deletionReason(pod, namespace))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	if c.opts.VerifyDeletion {
+		return c.verifyPodDeleted(ctx, pod, namespace, uid)
+	}
+	return nil
+}
+
+// recordDeletedOwner remembers the top-level owner of a deleted Pod
+func (c *kubeClient) recordDeletedOwner(pod, namespace, owner string) {
+	c.ownersMu.Lock()
+	defer c.ownersMu.Unlock()
+	if c.deletedOwners == nil {
+		c.deletedOwners = make(map[string]string)
+	}
+	c.deletedOwners[namespace+"/"+pod] = owner
+}
+
+// DeletedPodOwner returns the top-level owner of a Pod deleted by this client as Kind/namespace/name
+// empty if the Pod was not deleted by this client or has no owner
+func (c *kubeClient) DeletedPodOwner(pod, namespace string) string {
+	c.ownersMu.Lock()
+	defer c.ownersMu.Unlock()
+	return c.deletedOwners[namespace+"/"+pod]
+}
+
+// recordMatchedEvents remembers the first matching Event of every Pod
+// Pods are matched before they are deleted, so no lock is needed
+func (c *kubeClient) recordMatchedEvents(events []PodEvent) {
+	if c.matchedEvents == nil {
+		c.matchedEvents = make(map[string]PodEvent)
+	}
+	for _, event := range events {
+		key := event.PodNamespace + "/" + event.PodName
+		if _, ok := c.matchedEvents[key]; !ok {
+			c.matchedEvents[key] = event
+		}
+	}
+}
+
+// recordMatchedPodAges remembers the ages of matched Pods, since Pod creation
+// matched Pods that are gone already are not in podCreation and are not recorded
+func (c *kubeClient) recordMatchedPodAges(namespace string, events []PodEvent, podCreation map[types.UID]time.Time) {
+	if c.matchedPodAges == nil {
+		c.matchedPodAges = make(map[string][]time.Duration)
+	}
+	now := c.clock().Now()
+	recorded := make(map[types.UID]bool)
+	for _, event := range events {
+		created, ok := podCreation[event.UID]
+		if !ok || recorded[event.UID] {
+			continue
+		}
+		recorded[event.UID] = true
+		c.matchedPodAges[namespace] = append(c.matchedPodAges[namespace], now.Sub(created))
+	}
+}
+
+// ReportMatchedPodAges sets the matched Pod ages metric from the Pods matched by this client
+// call it once all scanned namespaces are matched, the metric is replaced
+func (c *kubeClient) ReportMatchedPodAges() {
+	metrics.SetMatchedPodAges(c.matchedPodAges)
+}
+
+// deletionReason returns the reason a Pod is deleted for, nil if the Pod was not matched by this client
+// eg: Pods queued in an earlier cycle that do not match anymore
+func (c *kubeClient) deletionReason(pod, namespace string) *deletionReason {
+	event, ok := c.matchedEvents[namespace+"/"+pod]
+	if !ok {
+		return nil
+	}
+	return &deletionReason{
+		Pod:     namespace + "/" + pod,
+		Reason:  event.Reason,
+		Message: event.Message,
+		Time:    c.clock().Now().UTC().Truncate(time.Second),
+	}
+}
+
+// verifyPodDeleted polls Pod details until the Pod is NotFound (or replaced by a Pod with a different UID)
+// returns error if the Pod is still terminating after VerifyDeletionTimeout
+func (c *kubeClient) verifyPodDeleted(ctx context.Context, pod, namespace string, uid types.UID) error {
+	var podInfo *PodDetails
+	gone := func() bool {
+		var err error
+		podInfo, err = c.GetPodDetails(ctx, pod, namespace)
+		if e.IsNotFound(err) {
+			return true
+		} else if err != nil {
+			log.Println(err)
+			return false
+		}
+		return uid != "" && podInfo.UID != uid
+	}
+	if !c.pollUntil(ctx, verifyDeletionInterval, c.opts.VerifyDeletionTimeout, gone) {
+		msg := fmt.Sprintf("Pod %s/%s is stuck terminating after %v", namespace, pod, c.opts.VerifyDeletionTimeout)
+		if podInfo != nil && podInfo.DeletionTimestamp != nil {
+			msg += fmt.Sprintf(" (deletionTimestamp: %v)", podInfo.DeletionTimestamp)
+		}
+		return errors.New(msg)
+	}
+	log.Printf("VERIFIED Pod %s/%s is gone", namespace, pod)
+	return nil
+}
+
+// pollUntil calls done right away and then every interval with the client clock, until it returns true
+// returns false if done did not return true before timeout or ctx is cancelled
+func (c *kubeClient) pollUntil(ctx context.Context, interval, timeout time.Duration, done func() bool) bool {
+	deadline := c.clock().NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := c.clock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if done() {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline.C():
+			return false
+		case <-ticker.C():
+		}
+	}
+}
+
+// dumpPod writes the Pod manifest (spec and status) as JSON to a timestamped file in DumpDir
+func (c *kubeClient) dumpPod(ctx context.Context, pod, namespace string) error {
+	api := c.clientSet.CoreV1()
+
+	var item *v1.Pod
+	err := c.retryThrottled(ctx, func() (err error) {
+		item, err = api.Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
 		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not get Pod %s/%s manifest: %w", namespace, pod, err)
+	}
+
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not serialize Pod %s/%s manifest: %w", namespace, pod, err)
 	}
-	log.Printf("DELETED Pod %s/%s", namespace, pod)
+
+	err = os.MkdirAll(c.opts.DumpDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("Could not create dump directory %s: %w", c.opts.DumpDir, err)
+	}
+
+	fileName := fmt.Sprintf("%s_%s_%s.json", namespace, pod, c.clock().Now().UTC().Format("20060102T150405Z"))
+	filePath := filepath.Join(c.opts.DumpDir, fileName)
+	err = os.WriteFile(filePath, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("Could not write Pod %s/%s manifest: %w", namespace, pod, err)
+	}
+	log.Printf("Saved Pod %s/%s manifest to %s", namespace, pod, filePath)
 	return nil
 }
 
@@ -228,25 +786,235 @@ func (c *kubeClient) GenerateToBeDeletedPodList(ctx context.Context, namespace,
 
 	var uniquePodList = make(map[string]string)
 
-	// get a list of Events that match Reason
-	eventList, err := c.GetEvents(ctx, namespace, eventReason, errorMessage)
+	eventList, err := c.getMatchingEvents(ctx, namespace, eventReason, errorMessage, counter, pollingInterval)
 	if err != nil {
 		return uniquePodList, err
 	}
 
+	// generate a unique list of Pods that match Event Reason
+	// we do this because a Pod might have multiple Events with the same Reason
+	uniquePodList = getUniqueListOfPods(eventList)
+
+	// remember the Events Pods were matched for, so the deletion reason can be recorded
+	if c.opts.ReasonAnnotation != "" {
+		c.recordMatchedEvents(eventList)
+	}
+
+	// why Pods are deleted, as extracted by the ErrorRegex reason group
+	if HasCategory(c.opts.ErrorRegex) {
+		c.recordMatchedCategories(eventList)
+	}
+
+	log.Printf("There is a total of %d Pods with Reason: %s", len(uniquePodList), eventReason) // DEBUG
+
+	return uniquePodList, nil
+}
+
+// getMatchingEvents returns the Events of Pods that match Event Reason and Error Message
+// the ages of the matched Pods are recorded, Pods are listed at most once for all steps that need them
+func (c *kubeClient) getMatchingEvents(ctx context.Context, namespace, eventReason, errorMessage string, counter, pollingInterval int) ([]PodEvent, error) {
+	pods := &namespacePods{namespace: namespace}
+
+	// get a list of Events that match Reason
+	// or, if ErrorMessagesAll is set, Events of Pods that have all messages
+	var eventList []PodEvent
+	var err error
+	if len(c.opts.ErrorMessagesAll) > 0 {
+		eventList, err = c.listEvents(ctx, namespace, func(event PodEvent) bool {
+			return containsAny(event.Message, c.opts.ErrorMessagesAll, c.opts.CaseInsensitive)
+		})
+		eventList = filterPodsMatchingAllMessages(eventList, c.opts.ErrorMessagesAll, c.opts.CaseInsensitive)
+	} else {
+		eventList, err = c.GetEvents(ctx, namespace, eventReason, errorMessage)
+	}
+	// the Events synthesized from Pod status go through the same filters as listed Events
+	statusFallback := false
+	if e.IsForbidden(err) {
+		eventsForbiddenWarning.Do(func() {
+			log.Printf(
+				"WARNING: listing Events is forbidden, grant the ServiceAccount get/list/watch on events. Status fallback enabled: %t",
+				c.opts.StatusFallback,
+			)
+		})
+		if !c.opts.StatusFallback {
+			return nil, err
+		}
+		statusFallback = true
+		eventList, err = c.getStatusMatchingEvents(ctx, pods, eventReason, errorMessage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// match Events of the owning controllers of Pods, eg: ReplicaSet FailedCreate Events
+	// owner Events are Events too, with the status fallback listing them is expected to be forbidden as well
+	if c.opts.CheckOwnerEvents {
+		ownerEventList, err := c.getOwnerMatchingEvents(ctx, pods, eventReason, errorMessage)
+		if statusFallback && e.IsForbidden(err) {
+			log.Printf("WARNING: %v", err)
+		} else if err != nil {
+			return nil, err
+		}
+		eventList = append(eventList, ownerEventList...)
+	}
+
+	// match Pods with the matchers, eg: Pods unschedulable for too long, in addition to Events or together with Events
+	if matchers := c.matchers(); len(matchers) > 0 {
+		eventList, err = c.getMatcherEvents(ctx, pods, eventList, matchers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Filter out Events that are older than polling interval
-	eventMaxAge := time.Now().Add(-time.Duration(pollingInterval) * time.Second)
+	eventMaxAge := c.clock().Now().Add(-time.Duration(pollingInterval) * time.Second)
 	if counter > 0 {
 		eventList = removeOlderEvents(eventList, eventMaxAge)
 	}
 
+	// Events from before a Pod was last deleted are about the deleted Pod, not its replacement with the same name
+	if c.opts.DeletionHistory != nil {
+		eventList = removeEventsBeforeDeletion(eventList, c.opts.DeletionHistory)
+	}
+
+	// a single Event might be a blip, the same error recurring many times is a stuck Pod
+	if c.opts.MinMatchCount > 1 {
+		eventList = removeInfrequentPods(eventList, c.opts.MinMatchCount)
+	}
+
+	// Pod creation times are listed once for the filters below and the matched Pod ages
+	var podCreation map[types.UID]time.Time
+	if len(eventList) > 0 {
+		podCreation, err = pods.creationTimes(ctx, c)
+		if err != nil && (c.opts.MinEventOffset > 0 || !c.opts.IgnoreCreatedBefore.IsZero()) {
+			return nil, err
+		} else if err != nil {
+			log.Printf("WARNING: Could not list Pods to record matched Pod ages: %v", err)
+		}
+	}
+
+	// ignore errors that happened shortly after Pod creation (transient startup noise)
+	if c.opts.MinEventOffset > 0 && len(eventList) > 0 {
+		eventList = removeEarlyEvents(eventList, podCreation, c.opts.MinEventOffset)
+	}
+
+	// ignore Pods that already existed when pod-restarter started, they might be provisioning legitimately
+	if !c.opts.IgnoreCreatedBefore.IsZero() && len(eventList) > 0 {
+		var ignored int
+		eventList, ignored = removePreexistingPods(eventList, podCreation, c.opts.IgnoreCreatedBefore)
+		if ignored > 0 {
+			log.Printf("Ignoring %d Pods created before %s", ignored, c.opts.IgnoreCreatedBefore.Format(time.RFC3339))
+		}
+	}
+
+	// exclude Pods with matching Events that contain a message to ignore
+	if len(c.opts.IgnoreMessages) > 0 {
+		eventList = removeIgnoredPods(eventList, c.opts.IgnoreMessages, c.opts.CaseInsensitive)
+	}
+
+	// how long matched Pods have been waiting, reported once all namespaces are matched
+	c.recordMatchedPodAges(namespace, eventList, podCreation)
+
 	log.Printf("There is a total of %d Events with Reason: %s", len(eventList), eventReason) // DEBUG
 
-	// generate a unique list of Pods that match Event Reason
-	// we do this because a Pod might have multiple Events with the same Reason
-	uniquePodList = getUniqueListOfPods(eventList)
+	return eventList, nil
+}
 
-	log.Printf("There is a total of %d Pods with Reason: %s", len(uniquePodList), eventReason) // DEBUG
+// matchers returns the matchers Pods are matched with, in addition to Events matching Reason and Message
+// the built-in matchers enabled in the options are used, unless Matchers is set
+func (c *kubeClient) matchers() []Matcher {
+	if c.opts.Matchers != nil {
+		return c.opts.Matchers
+	}
+	return PodMatchers(c.opts)
+}
 
-	return uniquePodList, nil
+// getMatcherEvents returns the Events of the Pods matched with matchers
+// by default Pods are matched by their Events or by any of the matchers, with MatchAll by their Events and by all the matchers
+// Pods matched by a matcher get an Event with the matcher reason as message, timestamped now because the Pod matches now
+func (c *kubeClient) getMatcherEvents(ctx context.Context, pods *namespacePods, eventList []PodEvent, matchers []Matcher) ([]PodEvent, error) {
+
+	podList, err := pods.list(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	podEvents := make(map[types.UID][]PodEvent)
+	for _, event := range eventList {
+		podEvents[event.UID] = append(podEvents[event.UID], event)
+	}
+
+	matcher := AnyOf(matchers...)
+	var matchedEvents []PodEvent
+	if c.opts.MatchAll {
+		matcher = AllOf(append([]Matcher{EventMatcher{}}, matchers...)...)
+	} else {
+		// Pods matched by their Events are matched, even if they are not listed anymore
+		matchedEvents = eventList
+	}
+
+	matched := 0
+	for _, pod := range *podList {
+		// matchers do not call the API, Nodes are looked up once per client
+		if c.opts.OrphanedNodePods && pod.NodeName != "" {
+			exists, err := c.nodeExists(ctx, pod.NodeName)
+			if err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+			pod.NodeMissing = err == nil && !exists
+		}
+		ok, reason := matcher.Matches(&pod, podEvents[pod.UID])
+		if !ok {
+			continue
+		}
+		matched++
+		if c.opts.MatchAll {
+			matchedEvents = append(matchedEvents, podEvents[pod.UID]...)
+			continue
+		}
+		matchedEvents = append(matchedEvents, PodEvent{
+			UID:            pod.UID,
+			PodName:        pod.PodName,
+			PodNamespace:   pod.PodNamespace,
+			Reason:         matcherEventReason,
+			Message:        reason,
+			LastTimestamp:  c.clock().Now(),
+			FirstTimestamp: pod.CreationTimestamp,
+		})
+	}
+
+	log.Printf("There is a total of %d Pods matched by %d matchers", matched, len(matchers)) // DEBUG
+
+	return matchedEvents, nil
+}
+
+// getStatusMatchingEvents returns one Event for every Pod with a container state or condition that matches Reason and Error Message
+// this is used instead of Events when the ServiceAccount is not allowed to list Events
+// the Events are timestamped now because the Pod matches now, like the Events of Pods matched by a matcher
+func (c *kubeClient) getStatusMatchingEvents(ctx context.Context, pods *namespacePods, eventReason, errorMessage string) ([]PodEvent, error) {
+
+	var eventList []PodEvent
+
+	podList, err := pods.list(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range *podList {
+		if message, ok := pod.matchesStatus(eventReason, func(message string) bool { return c.matchesMessage(message, errorMessage) }); ok {
+			eventList = append(eventList, PodEvent{
+				UID:            pod.UID,
+				PodName:        pod.PodName,
+				PodNamespace:   pod.PodNamespace,
+				Reason:         eventReason,
+				Message:        message,
+				LastTimestamp:  c.clock().Now(),
+				FirstTimestamp: pod.CreationTimestamp,
+			})
+		}
+	}
+
+	log.Printf("There is a total of %d Pods with status Reason: %s", len(eventList), eventReason) // DEBUG
+
+	return eventList, nil
 }
@@ -2,14 +2,29 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
 func TestDeletePod(t *testing.T) {
@@ -291,3 +306,1254 @@ func TestGenerateToBeDeletedPodList(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateToBeDeletedPodListEventsForbidden(t *testing.T) {
+	testCases := []struct {
+		testName              string
+		mockedPods            []runtime.Object
+		statusFallback        bool
+		expectSuccess         bool
+		expectedUniquePodList int
+	}{
+		// Listing Events is forbidden and status fallback is disabled
+		{
+			testName: "Return error when Events are forbidden and fallback is disabled",
+			mockedPods: []runtime.Object{
+				makeWaitingPod("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists"),
+			},
+			statusFallback:        false,
+			expectSuccess:         false,
+			expectedUniquePodList: 0,
+		},
+		// Listing Events is forbidden and status fallback is enabled
+		// 1 Pod will match Reason and Message in its container state
+		{
+			testName: "Match Pod status when Events are forbidden and fallback is enabled",
+			mockedPods: []runtime.Object{
+				makeWaitingPod("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists"),
+				makeWaitingPod("pod_2", "default", "ImagePullBackOff", "Back-off pulling image"),
+				makePod("pod_3", "default", 1, corev1.PodRunning, "uid3"),
+			},
+			statusFallback:        true,
+			expectSuccess:         true,
+			expectedUniquePodList: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clientSet := fake.NewSimpleClientset(test.mockedPods...)
+			clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("no RBAC"))
+			})
+			clt.clientSet = clientSet
+			clt.opts.StatusFallback = test.statusFallback
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(
+				ctx,
+				"",
+				"FailedCreatePodSandBox",
+				"container veth name provided (eth0) already exists",
+				0,
+				10,
+			)
+
+			if test.expectSuccess {
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedUniquePodList, len(uniquePodList))
+			} else {
+				assert.True(t, apierrors.IsForbidden(err))
+			}
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListStatusFallbackFilters(t *testing.T) {
+	var ctx = context.TODO()
+	clientSet := fake.NewSimpleClientset(
+		makeWaitingPod("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists"),
+		makeWaitingPod("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth1) already exists"),
+		makeWaitingPod("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists"),
+	)
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("no RBAC"))
+	})
+	history := NewDeletionHistory(clock.RealClock{})
+	clt := kubeClient{clientSet: clientSet, opts: Options{
+		StatusFallback:   true,
+		CheckOwnerEvents: true,
+		IgnoreMessages:   []string{"(eth1)"},
+		DeletionHistory:  history,
+	}}
+	// pod_3 was deleted after it was created, it is still terminating
+	history.Record("default", "pod_3")
+
+	// Pods matched by their status go through the Event filters, and are not dropped by the polling interval age filter
+	uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "already exists", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default"}, uniquePodList)
+}
+
+func TestGenerateToBeDeletedPodListAllMessages(t *testing.T) {
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid1"),
+		makeEvent("pod_1", "default", "FailedMount", "volume node affinity conflict", "Warning", 2, "uid1"),
+		makeEvent("pod_2", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid2"),
+		makeEvent("pod_3", "test", "FailedMount", "volume node affinity conflict", "Warning", 1, "uid3"),
+	}
+
+	testCases := []struct {
+		testName              string
+		eventReason           string
+		eventMessage          string
+		errorMessagesAll      []string
+		expectedUniquePodList int
+	}{
+		// any Pod with an Event that matches Reason and Message
+		{
+			testName:              "Match Pods with a single Event that matches Reason and Message",
+			eventReason:           "FailedScheduling",
+			eventMessage:          "0/3 nodes are available",
+			expectedUniquePodList: 2,
+		},
+		// only Pods with Events that have all messages
+		{
+			testName:              "Match Pods with Events that have all messages",
+			errorMessagesAll:      []string{"0/3 nodes are available", "volume node affinity conflict"},
+			expectedUniquePodList: 1,
+		},
+		// no Pod has Events with all messages
+		{
+			testName:              "Match no Pods when a message does not appear in any Event",
+			errorMessagesAll:      []string{"0/3 nodes are available", "not found"},
+			expectedUniquePodList: 0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.ErrorMessagesAll = test.errorMessagesAll
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(
+				ctx,
+				"",
+				test.eventReason,
+				test.eventMessage,
+				0,
+				10,
+			)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUniquePodList, len(uniquePodList))
+		})
+	}
+}
+
+func TestDeletePodDumpDir(t *testing.T) {
+	var clt kubeClient
+	var ctx = context.TODO()
+	dumpDir := filepath.Join(t.TempDir(), "dumps")
+	clt.clientSet = fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+	clt.opts.DumpDir = dumpDir
+
+	err := clt.DeletePod(ctx, "foo", "default")
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dumpDir, "default_foo_*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	var pod corev1.Pod
+	require.NoError(t, json.Unmarshal(data, &pod))
+	assert.Equal(t, "foo", pod.Name)
+	assert.Equal(t, corev1.PodPending, pod.Status.Phase)
+}
+
+func TestGenerateToBeDeletedPodListPagination(t *testing.T) {
+	// the fake clientset does not paginate or record Limit/Continue, so Events are served in pages by a reactor
+	pages := [][]corev1.Event{
+		{
+			*makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+			*makeEvent("pod_2", "default", "Scheduled", "Successfully assigned pod to kublet.node1", "Normal", 1, "uid2"),
+		},
+		{
+			*makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 2, "uid1"),
+			*makeEvent("pod_3", "test", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid3"),
+		},
+	}
+
+	var clt kubeClient
+	var ctx = context.TODO()
+	var listCalls int
+	clientSet := fake.NewSimpleClientset()
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		page := listCalls
+		listCalls++
+		eventList := &corev1.EventList{Items: pages[page]}
+		if page < len(pages)-1 {
+			eventList.Continue = fmt.Sprintf("page-%d", page+1)
+		}
+		return true, eventList, nil
+	})
+	clt.clientSet = clientSet
+	clt.opts.ListPageSize = 2
+
+	uniquePodList, err := clt.GenerateToBeDeletedPodList(
+		ctx,
+		"",
+		"FailedCreatePodSandBox",
+		"container veth name provided (eth0) already exists",
+		0,
+		10,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, listCalls)
+	assert.Equal(t, map[string]string{"pod_1": "default", "pod_3": "test"}, uniquePodList)
+}
+
+func TestDeletePodVerifyDeletion(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		stuckPod      bool
+		expectSuccess bool
+	}{
+		// Pod is gone right after deletion
+		{
+			testName:      "Verify deleted Pod is gone",
+			stuckPod:      false,
+			expectSuccess: true,
+		},
+		// Pod is stuck terminating, eg: because of finalizers
+		{
+			testName:      "Verify deleted Pod is stuck terminating",
+			stuckPod:      true,
+			expectSuccess: false,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clientSet := fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+			if test.stuckPod {
+				// accept the delete call without removing the Pod
+				clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			}
+			gets := 0
+			clientSet.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				gets++
+				return false, nil, nil
+			})
+			fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+			clt.clientSet = clientSet
+			clt.opts.VerifyDeletion = true
+			clt.opts.VerifyDeletionTimeout = 10 * time.Second
+			clt.opts.Clock = fakeClock
+
+			// the Pod is checked every verifyDeletionInterval of the client clock
+			var err error
+			start := time.Now()
+			runWithFakeClock(t, fakeClock, func() { err = clt.DeletePod(ctx, "foo", "default") })
+			assert.Less(t, time.Since(start), 5*time.Second)
+			if test.expectSuccess {
+				require.NoError(t, err)
+				assert.Equal(t, 2, gets)
+			} else {
+				assert.EqualError(t, err, "Pod default/foo is stuck terminating after 10s")
+				assert.GreaterOrEqual(t, gets, 5)
+			}
+		})
+	}
+}
+
+func TestGetEventsSource(t *testing.T) {
+	schedulerEvent := makeEvent("pod_2", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid2")
+	schedulerEvent.Source.Component = "default-scheduler"
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid1"),
+		schedulerEvent,
+	}
+
+	testCases := []struct {
+		testName       string
+		eventSource    string
+		expectedEvents int
+	}{
+		{
+			testName:       "Match Events from all sources",
+			eventSource:    "",
+			expectedEvents: 2,
+		},
+		{
+			testName:       "Match only Events from the scheduler",
+			eventSource:    "default-scheduler",
+			expectedEvents: 1,
+		},
+		{
+			testName:       "Match no Events from an unknown source",
+			eventSource:    "cni",
+			expectedEvents: 0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.EventSource = test.eventSource
+
+			podEvents, err := clt.GetEvents(ctx, "default", "FailedScheduling", "0/3 nodes are available")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEvents, len(podEvents))
+		})
+	}
+}
+
+func TestGetEventsTargetContainer(t *testing.T) {
+	sidecarEvent := makeEvent("pod_2", "default", "BackOff", "Back-off restarting failed container", "Warning", 1, "uid2")
+	sidecarEvent.InvolvedObject.FieldPath = "spec.containers{sidecar}"
+	podEvent := makeEvent("pod_3", "default", "BackOff", "Back-off restarting failed container", "Warning", 1, "uid3")
+	podEvent.InvolvedObject.FieldPath = ""
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "BackOff", "Back-off restarting failed container", "Warning", 1, "uid1"),
+		sidecarEvent,
+		podEvent,
+	}
+
+	testCases := []struct {
+		testName        string
+		targetContainer string
+		expectedPods    []string
+	}{
+		{
+			testName:        "Match Events of all containers and Pod level Events",
+			targetContainer: "",
+			expectedPods:    []string{"pod_1", "pod_2", "pod_3"},
+		},
+		{
+			testName:        "Match only Events of the sidecar container",
+			targetContainer: "sidecar",
+			expectedPods:    []string{"pod_2"},
+		},
+		{
+			testName:        "Match no Events of an unknown container",
+			targetContainer: "unknown",
+			expectedPods:    nil,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.TargetContainer = test.targetContainer
+
+			podEvents, err := clt.GetEvents(ctx, "default", "BackOff", "Back-off restarting failed container")
+			require.NoError(t, err)
+			var pods []string
+			for _, event := range podEvents {
+				pods = append(pods, event.PodName)
+			}
+			assert.ElementsMatch(t, test.expectedPods, pods)
+		})
+	}
+
+	// Events of a single Pod are filtered the same way
+	var ctx = context.TODO()
+	clt := kubeClient{clientSet: fake.NewSimpleClientset(podEvent), opts: Options{TargetContainer: "sidecar"}}
+	_, err := clt.getPodEvents(ctx, "pod_3", "default")
+	assert.ErrorIs(t, err, errNoPodEvents, "Pod level Events should not be matched with a target container")
+}
+
+func TestGetPodEventsErrors(t *testing.T) {
+	var ctx = context.TODO()
+
+	// a Pod without Events is not a match
+	clt := kubeClient{clientSet: fake.NewSimpleClientset()}
+	podEvents, err := clt.getPodEvents(ctx, "foo", "default")
+	assert.Empty(t, podEvents)
+	assert.ErrorIs(t, err, errNoPodEvents)
+	assert.EqualError(t, err, "Pod has 0 Events. Probably it does not exist or it does not have any events in the last hour: default/foo")
+
+	// API errors are wrapped, so callers can branch on them
+	clientSet := fake.NewSimpleClientset()
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("RBAC"))
+	})
+	clt = kubeClient{clientSet: clientSet}
+	_, err = clt.getPodEvents(ctx, "foo", "default")
+	assert.True(t, apierrors.IsForbidden(err), err)
+	assert.NotErrorIs(t, err, errNoPodEvents)
+}
+
+func TestGetEventsType(t *testing.T) {
+	schedulerEvent := makeEvent("pod_2", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid2")
+	schedulerEvent.Source.Component = "default-scheduler"
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "FailedScheduling", "0/3 nodes are available", "Normal", 1, "uid1"),
+		makeEvent("pod_3", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid3"),
+		schedulerEvent,
+	}
+
+	testCases := []struct {
+		testName              string
+		eventType             string
+		eventSource           string
+		expectedEvents        int
+		expectedFieldSelector string
+	}{
+		{
+			testName:       "Match Events of all types",
+			expectedEvents: 3,
+		},
+		{
+			testName:              "Match only Warning Events",
+			eventType:             "Warning",
+			expectedEvents:        2,
+			expectedFieldSelector: "type=Warning",
+		},
+		{
+			testName:              "Match only Warning Events from the scheduler",
+			eventType:             "Warning",
+			eventSource:           "default-scheduler",
+			expectedEvents:        1,
+			expectedFieldSelector: "source=default-scheduler,type=Warning",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clientSet := fake.NewSimpleClientset(mockedEvents...)
+			var fieldSelector string
+			clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				fieldSelector = action.(k8stesting.ListActionImpl).ListRestrictions.Fields.String()
+				return false, nil, nil
+			})
+			clt.clientSet = clientSet
+			clt.opts.EventType = test.eventType
+			clt.opts.EventSource = test.eventSource
+
+			// the fake clientset ignores field selectors, Events are also filtered client-side
+			podEvents, err := clt.GetEvents(ctx, "default", "FailedScheduling", "0/3 nodes are available")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEvents, len(podEvents))
+			assert.Equal(t, test.expectedFieldSelector, fieldSelector)
+		})
+	}
+}
+
+func TestDeletePodNamespaceTerminating(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		deleteErr        error
+		expectedDecision Decision
+	}{
+		// 409 namespace is terminating is benign
+		{
+			testName: "Skip Pod in terminating namespace",
+			deleteErr: &apierrors.StatusError{ErrStatus: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    409,
+				Reason:  metav1.StatusReasonConflict,
+				Message: "Operation cannot be fulfilled on namespaces \"default\": namespace default is being terminated",
+				Details: &metav1.StatusDetails{
+					Causes: []metav1.StatusCause{{Type: corev1.NamespaceTerminatingCause}},
+				},
+			}},
+			expectedDecision: DecisionSkippedNamespaceTerminating,
+		},
+		// namespace is already gone
+		{
+			testName:         "Skip Pod in deleted namespace",
+			deleteErr:        apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "default"),
+			expectedDecision: DecisionSkippedNamespaceTerminating,
+		},
+		// other errors still surface
+		{
+			testName:         "Return error for other conflicts",
+			deleteErr:        apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "foo", errors.New("object has been modified")),
+			expectedDecision: DecisionError,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clientSet := fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+			clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, test.deleteErr
+			})
+			clt.clientSet = clientSet
+
+			err := clt.DeletePod(ctx, "foo", "default")
+			require.Error(t, err)
+			assert.Equal(t, test.expectedDecision, DecisionOf(err))
+		})
+	}
+}
+
+func TestGetEventsCaseInsensitive(t *testing.T) {
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "FailedCreatePodSandBox", "Container veth name provided (eth0) already exists ....", "Warning", 1, "uid1"),
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists ....", "Warning", 1, "uid2"),
+		makeEvent("pod_3", "default", "FailedCreatePodSandBox", "CONTAINER VETH NAME PROVIDED (ETH0) ALREADY EXISTS", "Warning", 1, "uid3"),
+	}
+
+	testCases := []struct {
+		testName        string
+		caseInsensitive bool
+		expectedEvents  int
+	}{
+		{
+			testName:        "Match mixed-case messages case-sensitively",
+			caseInsensitive: false,
+			expectedEvents:  1,
+		},
+		{
+			testName:        "Match mixed-case messages case-insensitively",
+			caseInsensitive: true,
+			expectedEvents:  3,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.CaseInsensitive = test.caseInsensitive
+
+			podEvents, err := clt.GetEvents(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEvents, len(podEvents))
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListIgnoreMessages(t *testing.T) {
+	mockedEvents := []runtime.Object{
+		makeEvent("pod_1", "default", "Failed", "Failed to pull image \"nginx\": toomanyrequests", "Warning", 1, "uid1"),
+		makeEvent("pod_1", "default", "Failed", "Failed to pull image \"nginx\": not found", "Warning", 2, "uid1"),
+		makeEvent("pod_2", "default", "Failed", "Failed to pull image \"wrongimage\": not found", "Warning", 1, "uid2"),
+		makeEvent("pod_3", "default", "Failed", "Failed to pull image \"busybox\": TooManyRequests", "Warning", 1, "uid3"),
+	}
+
+	testCases := []struct {
+		testName              string
+		ignoreMessages        []string
+		caseInsensitive       bool
+		expectedUniquePodList map[string]string
+	}{
+		{
+			testName:              "Match all Pods without ignored messages",
+			ignoreMessages:        nil,
+			expectedUniquePodList: map[string]string{"pod_1": "default", "pod_2": "default", "pod_3": "default"},
+		},
+		{
+			testName:              "Exclude Pods with a matching Event that contains an ignored message",
+			ignoreMessages:        []string{"toomanyrequests"},
+			expectedUniquePodList: map[string]string{"pod_2": "default", "pod_3": "default"},
+		},
+		{
+			testName:              "Exclude Pods with an ignored message case-insensitively",
+			ignoreMessages:        []string{"toomanyrequests"},
+			caseInsensitive:       true,
+			expectedUniquePodList: map[string]string{"pod_2": "default"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.IgnoreMessages = test.ignoreMessages
+			clt.opts.CaseInsensitive = test.caseInsensitive
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "Failed", "Failed to pull image", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUniquePodList, uniquePodList)
+		})
+	}
+}
+
+func TestGetEventsMaxEventsPerPod(t *testing.T) {
+	var mockedEvents []runtime.Object
+	for i := 0; i < 5; i++ {
+		mockedEvents = append(mockedEvents,
+			makeEvent("pod_1", "default", "FailedScheduling", "0/3 nodes are available", "Warning", i, "uid1"),
+		)
+	}
+	mockedEvents = append(mockedEvents,
+		makeEvent("pod_2", "default", "FailedScheduling", "0/3 nodes are available", "Warning", 1, "uid2"),
+	)
+
+	testCases := []struct {
+		testName              string
+		maxEventsPerPod       int
+		expectedEvents        int
+		expectedUniquePodList int
+	}{
+		{
+			testName:              "Keep all Events without limit",
+			maxEventsPerPod:       0,
+			expectedEvents:        6,
+			expectedUniquePodList: 2,
+		},
+		{
+			testName:              "Keep one Event per Pod with limit",
+			maxEventsPerPod:       1,
+			expectedEvents:        2,
+			expectedUniquePodList: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedEvents...)
+			clt.opts.MaxEventsPerPod = test.maxEventsPerPod
+
+			podEvents, err := clt.GetEvents(ctx, "default", "FailedScheduling", "0/3 nodes are available")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedEvents, len(podEvents))
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedScheduling", "0/3 nodes are available", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedUniquePodList, len(uniquePodList))
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListMinEventOffset(t *testing.T) {
+	// pod_1 failed long after creation, pod_2 failed right after creation, pod_3 does not exist anymore
+	oldPod := makePod("pod_1", "default", 1, corev1.PodPending, "uid1")
+	oldPod.ObjectMeta.CreationTimestamp = metav1.Time{Time: time.Now().Add(-10 * time.Minute)}
+	mockedObjects := []runtime.Object{
+		oldPod,
+		makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+		makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+		makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid3"),
+	}
+
+	testCases := []struct {
+		testName        string
+		minEventOffset  time.Duration
+		expectedPodList map[string]string
+	}{
+		{
+			testName:        "Keep all Pods without offset",
+			minEventOffset:  0,
+			expectedPodList: map[string]string{"pod_1": "default", "pod_2": "default", "pod_3": "default"},
+		},
+		{
+			testName:        "Keep only Pods that failed at least offset after creation",
+			minEventOffset:  5 * time.Minute,
+			expectedPodList: map[string]string{"pod_1": "default"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedObjects...)
+			clt.opts.MinEventOffset = test.minEventOffset
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPodList, uniquePodList)
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListMinMatchCount(t *testing.T) {
+	// pod_1 has a single Event that occurred 5 times, pod_2 has 3 Events, pod_3 has a single Event that occurred once
+	recurringEvent := makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1")
+	recurringEvent.Count = 5
+	mockedObjects := []runtime.Object{
+		recurringEvent,
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+		makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid3"),
+	}
+
+	testCases := []struct {
+		testName        string
+		minMatchCount   int32
+		expectedPodList map[string]string
+	}{
+		{
+			testName:        "Keep all Pods without min match count",
+			minMatchCount:   0,
+			expectedPodList: map[string]string{"pod_1": "default", "pod_2": "default", "pod_3": "default"},
+		},
+		{
+			testName:        "Keep Pods with repeated Events or an Event count at the threshold",
+			minMatchCount:   3,
+			expectedPodList: map[string]string{"pod_1": "default", "pod_2": "default"},
+		},
+		{
+			testName:        "Keep only Pods with an Event count above the repeated Events",
+			minMatchCount:   4,
+			expectedPodList: map[string]string{"pod_1": "default"},
+		},
+		{
+			testName:        "Remove Pods below the threshold",
+			minMatchCount:   6,
+			expectedPodList: map[string]string{},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(mockedObjects...)
+			clt.opts.MinMatchCount = test.minMatchCount
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPodList, uniquePodList)
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListMinMatchCountMatchers(t *testing.T) {
+	var ctx = context.TODO()
+	// pod_1 is matched by the unschedulable timeout matcher only, pod_2 has a single Event, pod_3 has an Event that occurred 5 times
+	stuck := makeOwnedPod("pod_1", "default", corev1.PodPending, nil)
+	stuck.Status.Conditions = []corev1.PodCondition{{
+		Type:               corev1.PodScheduled,
+		Status:             corev1.ConditionFalse,
+		Reason:             corev1.PodReasonUnschedulable,
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}}
+	recurringEvent := makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid3")
+	recurringEvent.Count = 5
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			stuck,
+			makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+			recurringEvent,
+		),
+		opts: Options{UnschedulableTimeout: 15 * time.Minute, MinMatchCount: 2},
+	}
+
+	// the min match count applies to Events only, Pods matched by a matcher are kept
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default", "pod_3": "default"}, uniquePodList)
+}
+
+func TestGenerateToBeDeletedPodListIgnoreCreatedBefore(t *testing.T) {
+	var ctx = context.TODO()
+	started := time.Now().Add(-time.Minute)
+	// pod_1 existed before pod-restarter started, pod_2 was created after, pod_3 does not exist anymore
+	preexistingPod := makePod("pod_1", "default", 1, corev1.PodPending, "uid1")
+	preexistingPod.ObjectMeta.CreationTimestamp = metav1.Time{Time: started.Add(-time.Hour)}
+	clt := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			preexistingPod,
+			makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+			makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+			makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+			makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+			makeEvent("pod_3", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid3"),
+		),
+		opts: Options{IgnoreCreatedBefore: started},
+	}
+
+	uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_2": "default"}, uniquePodList)
+}
+
+func TestRemovePreexistingPods(t *testing.T) {
+	started := time.Now()
+	podCreation := map[types.UID]time.Time{
+		"uid1": started.Add(-time.Hour),
+		"uid2": started.Add(time.Second),
+	}
+	events := []PodEvent{{UID: "uid1"}, {UID: "uid1"}, {UID: "uid2"}, {UID: "uid3"}}
+
+	remaining, ignored := removePreexistingPods(events, podCreation, started)
+	assert.Equal(t, []PodEvent{{UID: "uid2"}}, remaining)
+	assert.Equal(t, 1, ignored, "pre-existing Pods are counted once, Pods that do not exist anymore are not counted")
+}
+
+func TestDeletePodDryRunCheck(t *testing.T) {
+	webhookErr := apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "foo", errors.New("admission webhook denied the request"))
+
+	testCases := []struct {
+		testName        string
+		rejectDryRun    bool
+		rejectDelete    bool
+		expectedErr     string
+		expectedDeletes int
+	}{
+		{
+			testName:        "Verify Pod is deleted when dry-run is admitted",
+			expectedDeletes: 2,
+		},
+		{
+			testName:        "Verify Pod is not deleted when dry-run is rejected",
+			rejectDryRun:    true,
+			expectedErr:     "Server-side dry-run deletion of Pod default/foo was rejected: " + webhookErr.Error(),
+			expectedDeletes: 1,
+		},
+		{
+			testName:        "Verify error when delete fails after dry-run is admitted",
+			rejectDelete:    true,
+			expectedErr:     "Deletion of Pod default/foo failed after server-side dry-run was admitted: " + webhookErr.Error(),
+			expectedDeletes: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clientSet := fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+			deletes := 0
+			// the fake clientset ignores DryRun, dry-run deletions are handled here
+			clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				deletes++
+				dryRun := len(action.(k8stesting.DeleteActionImpl).DeleteOptions.DryRun) > 0
+				if dryRun && test.rejectDryRun || !dryRun && test.rejectDelete {
+					return true, nil, webhookErr
+				}
+				return dryRun, nil, nil
+			})
+			clt.clientSet = clientSet
+			clt.opts.DeleteDryRunCheck = true
+
+			err := clt.DeletePod(ctx, "foo", "default")
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedDeletes, deletes)
+		})
+	}
+}
+
+// fakeNotifier records notified Events
+type fakeNotifier struct {
+	events []notify.Event
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, event notify.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func TestDeletePodNotifier(t *testing.T) {
+	var clt kubeClient
+	var ctx = context.TODO()
+	notifier := &fakeNotifier{}
+	clt.clientSet = fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+	clt.opts.Notifier = notifier
+
+	require.NoError(t, clt.DeletePod(ctx, "foo", "default"))
+	// Pod is gone, nothing is deleted and nothing is notified
+	require.Error(t, clt.DeletePod(ctx, "foo", "default"))
+
+	require.Len(t, notifier.events, 1)
+	assert.Equal(t, "default", notifier.events[0].Namespace)
+	assert.Equal(t, "foo", notifier.events[0].Pod)
+	assert.False(t, notifier.events[0].Timestamp.IsZero())
+}
+
+func TestReadyNodeFraction(t *testing.T) {
+	makeNode := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		testName         string
+		mockedNodes      []runtime.Object
+		expectedFraction float64
+	}{
+		{
+			testName:         "Verify fraction of Ready Nodes",
+			mockedNodes:      []runtime.Object{makeNode("node1", corev1.ConditionTrue), makeNode("node2", corev1.ConditionFalse), makeNode("node3", corev1.ConditionUnknown), makeNode("node4", corev1.ConditionTrue)},
+			expectedFraction: 0.5,
+		},
+		{
+			testName:         "Verify fraction is 0 without Nodes",
+			mockedNodes:      nil,
+			expectedFraction: 0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			clt.clientSet = fake.NewSimpleClientset(test.mockedNodes...)
+
+			fraction, err := clt.ReadyNodeFraction(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFraction, fraction)
+		})
+	}
+}
+
+func TestGetEventsAllowCachedList(t *testing.T) {
+	testCases := []struct {
+		testName          string
+		allowCachedList   bool
+		expectedErr       bool
+		expectedListCalls int
+	}{
+		{
+			testName:          "Return error when List times out",
+			allowCachedList:   false,
+			expectedErr:       true,
+			expectedListCalls: 1,
+		},
+		{
+			testName:          "List from cache when List times out",
+			allowCachedList:   true,
+			expectedErr:       false,
+			expectedListCalls: 2,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var clt kubeClient
+			var ctx = context.TODO()
+			var listCalls int
+			clientSet := fake.NewSimpleClientset()
+			// the first (consistent) List times out, the cached List succeeds
+			clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				listCalls++
+				if listCalls == 1 {
+					return true, nil, apierrors.NewTimeoutError("request timed out", 1)
+				}
+				return true, &corev1.EventList{Items: []corev1.Event{
+					*makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+				}}, nil
+			})
+			clt.clientSet = clientSet
+			clt.opts.ListPageSize = 500
+			clt.opts.AllowCachedList = test.allowCachedList
+
+			podEvents, err := clt.GetEvents(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, podEvents, 1)
+			}
+			assert.Equal(t, test.expectedListCalls, listCalls)
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListInitWaitingReasons(t *testing.T) {
+	var ctx = context.TODO()
+	stuck := makePod("pod_2", "default", 1, corev1.PodPending, "uid2")
+	stuck.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "setup", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError"}}},
+	}
+	initializing := makePod("pod_3", "default", 1, corev1.PodPending, "uid3")
+	initializing.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "setup", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}},
+	}
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			makePod("pod_1", "default", 1, corev1.PodPending, "uid1"),
+			stuck,
+			initializing,
+			makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+		),
+		opts: Options{InitWaitingReasons: []string{"CreateContainerConfigError"}},
+	}
+
+	// Pods matching Events or waiting on init containers are matched
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default", "pod_2": "default"}, uniquePodList)
+}
+
+func TestAllNamespacesWithFieldSelector(t *testing.T) {
+	var ctx = context.TODO()
+	var pods []runtime.Object
+	for _, ns := range []string{"team-a", "team-b"} {
+		for _, node := range []string{"node1", "node2"} {
+			name := fmt.Sprintf("%s-%s", ns, node)
+			pod := makeOwnedPod(name, ns, corev1.PodPending, nil)
+			pod.ObjectMeta.UID = types.UID(ns + "-" + name)
+			pod.Spec.NodeName = node
+			pods = append(pods, pod)
+			pods = append(pods, makeEvent(name, ns, "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, pod.ObjectMeta.UID))
+		}
+		stuck := makeOwnedPod(ns+"-init", ns, corev1.PodPending, nil)
+		stuck.ObjectMeta.UID = types.UID(ns + "-init")
+		stuck.Spec.NodeName = "node1"
+		stuck.Status.InitContainerStatuses = []corev1.ContainerStatus{
+			{Name: "setup", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError"}}},
+		}
+		pods = append(pods, stuck)
+	}
+	clientSet := fake.NewSimpleClientset(pods...)
+	var getNamespaces []string
+	clientSet.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		getNamespaces = append(getNamespaces, action.GetNamespace())
+		return false, nil, nil
+	})
+	client := kubeClient{
+		clientSet: clientSet,
+		opts: Options{
+			NodeName:           "node1",
+			InitWaitingReasons: []string{"CreateContainerConfigError"},
+		},
+	}
+
+	// Pods are matched in all namespaces and keep their own namespace
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"team-a-node1": "team-a",
+		"team-a-node2": "team-a",
+		"team-a-init":  "team-a",
+		"team-b-node1": "team-b",
+		"team-b-node2": "team-b",
+		"team-b-init":  "team-b",
+	}, uniquePodList)
+
+	// Pods are checked in their own namespace, Pods on other nodes are skipped
+	for pod, ns := range uniquePodList {
+		err := client.PodChecks(ctx, pod, ns)
+		if strings.HasSuffix(pod, "-node2") {
+			assert.Equal(t, DecisionSkippedNode, DecisionOf(err))
+		} else {
+			assert.NotEqual(t, DecisionErrorGetPod, DecisionOf(err))
+		}
+	}
+	assert.NotEmpty(t, getNamespaces)
+	assert.NotContains(t, getNamespaces, "")
+
+	// an empty namespace is never used to look up a single Pod
+	_, err = client.GetPodDetails(ctx, "team-a-node1", "")
+	assert.Error(t, err)
+	_, err = client.getPodEvents(ctx, "team-a-node1", "")
+	assert.Error(t, err)
+}
+
+func TestOrphanedNodePods(t *testing.T) {
+	var ctx = context.TODO()
+	orphaned := makeOwnedPod("pod_1", "default", corev1.PodRunning, nil)
+	orphaned.Spec.NodeName = "node-gone"
+	scheduled := makeOwnedPod("pod_2", "default", corev1.PodRunning, nil)
+	scheduled.Spec.NodeName = "node1"
+	unscheduled := makeOwnedPod("pod_3", "default", corev1.PodPending, nil)
+	clientSet := fake.NewSimpleClientset(
+		orphaned, scheduled, unscheduled,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	)
+	nodeGets := 0
+	clientSet.PrependReactor("get", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		nodeGets++
+		return false, nil, nil
+	})
+	var gracePeriod *int64
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gracePeriod = action.(k8stesting.DeleteActionImpl).DeleteOptions.GracePeriodSeconds
+		return false, nil, nil
+	})
+	client := kubeClient{
+		clientSet: clientSet,
+		opts:      Options{OrphanedNodePods: true},
+	}
+
+	// only the Pod assigned to a Node that does not exist is matched
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default"}, uniquePodList)
+
+	// the Pod status is stale without a kubelet, the Pod is deleted although it is Running
+	require.NoError(t, client.PodChecks(ctx, "pod_1", "default"))
+	require.NoError(t, client.DeletePod(ctx, "pod_1", "default"))
+	require.NotNil(t, gracePeriod, "Pod should have been force deleted")
+	assert.Equal(t, int64(0), *gracePeriod)
+
+	// Nodes are fetched once per client
+	assert.Equal(t, 2, nodeGets)
+}
+
+func TestUnschedulableTimeout(t *testing.T) {
+	var ctx = context.TODO()
+	unschedulable := func(name string, since time.Duration) *corev1.Pod {
+		pod := makeOwnedPod(name, "default", corev1.PodPending, nil)
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionFalse,
+			Reason:             corev1.PodReasonUnschedulable,
+			Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+		}}
+		return pod
+	}
+	stuck := unschedulable("pod_1", time.Hour)
+	recent := unschedulable("pod_2", time.Minute)
+	assigned := unschedulable("pod_3", time.Hour)
+	assigned.Spec.NodeName = "node1"
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(stuck, recent, assigned),
+		opts:      Options{UnschedulableTimeout: 15 * time.Minute},
+	}
+
+	// only the Pod unschedulable for longer than the timeout and not assigned to a node is matched
+	uniquePodList, err := client.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod_1": "default"}, uniquePodList)
+}
+
+func TestDeletePodConflict(t *testing.T) {
+	var ctx = context.TODO()
+	clientSet := fake.NewSimpleClientset(makeOwnedPod("pod_1", "default", corev1.PodPending, nil))
+	conflicted := false
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if !conflicted {
+			conflicted = true
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "pod_1", errors.New("the object has been modified"))
+		}
+		return false, nil, nil
+	})
+	client := kubeClient{clientSet: clientSet}
+
+	require.NoError(t, client.PodChecks(ctx, "pod_1", "default"))
+	err := client.DeletePod(ctx, "pod_1", "default")
+	assert.True(t, apierrors.IsConflict(err))
+
+	// the conflicted Pod can be checked again in the same cycle, and is deleted
+	require.NoError(t, client.PodChecks(ctx, "pod_1", "default"))
+	require.NoError(t, client.DeletePod(ctx, "pod_1", "default"))
+	_, err = client.GetPodDetails(ctx, "pod_1", "default")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestDeletePodReusesCheckedDetails(t *testing.T) {
+	var ctx = context.TODO()
+	clientSet := fake.NewSimpleClientset(
+		makeOwnedPod("pod_1", "default", corev1.PodPending, nil),
+		makeOwnedPod("pod_2", "default", corev1.PodPending, nil),
+	)
+	client := kubeClient{clientSet: clientSet}
+	podGets := func() int {
+		gets := 0
+		for _, action := range clientSet.Actions() {
+			if action.Matches("get", "pods") {
+				gets++
+			}
+		}
+		return gets
+	}
+
+	// the Pod fetched by PodChecks is not fetched again before it is deleted
+	require.NoError(t, client.PodChecks(ctx, "pod_1", "default"))
+	require.NoError(t, client.DeletePod(ctx, "pod_1", "default"))
+	assert.Equal(t, 1, podGets())
+	assert.Empty(t, client.checkedDetails)
+
+	// a Pod that was not checked by the client is fetched
+	require.NoError(t, client.DeletePod(ctx, "pod_2", "default"))
+	assert.Equal(t, 2, podGets())
+}
+
+func TestCanceledDuringShutdown(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(makeOwnedPod("pod_1", "default", corev1.PodPending, nil))
+	client := kubeClient{clientSet: clientSet}
+
+	// the context is cancelled while the API call is in flight, client-go wraps the context error
+	var cancel context.CancelFunc
+	canceled := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		return true, nil, &url.Error{Op: "Get", URL: "https://kubernetes.default", Err: context.Canceled}
+	}
+	clientSet.PrependReactor("list", "events", canceled)
+	clientSet.PrependReactor("delete", "pods", canceled)
+
+	listCtx, cancelList := context.WithCancel(context.Background())
+	defer cancelList()
+	cancel = cancelList
+	_, err := client.GenerateToBeDeletedPodList(listCtx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+	assert.True(t, IsCanceled(listCtx, err))
+
+	deleteCtx, cancelDelete := context.WithCancel(context.Background())
+	defer cancelDelete()
+	cancel = cancelDelete
+	require.NoError(t, client.PodChecks(deleteCtx, "pod_1", "default"))
+	err = client.DeletePod(deleteCtx, "pod_1", "default")
+	assert.Equal(t, DecisionCanceled, DecisionOf(err))
+
+	// the same errors are not expected while running
+	assert.False(t, IsCanceled(context.Background(), err))
+}
+
+func TestGenerateToBeDeletedPodListMatchedPodAges(t *testing.T) {
+	var ctx = context.TODO()
+	now := time.Now()
+	older := makePod("foo", "default", 1, corev1.PodPending, "uid1")
+	older.CreationTimestamp = metav1.NewTime(now.Add(-10 * time.Minute))
+	newer := makePod("bar", "default", 1, corev1.PodPending, "uid2")
+	newer.CreationTimestamp = metav1.NewTime(now.Add(-time.Minute))
+	clt := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			older, newer, makePod("baz", "default", 1, corev1.PodRunning, "uid3"),
+			makeEvent("foo", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+			makeEvent("bar", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+			// the Pod is gone, its age is unknown
+			makeEvent("gone", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid4"),
+		),
+		opts: Options{Clock: clocktesting.NewFakeClock(now)},
+	}
+
+	uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+	require.NoError(t, err)
+	assert.Len(t, uniquePodList, 3)
+	assert.ElementsMatch(t, []time.Duration{10 * time.Minute, time.Minute}, clt.matchedPodAges["default"])
+
+	// Pods are listed once for the matchers, the filters and the ages
+	clt.clientSet.(*fake.Clientset).ClearActions()
+	clt.matchedPodAges = nil
+	clt.opts.UnschedulableTimeout = 15 * time.Minute
+	clt.opts.MinEventOffset = time.Second
+	_, err = clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 30)
+	require.NoError(t, err)
+	podLists := 0
+	for _, action := range clt.clientSet.(*fake.Clientset).Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "pods" {
+			podLists++
+		}
+	}
+	assert.Equal(t, 1, podLists)
+	assert.Len(t, clt.matchedPodAges["default"], 2)
+}
@@ -0,0 +1,156 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// MatchModes are the accepted values of the match mode
+// any matches Pods matched by Events or by any of the matchers, all matches Pods matched by Events and by all the matchers
+var MatchModes = []string{"any", "all"}
+
+// matcherEventReason is the Reason of the Events recorded for Pods matched by a Matcher, the Message is the matcher reason
+const matcherEventReason = "Matched"
+
+// Matcher decides if a Pod matches, eg: because it is unschedulable for too long
+// events are the Events of the Pod that matched Reason and Message, there might be none
+// returns true and the reason the Pod matched, eg: "Node node1 does not exist anymore"
+type Matcher interface {
+	Matches(pod *PodDetails, events []PodEvent) (bool, string)
+}
+
+// MatcherFunc is a function that implements Matcher
+type MatcherFunc func(pod *PodDetails, events []PodEvent) (bool, string)
+
+// Matches returns f(pod, events)
+func (f MatcherFunc) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	return f(pod, events)
+}
+
+// PodMatchers returns the built-in matchers enabled in opts, in addition to Events matching Reason and Message
+func PodMatchers(opts Options) []Matcher {
+	var matchers []Matcher
+	if opts.UnschedulableTimeout > 0 {
+		matchers = append(matchers, UnschedulableMatcher{Timeout: opts.UnschedulableTimeout, Clock: opts.Clock})
+	}
+	if opts.OrphanedNodePods {
+		matchers = append(matchers, OrphanedNodeMatcher{})
+	}
+	if len(opts.InitWaitingReasons) > 0 {
+		matchers = append(matchers, InitWaitingMatcher{Reasons: opts.InitWaitingReasons})
+	}
+	if len(opts.TerminationReasons) > 0 {
+		matchers = append(matchers, LastTerminationMatcher{Reasons: opts.TerminationReasons})
+	}
+	if opts.MatchJSONPath != "" {
+		matchers = append(matchers, JSONPathMatcher{})
+	}
+	return matchers
+}
+
+// AnyOf returns a Matcher that matches Pods matched by any of matchers, with the reason of the first one
+func AnyOf(matchers ...Matcher) Matcher {
+	return MatcherFunc(func(pod *PodDetails, events []PodEvent) (bool, string) {
+		for _, m := range matchers {
+			if ok, reason := m.Matches(pod, events); ok {
+				return true, reason
+			}
+		}
+		return false, ""
+	})
+}
+
+// AllOf returns a Matcher that matches Pods matched by all of matchers, with their reasons joined
+func AllOf(matchers ...Matcher) Matcher {
+	return MatcherFunc(func(pod *PodDetails, events []PodEvent) (bool, string) {
+		var reasons []string
+		for _, m := range matchers {
+			ok, reason := m.Matches(pod, events)
+			if !ok {
+				return false, ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return len(matchers) > 0, strings.Join(reasons, "; ")
+	})
+}
+
+// EventMatcher matches Pods with Events that matched Reason and Message
+type EventMatcher struct{}
+
+// Matches returns true if the Pod has Events, with the Reason and Message of the first one
+func (EventMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	if len(events) == 0 {
+		return false, ""
+	}
+	return true, fmt.Sprintf("%s: %s", events[0].Reason, events[0].Message)
+}
+
+// UnschedulableMatcher matches Pending Pods that have been unschedulable for longer than Timeout
+type UnschedulableMatcher struct {
+	Timeout time.Duration
+	Clock   clock.PassiveClock // source of the current time (nil uses the real clock)
+}
+
+// Matches returns true if the Pod has been unschedulable for longer than Timeout, with the scheduling failure message
+func (m UnschedulableMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	since, message, ok := pod.unschedulableSince()
+	if !ok {
+		return false, ""
+	}
+	var now time.Time
+	if m.Clock != nil {
+		now = m.Clock.Now()
+	} else {
+		now = time.Now()
+	}
+	if now.Sub(since) < m.Timeout {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Pod has been unschedulable for %v: %s", now.Sub(since).Truncate(time.Second), message)
+}
+
+// OrphanedNodeMatcher matches Pods assigned to a Node that does not exist anymore
+// these Pods are stuck, eg: Pending or Unknown, until they are garbage collected
+type OrphanedNodeMatcher struct{}
+
+// Matches returns true if the Node of the Pod does not exist anymore
+func (OrphanedNodeMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	if !pod.NodeMissing {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Node %s does not exist anymore", pod.NodeName)
+}
+
+// InitWaitingMatcher matches Pods with an init container waiting for any of Reasons, eg: CreateContainerConfigError
+type InitWaitingMatcher struct {
+	Reasons []string
+}
+
+// Matches returns true if an init container of the Pod is waiting for any of Reasons, with the waiting message
+func (m InitWaitingMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	_, message, ok := pod.initContainerWaiting(m.Reasons)
+	return ok, message
+}
+
+// LastTerminationMatcher matches Pods with a container that last terminated for any of Reasons, eg: OOMKilled
+type LastTerminationMatcher struct {
+	Reasons []string
+}
+
+// Matches returns true if a container of the Pod last terminated for any of Reasons, with the termination reason and exit code
+func (m LastTerminationMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	_, message, ok := pod.lastTerminated(m.Reasons)
+	return ok, message
+}
+
+// JSONPathMatcher matches Pods with a non-empty MatchJSONPath result, the result is the reason
+type JSONPathMatcher struct{}
+
+// Matches returns true if the MatchJSONPath result of the Pod is not empty
+func (JSONPathMatcher) Matches(pod *PodDetails, events []PodEvent) (bool, string) {
+	return pod.JSONPathMatch != "", pod.JSONPathMatch
+}
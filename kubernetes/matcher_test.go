@@ -0,0 +1,194 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestMatchers(t *testing.T) {
+	now := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+	unschedulable := PodDetails{
+		Phase: corev1.PodPending,
+		Conditions: []corev1.PodCondition{{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionFalse,
+			Reason:             corev1.PodReasonUnschedulable,
+			Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+			LastTransitionTime: metav1.NewTime(now.Add(-time.Hour)),
+		}},
+	}
+	initWaiting := PodDetails{
+		InitContainerStatuses: []corev1.ContainerStatus{
+			{Name: "setup", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError"}}},
+		},
+	}
+	oomKilled := PodDetails{
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "sidecar"},
+			{
+				Name:                 "app",
+				RestartCount:         3,
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			},
+		},
+	}
+	events := []PodEvent{{Reason: "FailedCreatePodSandBox", Message: "container veth name provided (eth0) already exists"}}
+
+	tests := map[string]struct {
+		matcher        Matcher
+		pod            PodDetails
+		events         []PodEvent
+		expectedMatch  bool
+		expectedReason string
+	}{
+		"Verify EventMatcher matches Pods with Events": {
+			matcher:        EventMatcher{},
+			events:         events,
+			expectedMatch:  true,
+			expectedReason: "FailedCreatePodSandBox: container veth name provided (eth0) already exists",
+		},
+		"Verify EventMatcher does not match Pods without Events": {
+			matcher: EventMatcher{},
+		},
+		"Verify UnschedulableMatcher matches Pods unschedulable for longer than the timeout": {
+			matcher:        UnschedulableMatcher{Timeout: 15 * time.Minute, Clock: clocktesting.NewFakePassiveClock(now)},
+			pod:            unschedulable,
+			expectedMatch:  true,
+			expectedReason: "Pod has been unschedulable for 1h0m0s: 0/3 nodes are available: 3 Insufficient cpu.",
+		},
+		"Verify UnschedulableMatcher does not match Pods unschedulable for less than the timeout": {
+			matcher: UnschedulableMatcher{Timeout: 2 * time.Hour, Clock: clocktesting.NewFakePassiveClock(now)},
+			pod:     unschedulable,
+		},
+		"Verify OrphanedNodeMatcher matches Pods whose Node is missing": {
+			matcher:        OrphanedNodeMatcher{},
+			pod:            PodDetails{NodeName: "node1", NodeMissing: true},
+			expectedMatch:  true,
+			expectedReason: "Node node1 does not exist anymore",
+		},
+		"Verify OrphanedNodeMatcher does not match Pods whose Node exists": {
+			matcher: OrphanedNodeMatcher{},
+			pod:     PodDetails{NodeName: "node1"},
+		},
+		"Verify InitWaitingMatcher matches Pods with init containers waiting for a reason": {
+			matcher:        InitWaitingMatcher{Reasons: []string{"CreateContainerConfigError"}},
+			pod:            initWaiting,
+			expectedMatch:  true,
+			expectedReason: "init container setup is waiting: CreateContainerConfigError",
+		},
+		"Verify LastTerminationMatcher matches Pods with containers last terminated for a reason": {
+			matcher:        LastTerminationMatcher{Reasons: []string{"OOMKilled"}},
+			pod:            oomKilled,
+			expectedMatch:  true,
+			expectedReason: "container app last terminated: OOMKilled (exit code 137)",
+		},
+		"Verify LastTerminationMatcher does not match Pods with containers last terminated for other reasons": {
+			matcher: LastTerminationMatcher{Reasons: []string{"Error"}},
+			pod:     oomKilled,
+		},
+		"Verify JSONPathMatcher matches Pods with a JSONPath result": {
+			matcher:        JSONPathMatcher{},
+			pod:            PodDetails{JSONPathMatch: "mycontainer"},
+			expectedMatch:  true,
+			expectedReason: "mycontainer",
+		},
+		"Verify AnyOf matches with the reason of the first matching matcher": {
+			matcher:        AnyOf(JSONPathMatcher{}, InitWaitingMatcher{Reasons: []string{"CreateContainerConfigError"}}, EventMatcher{}),
+			pod:            initWaiting,
+			events:         events,
+			expectedMatch:  true,
+			expectedReason: "init container setup is waiting: CreateContainerConfigError",
+		},
+		"Verify AllOf matches with the reasons of all matchers": {
+			matcher:        AllOf(EventMatcher{}, InitWaitingMatcher{Reasons: []string{"CreateContainerConfigError"}}),
+			pod:            initWaiting,
+			events:         events,
+			expectedMatch:  true,
+			expectedReason: "FailedCreatePodSandBox: container veth name provided (eth0) already exists; init container setup is waiting: CreateContainerConfigError",
+		},
+		"Verify AllOf does not match if a matcher does not match": {
+			matcher: AllOf(EventMatcher{}, JSONPathMatcher{}),
+			pod:     initWaiting,
+			events:  events,
+		},
+		"Verify AllOf without matchers does not match": {
+			matcher: AllOf(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok, reason := test.matcher.Matches(&test.pod, test.events)
+			assert.Equal(t, test.expectedMatch, ok)
+			assert.Equal(t, test.expectedReason, reason)
+		})
+	}
+}
+
+func TestGenerateToBeDeletedPodListMatchAll(t *testing.T) {
+	// pod_1 has a matching Event and waits on an init container, pod_2 only has a matching Event, pod_3 only waits on an init container
+	initWaiting := func(name string, uid types.UID) *corev1.Pod {
+		pod := makePod(name, "default", 1, corev1.PodPending, uid)
+		pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+			{Name: "setup", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError"}}},
+		}
+		return pod
+	}
+	mockedObjects := []runtime.Object{
+		initWaiting("pod_1", "uid1"),
+		makePod("pod_2", "default", 1, corev1.PodPending, "uid2"),
+		initWaiting("pod_3", "uid3"),
+		makeEvent("pod_1", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+		makeEvent("pod_2", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid2"),
+	}
+
+	testCases := []struct {
+		testName        string
+		opts            Options
+		expectedPodList map[string]string
+	}{
+		{
+			testName:        "Match Pods matched by Events or by any matcher",
+			opts:            Options{InitWaitingReasons: []string{"CreateContainerConfigError"}},
+			expectedPodList: map[string]string{"pod_1": "default", "pod_2": "default", "pod_3": "default"},
+		},
+		{
+			testName:        "Match only Pods matched by Events and by all matchers",
+			opts:            Options{InitWaitingReasons: []string{"CreateContainerConfigError"}, MatchAll: true},
+			expectedPodList: map[string]string{"pod_1": "default"},
+		},
+		{
+			testName: "Match Pods with custom matchers instead of the built-in matchers",
+			opts: Options{
+				InitWaitingReasons: []string{"CreateContainerConfigError"},
+				Matchers: []Matcher{MatcherFunc(func(pod *PodDetails, events []PodEvent) (bool, string) {
+					return pod.PodName == "pod_2", "custom"
+				})},
+				MatchAll: true,
+			},
+			expectedPodList: map[string]string{"pod_2": "default"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.testName, func(t *testing.T) {
+			var ctx = context.TODO()
+			clt := kubeClient{clientSet: fake.NewSimpleClientset(mockedObjects...), opts: test.opts}
+
+			uniquePodList, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 1, 30)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPodList, uniquePodList)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ErrorMessages holds error messages matched in addition to Error Message, eg: loaded from a file
+// messages can be replaced while Pods are matched, eg: when the file is reloaded
+type ErrorMessages struct {
+	mu       sync.RWMutex
+	messages []string
+}
+
+// NewErrorMessages returns ErrorMessages holding messages
+func NewErrorMessages(messages []string) *ErrorMessages {
+	return &ErrorMessages{messages: messages}
+}
+
+// Set replaces the messages
+func (m *ErrorMessages) Set(messages []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = messages
+}
+
+// List returns the messages
+func (m *ErrorMessages) List() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.messages
+}
+
+// ReadErrorMessageFile returns the error messages in a file, one per line
+// surrounding whitespace is trimmed, blank lines and lines starting with # are ignored
+func ReadErrorMessageFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read error message file: %w", err)
+	}
+	var messages []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		messages = append(messages, line)
+	}
+	return messages, nil
+}
+
+// matchesMessage returns true if message contains errorMessage or any of the ErrorMessages, or matches ErrorRegex
+func (c *kubeClient) matchesMessage(message, errorMessage string) bool {
+	if containsMessage(message, errorMessage, c.opts.CaseInsensitive) {
+		return true
+	}
+	if c.opts.ErrorRegex != nil && c.opts.ErrorRegex.MatchString(message) {
+		return true
+	}
+	return c.opts.ErrorMessages != nil && containsAny(message, c.opts.ErrorMessages.List(), c.opts.CaseInsensitive)
+}
+
+// categoryGroup is the name of the ErrorRegex capture group that holds the category of a matched Event
+const categoryGroup = "reason"
+
+// CompileErrorRegex compiles the regex matched against Event messages
+// the only named capture group allowed is (?P<reason>...), it extracts the category of matched Events (eg: cni, volume)
+func CompileErrorRegex(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range re.SubexpNames() {
+		if name != "" && name != categoryGroup {
+			return nil, fmt.Errorf("named capture group %q is not supported, only (?P<%s>...) is", name, categoryGroup)
+		}
+	}
+	return re, nil
+}
+
+// HasCategory returns true if re extracts the category of matched Events
+func HasCategory(re *regexp.Regexp) bool {
+	return re != nil && re.SubexpIndex(categoryGroup) >= 0
+}
+
+// eventCategory returns the category the ErrorRegex extracts from an Event message, empty if it does not match
+func (c *kubeClient) eventCategory(message string) string {
+	match := c.opts.ErrorRegex.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(match[c.opts.ErrorRegex.SubexpIndex(categoryGroup)])
+}
+
+// recordMatchedCategories remembers the category of every Pod, from its first Event with a category
+// Pods are matched before they are deleted, so no lock is needed
+func (c *kubeClient) recordMatchedCategories(events []PodEvent) {
+	if c.matchedCategories == nil {
+		c.matchedCategories = make(map[string]string)
+	}
+	for _, event := range events {
+		key := event.PodNamespace + "/" + event.PodName
+		if _, ok := c.matchedCategories[key]; ok {
+			continue
+		}
+		if category := c.eventCategory(event.Message); category != "" {
+			c.matchedCategories[key] = category
+		}
+	}
+}
+
+// MatchedPodCategory returns the category extracted by ErrorRegex from the Events a Pod was matched for in this cycle
+// empty if ErrorRegex has no reason group or none of the Events of the Pod has a category
+func (c *kubeClient) MatchedPodCategory(pod, namespace string) string {
+	return c.matchedCategories[namespace+"/"+pod]
+}
@@ -0,0 +1,100 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReadErrorMessageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages")
+	content := `# known CNI failures
+container veth name provided (eth0) already exists
+
+  failed to allocate for range 0: no IP addresses available  
+# network plugin
+NetworkPlugin cni failed to set up pod
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	messages, err := ReadErrorMessageFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"container veth name provided (eth0) already exists",
+		"failed to allocate for range 0: no IP addresses available",
+		"NetworkPlugin cni failed to set up pod",
+	}, messages)
+
+	_, err = ReadErrorMessageFile(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestGetEventsErrorMessages(t *testing.T) {
+	var ctx = context.TODO()
+	clt := kubeClient{clientSet: fake.NewSimpleClientset(
+		makeEvent("foo", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid1"),
+		makeEvent("bar", "default", "FailedCreatePodSandBox", "no IP addresses available in range set", "Warning", 1, "uid2"),
+		makeEvent("baz", "default", "FailedCreatePodSandBox", "failed to pull image", "Warning", 1, "uid3"),
+	)}
+
+	podEvents, err := clt.GetEvents(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	require.NoError(t, err)
+	assert.Len(t, podEvents, 1)
+
+	// Events with any of the messages also match, messages can be replaced between cycles
+	clt.opts.ErrorMessages = NewErrorMessages([]string{"no IP addresses available"})
+	podEvents, err = clt.GetEvents(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	require.NoError(t, err)
+	assert.Len(t, podEvents, 2)
+
+	clt.opts.ErrorMessages.Set([]string{"no IP addresses available", "failed to pull image"})
+	podEvents, err = clt.GetEvents(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists")
+	require.NoError(t, err)
+	assert.Len(t, podEvents, 3)
+}
+
+func TestCompileErrorRegex(t *testing.T) {
+	re, err := CompileErrorRegex(`failed to set up pod network: (?P<reason>\w+)`)
+	require.NoError(t, err)
+	assert.True(t, HasCategory(re))
+
+	re, err = CompileErrorRegex(`no IP addresses (available|left)`)
+	require.NoError(t, err)
+	assert.False(t, HasCategory(re))
+
+	_, err = CompileErrorRegex(`(?P<plugin>\w+) failed`)
+	assert.EqualError(t, err, `named capture group "plugin" is not supported, only (?P<reason>...) is`)
+
+	_, err = CompileErrorRegex(`(unclosed`)
+	assert.Error(t, err)
+}
+
+func TestMatchedPodCategory(t *testing.T) {
+	var ctx = context.TODO()
+	re, err := CompileErrorRegex(`(?P<reason>cni|volume) failure`)
+	require.NoError(t, err)
+	clt := kubeClient{
+		clientSet: fake.NewSimpleClientset(
+			makePod("foo", "default", 1, "Pending", "uid1"),
+			makePod("bar", "default", 1, "Pending", "uid2"),
+			makePod("baz", "default", 1, "Pending", "uid3"),
+			makeEvent("foo", "default", "FailedCreatePodSandBox", "plugin reported cni failure", "Warning", 1, "uid1"),
+			makeEvent("bar", "default", "FailedCreatePodSandBox", "volume failure on mount", "Warning", 1, "uid2"),
+			makeEvent("baz", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "uid3"),
+		),
+		opts: Options{ErrorRegex: re},
+	}
+
+	// Events matching the regex are matched too, the reason group is the category of the Pod
+	pods, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, pods, 3)
+	assert.Equal(t, "cni", clt.MatchedPodCategory("foo", "default"))
+	assert.Equal(t, "volume", clt.MatchedPodCategory("bar", "default"))
+	assert.Equal(t, "", clt.MatchedPodCategory("baz", "default"))
+}
@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// deletion orders supported by OrderPods
+const (
+	DeletionOrderOldestFirst = "oldest-first"
+	DeletionOrderNewestFirst = "newest-first"
+	DeletionOrderRandom      = "random"
+)
+
+// PodRef identifies a Pod by name and namespace
+type PodRef struct {
+	Name      string
+	Namespace string
+}
+
+// String returns Pod as namespace/name
+func (p PodRef) String() string {
+	return fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+}
+
+// ValidDeletionOrder returns error if order is not a supported deletion order
+func ValidDeletionOrder(order string) error {
+	switch order {
+	case DeletionOrderOldestFirst, DeletionOrderNewestFirst, DeletionOrderRandom:
+		return nil
+	}
+	return fmt.Errorf("Deletion order %q is not supported, use %s, %s or %s", order, DeletionOrderOldestFirst, DeletionOrderNewestFirst, DeletionOrderRandom)
+}
+
+// OrderPods returns the Pods of a map of Pod names to namespaces in deletion order
+// Pods are ordered by creation time, Pods that cannot be fetched anymore are last
+// Pods created at the same time are ordered by namespace and name
+func (c *kubeClient) OrderPods(ctx context.Context, podList map[string]string, order string) []PodRef {
+	pods := make([]PodRef, 0, len(podList))
+	for pod, ns := range podList {
+		pods = append(pods, PodRef{Name: pod, Namespace: ns})
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].String() < pods[j].String()
+	})
+
+	if order == DeletionOrderRandom {
+		rand.Shuffle(len(pods), func(i, j int) { pods[i], pods[j] = pods[j], pods[i] })
+		return pods
+	}
+
+	created := make(map[PodRef]time.Time, len(pods))
+	for _, pod := range pods {
+		podInfo, err := c.GetPodDetails(ctx, pod.Name, pod.Namespace)
+		if err != nil {
+			continue
+		}
+		created[pod] = podInfo.CreationTimestamp
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		ti, iok := created[pods[i]]
+		tj, jok := created[pods[j]]
+		if !iok || !jok {
+			return iok && !jok
+		}
+		if order == DeletionOrderNewestFirst {
+			return ti.After(tj)
+		}
+		return ti.Before(tj)
+	})
+	return pods
+}
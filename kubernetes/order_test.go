@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestOrderPods(t *testing.T) {
+	makeAgedPod := func(name string, age time.Duration) *corev1.Pod {
+		pod := makePod(name, "default", 1, corev1.PodPending, "")
+		pod.ObjectMeta.CreationTimestamp = metav1.Time{Time: time.Now().Add(-age)}
+		return pod
+	}
+	clientSet := fake.NewSimpleClientset(
+		makeAgedPod("pod_new", time.Minute),
+		makeAgedPod("pod_old", time.Hour),
+		makeAgedPod("pod_mid", 10*time.Minute),
+	)
+	// pod_gone does not exist anymore
+	podList := map[string]string{"pod_new": "default", "pod_old": "default", "pod_mid": "default", "pod_gone": "default"}
+
+	tests := map[string]struct {
+		order    string
+		expected []string
+	}{
+		"Verify Pods are ordered oldest first": {
+			order:    DeletionOrderOldestFirst,
+			expected: []string{"pod_old", "pod_mid", "pod_new", "pod_gone"},
+		},
+		"Verify Pods are ordered newest first": {
+			order:    DeletionOrderNewestFirst,
+			expected: []string{"pod_new", "pod_mid", "pod_old", "pod_gone"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ctx = context.TODO()
+			clt := kubeClient{clientSet: clientSet}
+
+			var names []string
+			for _, pod := range clt.OrderPods(ctx, podList, tc.order) {
+				names = append(names, pod.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+
+	clt := kubeClient{clientSet: clientSet}
+	assert.ElementsMatch(t, []PodRef{
+		{Name: "pod_new", Namespace: "default"},
+		{Name: "pod_old", Namespace: "default"},
+		{Name: "pod_mid", Namespace: "default"},
+		{Name: "pod_gone", Namespace: "default"},
+	}, clt.OrderPods(context.TODO(), podList, DeletionOrderRandom))
+	assert.NoError(t, ValidDeletionOrder(DeletionOrderRandom))
+	assert.Error(t, ValidDeletionOrder("alphabetical"))
+}
@@ -0,0 +1,341 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// annotations written on owning controllers of deleted Pods
+const (
+	restartCountAnnotation = "pod-restarter.io/restart-count"
+	lastRestartAnnotation  = "pod-restarter.io/last-restart"
+)
+
+// Owner identifies the controller owning a Pod
+type Owner struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	UID        types.UID
+}
+
+// String returns owner as Kind/namespace/name
+func (o Owner) String() string {
+	return fmt.Sprintf("%s/%s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// controllerRef returns the controller owner reference (or the first owner if none is marked as controller)
+func controllerRef(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+// resolveOwner returns the owning controller of a Pod
+// Pods owned by a ReplicaSet that is owned by a Deployment resolve to the Deployment
+// Pods owned by a Job that is owned by a CronJob resolve to the CronJob, Jobs of a CronJob get a new name every run
+func (c *kubeClient) resolveOwner(ctx context.Context, namespace string, refs []metav1.OwnerReference) (*Owner, error) {
+	chain, err := c.ownerChain(ctx, namespace, refs)
+	if len(chain) == 0 {
+		return nil, err
+	}
+	return &chain[len(chain)-1], err
+}
+
+// ownerParents are the owner kinds whose own controller is looked up, by kind of the owner and of its controller
+var ownerParents = map[string]string{
+	"ReplicaSet": "Deployment",
+	"Job":        "CronJob",
+}
+
+// ownerChain returns the owning controllers of a Pod, starting with the direct owner
+// eg: the ReplicaSet and the Deployment owning the ReplicaSet, or the Job and the CronJob owning the Job
+// ReplicaSets and Jobs are fetched once per client (ie: per cycle), Pods of the same ReplicaSet or Job share the lookup
+func (c *kubeClient) ownerChain(ctx context.Context, namespace string, refs []metav1.OwnerReference) ([]Owner, error) {
+	ref := controllerRef(refs)
+	if ref == nil {
+		return nil, fmt.Errorf("Pod in namespace %s does not have owner/controller", namespace)
+	}
+	chain := []Owner{{APIVersion: ref.APIVersion, Kind: ref.Kind, Name: ref.Name, Namespace: namespace, UID: ref.UID}}
+	parentKind, ok := ownerParents[ref.Kind]
+	if !ok {
+		return chain, nil
+	}
+
+	key := ref.Kind + "/" + namespace + "/" + ref.Name
+	c.ownersMu.Lock()
+	cached, ok := c.ownerChains[key]
+	c.ownersMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var ownerRefs []metav1.OwnerReference
+	err := c.retryThrottled(ctx, func() error {
+		switch ref.Kind {
+		case "ReplicaSet":
+			rs, err := c.clientSet.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			ownerRefs = rs.ObjectMeta.OwnerReferences
+		case "Job":
+			job, err := c.clientSet.BatchV1().Jobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			ownerRefs = job.ObjectMeta.OwnerReferences
+		}
+		return nil
+	})
+	if err != nil {
+		return chain, fmt.Errorf("Could not get owner %s: %w", chain[0], err)
+	}
+	if parentRef := controllerRef(ownerRefs); parentRef != nil && parentRef.Kind == parentKind {
+		chain = append(chain, Owner{APIVersion: parentRef.APIVersion, Kind: parentRef.Kind, Name: parentRef.Name, Namespace: namespace, UID: parentRef.UID})
+	}
+
+	c.ownersMu.Lock()
+	defer c.ownersMu.Unlock()
+	if c.ownerChains == nil {
+		c.ownerChains = make(map[string][]Owner)
+	}
+	c.ownerChains[key] = chain
+	return chain, nil
+}
+
+// getOwnerMatchingEvents returns one Event for every Pod with an owning controller that has an Event matching Reason and Error Message
+// eg: a ReplicaSet with FailedCreate Events because a quota is exceeded
+// Events of every owner are listed once, even if the owner has many Pods
+func (c *kubeClient) getOwnerMatchingEvents(ctx context.Context, pods *namespacePods, eventReason, errorMessage string) ([]PodEvent, error) {
+
+	var eventList []PodEvent
+
+	podList, err := pods.list(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerEvents := make(map[string][]PodEvent)
+	for _, pod := range *podList {
+		if len(pod.OwnerReferences) == 0 {
+			continue
+		}
+		chain, err := c.ownerChain(ctx, pod.PodNamespace, pod.OwnerReferences)
+		if err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+		for _, owner := range chain {
+			events, ok := ownerEvents[owner.String()]
+			if !ok {
+				events, err = c.getOwnerEvents(ctx, owner, eventReason, errorMessage)
+				if err != nil {
+					return nil, err
+				}
+				ownerEvents[owner.String()] = events
+			}
+			if len(events) == 0 {
+				continue
+			}
+			log.Printf("Pod %s/%s matched Event of owner %s: %s", pod.PodNamespace, pod.PodName, owner, events[0].Message)
+			event := events[0]
+			event.UID = pod.UID
+			event.PodName = pod.PodName
+			event.PodNamespace = pod.PodNamespace
+			eventList = append(eventList, event)
+			break
+		}
+	}
+
+	log.Printf("There is a total of %d Pods with owner Events with Reason: %s", len(eventList), eventReason) // DEBUG
+
+	return eventList, nil
+}
+
+// getOwnerEvents returns the Events of an owning controller that match Reason and Error Message
+// Events are listed in pages of ListPageSize items, like Pod Events
+func (c *kubeClient) getOwnerEvents(ctx context.Context, owner Owner, eventReason, errorMessage string) ([]PodEvent, error) {
+	api := c.clientSet.CoreV1()
+	var events []PodEvent
+
+	listOptions := metav1.ListOptions{
+		Limit:         c.opts.ListPageSize,
+		FieldSelector: c.eventFieldSelector(fmt.Sprintf("involvedObject.kind=%s", owner.Kind), fmt.Sprintf("involvedObject.name=%s", owner.Name)),
+	}
+	for {
+		var eventList *v1.EventList
+		err := c.retryThrottled(ctx, func() (err error) {
+			eventList, err = api.Events(owner.Namespace).List(ctx, listOptions)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get Events of owner %s: %w", owner, err)
+		}
+
+		for _, item := range eventList.Items {
+			// field selectors might not be supported, eg: by caching proxies
+			if item.InvolvedObject.Kind != owner.Kind || item.InvolvedObject.Name != owner.Name {
+				continue
+			}
+			event := newPodEvent(&item)
+			if c.matchesEventFilters(event) && event.Reason == eventReason && c.matchesMessage(event.Message, errorMessage) {
+				events = append(events, event)
+			}
+		}
+
+		if eventList.Continue == "" {
+			break
+		}
+		listOptions.Continue = eventList.Continue
+	}
+	return events, nil
+}
+
+// deletionReason is the value of the ReasonAnnotation written on owning controllers of deleted Pods
+type deletionReason struct {
+	Pod     string    `json:"pod"`
+	Reason  string    `json:"reason,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// annotateOwner annotates the owning controller of a deleted Pod
+// with AnnotateOwner, the restart count is incremented and the last restart time is set
+// the patch sends the resourceVersion the count was read at and is retried on conflicts, so concurrent deletions do not lose increments
+// with ReasonAnnotation, the reason the Pod was deleted for is set, so it is discoverable from the replacement Pod
+func (c *kubeClient) annotateOwner(ctx context.Context, owner *Owner, reason *deletionReason) error {
+	switch owner.Kind {
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet":
+	default:
+		return fmt.Errorf("Could not annotate owner %s: kind %s is not supported", owner, owner.Kind)
+	}
+
+	restartCount := 0
+	annotated := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		metadata := make(map[string]interface{})
+		annotations := make(map[string]string)
+		if c.opts.AnnotateOwner {
+			var ownerMeta *metav1.ObjectMeta
+			err := c.retryThrottled(ctx, func() (err error) {
+				ownerMeta, err = c.getOwnerMeta(ctx, owner)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			restartCount, _ = strconv.Atoi(ownerMeta.Annotations[restartCountAnnotation])
+			annotations[restartCountAnnotation] = strconv.Itoa(restartCount + 1)
+			annotations[lastRestartAnnotation] = c.clock().Now().UTC().Format(time.RFC3339)
+			metadata["resourceVersion"] = ownerMeta.ResourceVersion
+		}
+		if c.opts.ReasonAnnotation != "" && reason != nil {
+			value, err := json.Marshal(reason)
+			if err != nil {
+				return err
+			}
+			annotations[c.opts.ReasonAnnotation] = string(value)
+		}
+		if len(annotations) == 0 {
+			return nil
+		}
+		metadata["annotations"] = annotations
+
+		patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+		if err != nil {
+			return err
+		}
+		annotated = true
+		err = c.retryThrottled(ctx, func() error {
+			return c.patchOwner(ctx, owner, patch)
+		})
+		if err != nil {
+			return fmt.Errorf("Could not annotate owner %s: %w", owner, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !annotated {
+		return nil
+	}
+	if c.opts.AnnotateOwner {
+		log.Printf("Annotated owner %s with restart count %d", owner, restartCount+1)
+	} else {
+		log.Printf("Annotated owner %s with the deletion reason", owner)
+	}
+	return nil
+}
+
+// patchOwner applies a merge patch to the owning controller
+func (c *kubeClient) patchOwner(ctx context.Context, owner *Owner, patch []byte) error {
+	apps := c.clientSet.AppsV1()
+
+	var err error
+	switch owner.Kind {
+	case "Deployment":
+		_, err = apps.Deployments(owner.Namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "ReplicaSet":
+		_, err = apps.ReplicaSets(owner.Namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(owner.Namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = apps.DaemonSets(owner.Namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("kind %s is not supported", owner.Kind)
+	}
+	return err
+}
+
+// getOwnerMeta returns the metadata of the owning controller
+func (c *kubeClient) getOwnerMeta(ctx context.Context, owner *Owner) (*metav1.ObjectMeta, error) {
+	apps := c.clientSet.AppsV1()
+
+	var objectMeta metav1.ObjectMeta
+	switch owner.Kind {
+	case "Deployment":
+		obj, err := apps.Deployments(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner %s: %w", owner, err)
+		}
+		objectMeta = obj.ObjectMeta
+	case "ReplicaSet":
+		obj, err := apps.ReplicaSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner %s: %w", owner, err)
+		}
+		objectMeta = obj.ObjectMeta
+	case "StatefulSet":
+		obj, err := apps.StatefulSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner %s: %w", owner, err)
+		}
+		objectMeta = obj.ObjectMeta
+	case "DaemonSet":
+		obj, err := apps.DaemonSets(owner.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Could not get owner %s: %w", owner, err)
+		}
+		objectMeta = obj.ObjectMeta
+	default:
+		return nil, fmt.Errorf("Owner %s cannot be annotated, kind is not supported", owner)
+	}
+	return &objectMeta, nil
+}
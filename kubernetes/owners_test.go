@@ -0,0 +1,340 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/clock"
+)
+
+func TestResolveOwner(t *testing.T) {
+	isController := true
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "foo-rs",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "foo", Controller: &isController},
+		},
+	}}
+	standaloneReplicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "bar-rs", Namespace: "default"}}
+	cronJobRun := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:      "backup-27834560",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: &isController},
+		},
+	}}
+	standaloneJob := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "default"}}
+
+	tests := map[string]struct {
+		refs          []metav1.OwnerReference
+		expectedOwner Owner
+	}{
+		"Verify ReplicaSet owned by Deployment resolves to the Deployment": {
+			refs:          []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo-rs", Controller: &isController}},
+			expectedOwner: Owner{APIVersion: "apps/v1", Kind: "Deployment", Name: "foo", Namespace: "default"},
+		},
+		"Verify standalone ReplicaSet resolves to itself": {
+			refs:          []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar-rs"}},
+			expectedOwner: Owner{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar-rs", Namespace: "default"},
+		},
+		"Verify Job owned by CronJob resolves to the CronJob": {
+			refs:          []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "backup-27834560", Controller: &isController}},
+			expectedOwner: Owner{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Namespace: "default"},
+		},
+		"Verify standalone Job resolves to itself": {
+			refs:          []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "migrate", Controller: &isController}},
+			expectedOwner: Owner{APIVersion: "batch/v1", Kind: "Job", Name: "migrate", Namespace: "default"},
+		},
+		"Verify StatefulSet resolves to itself": {
+			refs:          []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", Controller: &isController}},
+			expectedOwner: Owner{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", Namespace: "default"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(deployment, replicaSet, standaloneReplicaSet, cronJobRun, standaloneJob)
+
+			owner, err := clt.resolveOwner(context.TODO(), "default", tc.refs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOwner, *owner)
+		})
+	}
+}
+
+func TestDeletePodAnnotateOwner(t *testing.T) {
+	var clt kubeClient
+	var ctx = context.TODO()
+	isController := true
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "foo-rs",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "foo", Controller: &isController},
+		},
+	}}
+	pod1 := makeOwnedPod("foo-1", "default", v1.PodPending, nil)
+	pod1.ObjectMeta.OwnerReferences[0].Name = "foo-rs"
+	pod2 := makeOwnedPod("foo-2", "default", v1.PodPending, nil)
+	pod2.ObjectMeta.OwnerReferences[0].Name = "foo-rs"
+	clt.clientSet = fake.NewSimpleClientset(deployment, replicaSet, pod1, pod2)
+	clt.opts.AnnotateOwner = true
+
+	require.NoError(t, clt.DeletePod(ctx, "foo-1", "default"))
+	require.NoError(t, clt.DeletePod(ctx, "foo-2", "default"))
+
+	annotated, err := clt.clientSet.AppsV1().Deployments("default").Get(ctx, "foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2", annotated.ObjectMeta.Annotations[restartCountAnnotation])
+	assert.NotEmpty(t, annotated.ObjectMeta.Annotations[lastRestartAnnotation])
+}
+
+func TestDeletePodOwnerChain(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "foo-rs",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "foo", Controller: &isController},
+		},
+	}}
+	pod1 := makeOwnedPod("foo-1", "default", v1.PodPending, nil)
+	pod1.ObjectMeta.OwnerReferences[0].Name = "foo-rs"
+	pod2 := makeOwnedPod("foo-2", "default", v1.PodPending, nil)
+	pod2.ObjectMeta.OwnerReferences[0].Name = "foo-rs"
+	clientSet := fake.NewSimpleClientset(replicaSet, pod1, pod2, makePod("orphan", "default", 1, v1.PodPending, "uid3"))
+	replicaSetGets := 0
+	clientSet.PrependReactor("get", "replicasets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		replicaSetGets++
+		return false, nil, nil
+	})
+	notifier := &fakeNotifier{}
+	clt := kubeClient{clientSet: clientSet, opts: Options{Notifier: notifier}}
+
+	require.NoError(t, clt.DeletePod(ctx, "foo-1", "default"))
+	require.NoError(t, clt.DeletePod(ctx, "foo-2", "default"))
+	require.NoError(t, clt.DeletePod(ctx, "orphan", "default"))
+
+	// the top-level owner of deleted Pods is reported, the ReplicaSet is fetched once per client
+	assert.Equal(t, "Deployment/default/foo", clt.DeletedPodOwner("foo-1", "default"))
+	assert.Equal(t, "Deployment/default/foo", clt.DeletedPodOwner("foo-2", "default"))
+	assert.Empty(t, clt.DeletedPodOwner("orphan", "default"))
+	assert.Equal(t, 1, replicaSetGets)
+	require.Len(t, notifier.events, 3)
+	assert.Equal(t, "Deployment/default/foo", notifier.events[0].Owner)
+	assert.Empty(t, notifier.events[2].Owner)
+}
+
+func TestGetOwnerMatchingEvents(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web-1-rs",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &isController},
+		},
+	}}
+	// web-1 Deployment has a quota Event, web-2 ReplicaSet does not exist
+	deploymentEvent := makeEvent("web", "default", "FailedCreate", "exceeded quota: compute-resources", "Warning", 1, "web-uid")
+	deploymentEvent.InvolvedObject.Kind = "Deployment"
+	otherEvent := makeEvent("web-2-rs", "default", "FailedCreate", "exceeded quota: compute-resources", "Warning", 1, "web-2-rs")
+	otherEvent.InvolvedObject.Kind = "StatefulSet"
+
+	client := kubeClient{clientSet: fake.NewSimpleClientset(
+		replicaSet,
+		makeOwnedPod("web-1", "default", v1.PodPending, nil),
+		makeOwnedPod("web-2", "default", v1.PodPending, nil),
+		makePod("orphan", "default", 1, v1.PodPending, "orphan"),
+		deploymentEvent,
+		otherEvent,
+	)}
+
+	events, err := client.getOwnerMatchingEvents(ctx, &namespacePods{namespace: "default"}, "FailedCreate", "exceeded quota")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "web-1", events[0].PodName)
+	assert.Equal(t, "default", events[0].PodNamespace)
+	assert.Equal(t, "exceeded quota: compute-resources", events[0].Message)
+}
+
+func TestDeletePodReasonAnnotation(t *testing.T) {
+	var clt kubeClient
+	var ctx = context.TODO()
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	pod := makeOwnedPod("db-0", "default", v1.PodPending, nil)
+	pod.ObjectMeta.OwnerReferences[0].Kind = "StatefulSet"
+	pod.ObjectMeta.OwnerReferences[0].Name = "db"
+	clt.clientSet = fake.NewSimpleClientset(
+		statefulSet,
+		pod,
+		makeEvent("db-0", "default", "FailedCreatePodSandBox", "container veth name provided (eth0) already exists", "Warning", 1, "db-0"),
+	)
+	clt.opts.ReasonAnnotation = "pod-restarter.io/last-restart-reason"
+
+	_, err := clt.GenerateToBeDeletedPodList(ctx, "default", "FailedCreatePodSandBox", "already exists", 0, 30)
+	require.NoError(t, err)
+	require.NoError(t, clt.DeletePod(ctx, "db-0", "default"))
+
+	annotated, err := clt.clientSet.AppsV1().StatefulSets("default").Get(ctx, "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	var reason deletionReason
+	require.NoError(t, json.Unmarshal([]byte(annotated.ObjectMeta.Annotations["pod-restarter.io/last-restart-reason"]), &reason))
+	assert.Equal(t, "default/db-0", reason.Pod)
+	assert.Equal(t, "FailedCreatePodSandBox", reason.Reason)
+	assert.Equal(t, "container veth name provided (eth0) already exists", reason.Message)
+	assert.False(t, reason.Time.IsZero())
+	// restart count is only written with AnnotateOwner
+	assert.Empty(t, annotated.ObjectMeta.Annotations[restartCountAnnotation])
+}
+
+func TestAnnotateOwnerUnsupportedKind(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:      "backup-27834560",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: &isController},
+		},
+	}}
+	pod := makeOwnedPod("backup-27834560-abcde", "default", v1.PodPending, nil)
+	pod.ObjectMeta.OwnerReferences[0].APIVersion = "batch/v1"
+	pod.ObjectMeta.OwnerReferences[0].Kind = "Job"
+	pod.ObjectMeta.OwnerReferences[0].Name = "backup-27834560"
+	clientSet := fake.NewSimpleClientset(job, pod)
+	clt := kubeClient{clientSet: clientSet}
+	clt.opts.ReasonAnnotation = "pod-restarter.io/last-restart-reason"
+
+	owner, err := clt.resolveOwner(ctx, "default", pod.ObjectMeta.OwnerReferences)
+	require.NoError(t, err)
+	err = clt.annotateOwner(ctx, owner, &deletionReason{Pod: "default/backup-27834560-abcde"})
+	assert.EqualError(t, err, "Could not annotate owner CronJob/default/backup: kind CronJob is not supported")
+
+	// the Pod is still deleted, nothing is patched
+	require.NoError(t, clt.DeletePod(ctx, "backup-27834560-abcde", "default"))
+	for _, action := range clientSet.Actions() {
+		assert.NotEqual(t, "patch", action.GetVerb())
+	}
+}
+
+func TestDeletePodAnnotateOwnerConcurrent(t *testing.T) {
+	var ctx = context.TODO()
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1"}}
+	var pods []runtime.Object
+	for i := 0; i < 5; i++ {
+		pod := makeOwnedPod(fmt.Sprintf("web-%d", i), "default", v1.PodPending, nil)
+		pod.ObjectMeta.OwnerReferences[0].Kind = "Deployment"
+		pod.ObjectMeta.OwnerReferences[0].Name = "web"
+		pods = append(pods, pod)
+	}
+	clientSet := fake.NewSimpleClientset(append(pods, deployment)...)
+	// the fake clientset ignores resourceVersion, reject stale patches like the API server does
+	patches, conflicts := 0, 0
+	clientSet.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		var meta struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		if err := json.Unmarshal(patch.GetPatch(), &meta); err != nil {
+			return true, nil, err
+		}
+		obj, err := clientSet.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("deployments"), patch.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		current := obj.(*appsv1.Deployment).DeepCopy()
+		rv, _ := strconv.Atoi(current.ResourceVersion)
+		if patches++; patches == 1 {
+			// another replica deleted a Pod of the Deployment since the restart count was read
+			current.ResourceVersion = strconv.Itoa(rv + 1)
+			current.Annotations = map[string]string{restartCountAnnotation: "1"}
+			if err := clientSet.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), current, current.Namespace); err != nil {
+				return true, nil, err
+			}
+			rv++
+		}
+		if meta.Metadata.ResourceVersion != current.ResourceVersion {
+			conflicts++
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, current.Name, errors.New("the object has been modified"))
+		}
+		current.ResourceVersion = strconv.Itoa(rv + 1)
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		for k, v := range meta.Metadata.Annotations {
+			current.Annotations[k] = v
+		}
+		return true, current, clientSet.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), current, current.Namespace)
+	})
+	clt := kubeClient{clientSet: clientSet, opts: Options{AnnotateOwner: true}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(pod string) {
+			defer wg.Done()
+			assert.NoError(t, clt.DeletePod(ctx, pod, "default"))
+		}(fmt.Sprintf("web-%d", i))
+	}
+	wg.Wait()
+
+	// every deletion is counted, the conflicting patches are retried with the current count
+	annotated, err := clientSet.AppsV1().Deployments("default").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "6", annotated.ObjectMeta.Annotations[restartCountAnnotation])
+	assert.GreaterOrEqual(t, conflicts, 1)
+}
+
+func TestDeletePodPersistentFailureCronJob(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	var objects []runtime.Object
+	// every run of the CronJob is a new Job
+	for _, run := range []string{"backup-27834560", "backup-27834620"} {
+		objects = append(objects, &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      run,
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: &isController},
+			},
+		}})
+		pod := makeOwnedPod(run+"-abcde", "default", v1.PodPending, nil)
+		pod.ObjectMeta.OwnerReferences[0].APIVersion = "batch/v1"
+		pod.ObjectMeta.OwnerReferences[0].Kind = "Job"
+		pod.ObjectMeta.OwnerReferences[0].Name = run
+		objects = append(objects, pod)
+	}
+	clt := kubeClient{
+		clientSet: fake.NewSimpleClientset(objects...),
+		opts:      Options{PersistentFailures: NewOwnerFailureTracker(1, time.Hour, clock.RealClock{})},
+	}
+
+	// deletions of Pods of different runs add up on the CronJob
+	require.NoError(t, clt.DeletePod(ctx, "backup-27834560-abcde", "default"))
+	assert.Equal(t, "CronJob/default/backup", clt.DeletedPodOwner("backup-27834560-abcde", "default"))
+	err := clt.DeletePod(ctx, "backup-27834620-abcde", "default")
+	assert.Equal(t, DecisionSkippedPersistentFailure, DecisionOf(err))
+}
@@ -0,0 +1,185 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+)
+
+// persistentFailureReason is the reason of the Event recorded on owners flagged as persistent failures
+const persistentFailureReason = "PersistentFailure"
+
+// OwnerFailureTracker stops deleting Pods of owners whose Pods were deleted too many times within a rolling window
+// replacement Pods that keep matching point to a problem a restart does not fix (eg: a missing Secret), humans need to investigate
+// owners are tracked instead of Pods, because Pod names change when controllers replace them
+type OwnerFailureTracker struct {
+	threshold int
+	window    time.Duration
+
+	mu        sync.Mutex
+	deletions map[string][]time.Time // deletion timestamps within the rolling window by owner
+	flagged   map[string]bool        // owners reported as persistent failures
+	clock     clock.PassiveClock
+}
+
+// OwnerFailureState holds the persistent failure tracker state
+type OwnerFailureState struct {
+	Threshold       int            `json:"threshold"`
+	Window          string         `json:"window"`
+	RecentDeletions map[string]int `json:"recentDeletions"`
+	Flagged         []string       `json:"flagged"`
+}
+
+// NewOwnerFailureTracker returns an OwnerFailureTracker that flags owners after threshold deletions within window
+// the window is measured with clk
+func NewOwnerFailureTracker(threshold int, window time.Duration, clk clock.PassiveClock) *OwnerFailureTracker {
+	return &OwnerFailureTracker{
+		threshold: threshold,
+		window:    window,
+		deletions: make(map[string][]time.Time),
+		flagged:   make(map[string]bool),
+		clock:     clk,
+	}
+}
+
+// Allow returns true if Pods of owner can be deleted
+// flagged is true the first time owner is found to be a persistent failure, so it is reported only once
+// owners are no longer flagged once their deletions are outside the rolling window
+func (t *OwnerFailureTracker) Allow(owner string) (allowed bool, flagged bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(owner)
+	if len(t.deletions[owner]) < t.threshold {
+		delete(t.flagged, owner)
+		return true, false
+	}
+	if t.flagged[owner] {
+		return false, false
+	}
+	t.flagged[owner] = true
+	return false, true
+}
+
+// Record records a deletion of a Pod of owner
+func (t *OwnerFailureTracker) Record(owner string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(owner)
+	t.deletions[owner] = append(t.deletions[owner], t.clock.Now())
+}
+
+// prune drops deletions of owner that are outside the rolling window
+func (t *OwnerFailureTracker) prune(owner string) {
+	windowStart := t.clock.Now().Add(-t.window)
+	var recent []time.Time
+	for _, deletion := range t.deletions[owner] {
+		if deletion.After(windowStart) {
+			recent = append(recent, deletion)
+		}
+	}
+	if len(recent) == 0 {
+		delete(t.deletions, owner)
+		return
+	}
+	t.deletions[owner] = recent
+}
+
+// State returns the persistent failure tracker state
+func (t *OwnerFailureTracker) State() OwnerFailureState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := OwnerFailureState{
+		Threshold:       t.threshold,
+		Window:          t.window.String(),
+		RecentDeletions: make(map[string]int),
+		Flagged:         []string{},
+	}
+	for owner := range t.deletions {
+		t.prune(owner)
+		if len(t.deletions[owner]) > 0 {
+			state.RecentDeletions[owner] = len(t.deletions[owner])
+		}
+	}
+	for owner := range t.flagged {
+		state.Flagged = append(state.Flagged, owner)
+	}
+	sort.Strings(state.Flagged)
+	return state
+}
+
+// checkPersistentFailure returns an error if owner is a persistent failure and its Pods should not be deleted
+// the first time an owner is flagged, a warning is logged, the persistent failures counter is incremented and an Event is recorded on the owner
+func (c *kubeClient) checkPersistentFailure(ctx context.Context, owner *Owner, pod, namespace string) error {
+	allowed, flagged := c.opts.PersistentFailures.Allow(owner.String())
+	if allowed {
+		return nil
+	}
+	if flagged {
+		message := fmt.Sprintf(
+			"Pods of %s were deleted %d times within %v and still match, pod-restarter stopped deleting them",
+			owner, c.opts.PersistentFailures.threshold, c.opts.PersistentFailures.window,
+		)
+		log.Printf("WARNING: PERSISTENT FAILURE: %s", message)
+		metrics.OwnerPersistentFailure(namespace)
+
+		// recording the Event is best effort, the warning and the metric are already out
+		err := c.recordOwnerEvent(ctx, owner, persistentFailureReason, message)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return skip(DecisionSkippedPersistentFailure, fmt.Errorf("Skipping Pod %s/%s: owner %s is a persistent failure, deleting its Pods does not fix it", namespace, pod, owner))
+}
+
+// recordOwnerEvent records a Warning Event on an owning controller, so it shows up in kubectl describe
+func (c *kubeClient) recordOwnerEvent(ctx context.Context, owner *Owner, reason, message string) error {
+	err := c.recordEvent(ctx, v1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  owner.Namespace,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}, reason, message)
+	if err != nil {
+		return fmt.Errorf("Could not record Event on owner %s: %w", owner, err)
+	}
+	return nil
+}
+
+// recordEvent records a Warning Event on an object, so it shows up in kubectl describe
+// the Event source is the pod-restarter instance, so Events recorded by several replicas can be told apart
+func (c *kubeClient) recordEvent(ctx context.Context, object v1.ObjectReference, reason, message string) error {
+	now := metav1.NewTime(c.clock().Now())
+	event := &v1.Event{
+		ObjectMeta:          metav1.ObjectMeta{GenerateName: object.Name + ".", Namespace: object.Namespace},
+		InvolvedObject:      object,
+		Reason:              reason,
+		Message:             message,
+		Type:                v1.EventTypeWarning,
+		Source:              v1.EventSource{Component: "pod-restarter", Host: c.opts.InstanceName},
+		ReportingController: "pod-restarter",
+		ReportingInstance:   c.opts.InstanceName,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+	err := c.retryThrottled(ctx, func() error {
+		_, err := c.clientSet.CoreV1().Events(object.Namespace).Create(ctx, event, metav1.CreateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not record Event on %s %s/%s: %w", object.Kind, object.Namespace, object.Name, err)
+	}
+	return nil
+}
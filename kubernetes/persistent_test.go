@@ -0,0 +1,88 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestOwnerFailureTracker(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	tracker := NewOwnerFailureTracker(2, time.Hour, fakeClock)
+
+	tracker.Record("Deployment/default/foo")
+	allowed, _ := tracker.Allow("Deployment/default/foo")
+	assert.True(t, allowed)
+	tracker.Record("Deployment/default/foo")
+
+	// the owner is flagged once, when it reaches the threshold
+	allowed, flagged := tracker.Allow("Deployment/default/foo")
+	assert.False(t, allowed)
+	assert.True(t, flagged)
+	allowed, flagged = tracker.Allow("Deployment/default/foo")
+	assert.False(t, allowed)
+	assert.False(t, flagged)
+
+	// other owners are tracked separately
+	allowed, _ = tracker.Allow("Deployment/default/bar")
+	assert.True(t, allowed)
+	assert.Equal(t, OwnerFailureState{
+		Threshold:       2,
+		Window:          "1h0m0s",
+		RecentDeletions: map[string]int{"Deployment/default/foo": 2},
+		Flagged:         []string{"Deployment/default/foo"},
+	}, tracker.State())
+
+	// deletions outside the rolling window do not count
+	fakeClock.Step(2 * time.Hour)
+	allowed, _ = tracker.Allow("Deployment/default/foo")
+	assert.True(t, allowed)
+	assert.Empty(t, tracker.State().Flagged)
+}
+
+func TestDeletePodPersistentFailure(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	var pods []*corev1.Pod
+	for _, name := range []string{"db-0", "db-1", "db-2"} {
+		pod := makePod(name, "default", 1, corev1.PodPending, types.UID("uid-"+name))
+		pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: "db-uid", Controller: &isController},
+		}
+		pods = append(pods, pod)
+	}
+	clientSet := fake.NewSimpleClientset(pods[0], pods[1], pods[2])
+	client := kubeClient{
+		clientSet: clientSet,
+		opts:      Options{PersistentFailures: NewOwnerFailureTracker(1, time.Hour, clock.RealClock{}), InstanceName: "pod-restarter-0"},
+	}
+
+	require.NoError(t, client.DeletePod(ctx, "db-0", "default"))
+
+	// the owner reached the threshold, its Pods are no longer deleted
+	err := client.DeletePod(ctx, "db-1", "default")
+	assert.Equal(t, DecisionSkippedPersistentFailure, DecisionOf(err))
+	err = client.DeletePod(ctx, "db-2", "default")
+	assert.Equal(t, DecisionSkippedPersistentFailure, DecisionOf(err))
+	_, err = client.GetPodDetails(ctx, "db-1", "default")
+	assert.NoError(t, err, "Pod of a persistent failure owner should not have been deleted")
+
+	// a single Event is recorded on the owner
+	events, err := clientSet.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, "PersistentFailure", events.Items[0].Reason)
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: "default", Name: "db", UID: "db-uid"}, events.Items[0].InvolvedObject)
+	// the Event tells which pod-restarter instance recorded it
+	assert.Equal(t, corev1.EventSource{Component: "pod-restarter", Host: "pod-restarter-0"}, events.Items[0].Source)
+	assert.Equal(t, "pod-restarter-0", events.Items[0].ReportingInstance)
+}
@@ -0,0 +1,355 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errChecksPassed is returned by a Pod check to delete the Pod without running the remaining checks
+var errChecksPassed = errors.New("Pod checks passed")
+
+// podCheck is a step of the decision pipeline matched Pods go through before they are deleted
+// the check returns an error annotated with a decision (see skip) if the Pod must not be deleted
+type podCheck struct {
+	name    string
+	enabled func(o *Options) bool // nil runs the check for every Pod
+	check   func(ctx context.Context, c *kubeClient, p *PodDetails) error
+}
+
+// podChecks is the decision pipeline of PodChecks, checks run in this order and the first one that fails decides
+// checks that need no API calls run first, checks that depend on time run last
+var podChecks = []podCheck{
+	{
+		// deleting mirror Pods is pointless, the kubelet recreates them right away from its static Pod manifests
+		name:    "mirror-pod",
+		enabled: func(o *Options) bool { return !o.AllowMirrorPods },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedMirrorPod, p.verifyPodNotMirror())
+		},
+	},
+	{
+		name:    "required-annotations",
+		enabled: func(o *Options) bool { return len(o.RequireAnnotations) > 0 },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedRequiredAnnotation, p.verifyPodAnnotations(c.opts.RequireAnnotations))
+		},
+	},
+	{
+		// owner-less Pods are only deleted if DeleteOrphans is set
+		name: "owner",
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			err := p.verifyPodHasOwner()
+			if err != nil && c.opts.DeleteOrphans {
+				log.Printf("WARNING: %v. Pod will not be recreated after deletion", err)
+				return nil
+			}
+			return skipIf(DecisionSkippedNoOwner, err)
+		},
+	},
+	{
+		name: "terminating",
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedTerminating, p.verifyPodScheduledToBeDeleted())
+		},
+	},
+	{
+		// deleting these Pods only sets their deletion timestamp and might interfere with the finalizer controllers
+		name:    "finalizers",
+		enabled: func(o *Options) bool { return !o.DeleteWithFinalizers },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedFinalizers, p.verifyPodHasNoFinalizers())
+		},
+	},
+	{
+		name:    "priority",
+		enabled: func(o *Options) bool { return o.SkipPriorityAbove != nil },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return c.verifyPodPriority(ctx, p, *c.opts.SkipPriorityAbove)
+		},
+	},
+	{
+		name:    "node",
+		enabled: func(o *Options) bool { return o.NodeName != "" },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedNode, p.verifyPodNode(c.opts.NodeName))
+		},
+	},
+	{
+		// bound Pending Pods wait for the kubelet, unscheduled Pending Pods wait for the scheduler
+		name: "bound-pending",
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			if p.Phase != v1.PodPending {
+				return nil
+			}
+			log.Printf("Pod %s/%s is Pending and %s", p.PodNamespace, p.PodName, p.pendingCategory())
+			if !c.opts.SkipBoundPending {
+				return nil
+			}
+			return skipIf(DecisionSkippedBoundPending, p.verifyPodNotBoundPending())
+		},
+	},
+	{
+		// the Pod status is not updated without a kubelet, so its phase does not tell if it is healthy
+		name:    "orphaned-node",
+		enabled: func(o *Options) bool { return o.OrphanedNodePods },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			if p.NodeName == "" {
+				return nil
+			}
+			exists, err := c.nodeExists(ctx, p.NodeName)
+			if err != nil {
+				log.Printf("WARNING: %v", err)
+			} else if !exists {
+				log.Printf("Pod %s/%s is assigned to Node %s that does not exist anymore", p.PodNamespace, p.PodName, p.NodeName)
+				return errChecksPassed
+			}
+			return nil
+		},
+	},
+	{
+		name:    "node-condition",
+		enabled: func(o *Options) bool { return o.RequireNodeCondition != "" },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedNodeCondition, c.verifyNodeCondition(ctx, p, c.opts.RequireNodeCondition))
+		},
+	},
+	{
+		// deleting these Pods does not help, the replacement Pod waits for the same volumes
+		name:    "pvc-pending",
+		enabled: func(o *Options) bool { return o.IgnorePVCPending },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedPVCPending, p.verifyPodNotWaitingForPVC())
+		},
+	},
+	{
+		// these Pods are expected to be scheduled on the node being provisioned
+		name:    "autoscaler",
+		enabled: func(o *Options) bool { return o.RespectAutoscaler },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedScaleUp, c.verifyNoScaleUp(ctx, p))
+		},
+	},
+	{
+		name:    "pending-age",
+		enabled: func(o *Options) bool { return len(o.MaxPendingByOwner) > 0 },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedPendingAge, c.verifyPendingAge(ctx, p))
+		},
+	},
+	{
+		name:    "schedule-message",
+		enabled: func(o *Options) bool { return o.ScheduleMessageRegex != nil },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedScheduleMessage, p.verifyScheduleMessage(c.opts.ScheduleMessageRegex))
+		},
+	},
+	{
+		// Pods actively worked on by schedulers or controllers might still recover
+		name:    "stable",
+		enabled: func(o *Options) bool { return o.MinStableDuration > 0 },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedRecentlyChanged, p.verifyPodStable(c.clock().Now(), c.opts.MinStableDuration))
+		},
+	},
+	{
+		// Pods with containers restarted too many times are deleted, regardless of Pod phase
+		name:    "container-restarts",
+		enabled: func(o *Options) bool { return o.MaxContainerRestarts > 0 },
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			err := p.verifyContainerRestarts(c.opts.MaxContainerRestarts)
+			if err != nil {
+				log.Println(err)
+				return errChecksPassed
+			}
+			return nil
+		},
+	},
+	{
+		// these Pods are not stuck in the failure they were matched for, restarting them does not help
+		name: "crashlooping",
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			return skipIf(DecisionSkippedCrashLooping, p.verifyPodNotCrashLooping())
+		},
+	},
+	{
+		// current state might differ from the state the Pod was matched in
+		name: "status",
+		check: func(ctx context.Context, c *kubeClient, p *PodDetails) error {
+			if p.verifyPodStatus() != nil {
+				return nil
+			}
+			msg := fmt.Sprintf("Pod is in a Healthy State: %s/%s", p.PodNamespace, p.PodName)
+			return skip(DecisionSelfHealed, errors.New(msg))
+		},
+	},
+}
+
+// runPodChecks runs the enabled podChecks in order, returns nil if the Pod can be deleted
+func (c *kubeClient) runPodChecks(ctx context.Context, p *PodDetails) error {
+	for _, pc := range podChecks {
+		if pc.enabled != nil && !pc.enabled(&c.opts) {
+			continue
+		}
+		err := pc.check(ctx, c, p)
+		if err == errChecksPassed {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipIf returns err annotated with decision, nil if err is nil
+func skipIf(decision Decision, err error) error {
+	if err == nil {
+		return nil
+	}
+	return skip(decision, err)
+}
+
+// podDeletion is a Pod going through the deletion gates
+// its details and owner are fetched at most once, only if needed
+type podDeletion struct {
+	pod       string
+	namespace string
+
+	details  *PodDetails
+	fetched  bool
+	owner    *Owner
+	resolved bool
+
+	// a deletion was recorded by the circuit breaker, it is released if the Pod is not deleted
+	breakerAllowed bool
+}
+
+// release gives back the deletion recorded by the circuit breaker, if any
+func (d *podDeletion) release(c *kubeClient) {
+	if d.breakerAllowed {
+		c.opts.CircuitBreaker.Release()
+		d.breakerAllowed = false
+	}
+}
+
+// podDetails returns the Pod details, nil if the Pod could not be fetched
+func (d *podDeletion) podDetails(ctx context.Context, c *kubeClient) *PodDetails {
+	if !d.fetched {
+		d.fetched = true
+		podInfo, err := c.GetPodDetails(ctx, d.pod, d.namespace)
+		if err == nil {
+			d.details = podInfo
+		}
+	}
+	return d.details
+}
+
+// podOwner returns the top-level owner of the Pod, nil if the Pod has no owner or it could not be resolved
+func (d *podDeletion) podOwner(ctx context.Context, c *kubeClient) *Owner {
+	if !d.resolved {
+		d.resolved = true
+		details := d.podDetails(ctx, c)
+		if details != nil && len(details.OwnerReferences) > 0 {
+			owner, err := c.resolveOwner(ctx, d.namespace, details.OwnerReferences)
+			if err != nil {
+				log.Println(err)
+			} else {
+				d.owner = owner
+			}
+		}
+	}
+	return d.owner
+}
+
+// deletionGate is a step of the decision pipeline checked Pods go through right before they are deleted
+// unlike Pod checks, gates decide on pod-restarter state (eg: budgets) rather than on the Pod
+type deletionGate struct {
+	name    string
+	enabled func(o *Options) bool
+	check   func(ctx context.Context, c *kubeClient, d *podDeletion) error
+}
+
+// deletionGates is the decision pipeline of DeletePod, gates run in this order and the first one that fails decides
+// gates that only look run first, the deletion budget is reserved last so it is not used up by Pods another gate skips
+var deletionGates = []deletionGate{
+	{
+		// Pods are deleted only once their delete-after time has passed
+		name:    "delete-ttl",
+		enabled: func(o *Options) bool { return o.DeleteTTL > 0 },
+		check: func(ctx context.Context, c *kubeClient, d *podDeletion) error {
+			return c.checkDeleteAfter(ctx, d.pod, d.namespace)
+		},
+	},
+	{
+		// confirm the deletion would be admitted (eg: by admission webhooks) without side effects
+		name:    "admission",
+		enabled: func(o *Options) bool { return o.DeleteDryRunCheck },
+		check: func(ctx context.Context, c *kubeClient, d *podDeletion) error {
+			err := c.retryThrottled(ctx, func() error {
+				return c.clientSet.CoreV1().Pods(d.namespace).Delete(
+					ctx,
+					d.pod,
+					metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}},
+				)
+			})
+			if err != nil {
+				return skip(DecisionSkippedAdmissionRejected, fmt.Errorf("Server-side dry-run deletion of Pod %s/%s was rejected: %w", d.namespace, d.pod, err))
+			}
+			return nil
+		},
+	},
+	{
+		// Pods of owners whose Pods keep matching after being deleted are not deleted again
+		name:    "persistent-failure",
+		enabled: func(o *Options) bool { return o.PersistentFailures != nil },
+		check: func(ctx context.Context, c *kubeClient, d *podDeletion) error {
+			owner := d.podOwner(ctx, c)
+			if owner == nil {
+				return nil
+			}
+			return c.checkPersistentFailure(ctx, owner, d.pod, d.namespace)
+		},
+	},
+	{
+		name:    "circuit-breaker",
+		enabled: func(o *Options) bool { return o.CircuitBreaker != nil },
+		check: func(ctx context.Context, c *kubeClient, d *podDeletion) error {
+			if !c.opts.CircuitBreaker.Allow() {
+				return skip(DecisionSkippedCircuitBreaker, fmt.Errorf("Skipping Pod %s/%s: circuit breaker is open, deletions are paused", d.namespace, d.pod))
+			}
+			d.breakerAllowed = true
+			return nil
+		},
+	},
+	{
+		// the reserved deletion is released if the deletion fails
+		name:    "deletion-budget",
+		enabled: func(o *Options) bool { return o.DeletionBudget != nil },
+		check: func(ctx context.Context, c *kubeClient, d *podDeletion) error {
+			if !c.opts.DeletionBudget.Reserve() {
+				return skip(DecisionSkippedBudgetExhausted, fmt.Errorf("Skipping Pod %s/%s: deletion budget is exhausted", d.namespace, d.pod))
+			}
+			return nil
+		},
+	},
+}
+
+// runDeletionGates runs the enabled deletionGates in order, returns nil if the Pod can be deleted
+// a deletion recorded by an earlier gate is released if a later gate fails
+func (c *kubeClient) runDeletionGates(ctx context.Context, d *podDeletion) error {
+	for _, gate := range deletionGates {
+		if !gate.enabled(&c.opts) {
+			continue
+		}
+		err := gate.check(ctx, c, d)
+		if err != nil {
+			d.release(c)
+			return err
+		}
+	}
+	return nil
+}
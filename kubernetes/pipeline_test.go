@@ -0,0 +1,114 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/clock"
+)
+
+// TestDecisionPipelineOrder asserts the documented order of the decision pipeline (see readme)
+func TestDecisionPipelineOrder(t *testing.T) {
+	var checks []string
+	for _, pc := range podChecks {
+		checks = append(checks, pc.name)
+	}
+	assert.Equal(t, []string{
+		"mirror-pod",
+		"required-annotations",
+		"owner",
+		"terminating",
+		"finalizers",
+		"priority",
+		"node",
+		"bound-pending",
+		"orphaned-node",
+		"node-condition",
+		"pvc-pending",
+		"autoscaler",
+		"pending-age",
+		"schedule-message",
+		"stable",
+		"container-restarts",
+		"crashlooping",
+		"status",
+	}, checks)
+
+	var gates []string
+	for _, gate := range deletionGates {
+		gates = append(gates, gate.name)
+	}
+	assert.Equal(t, []string{
+		"delete-ttl",
+		"admission",
+		"persistent-failure",
+		"circuit-breaker",
+		"deletion-budget",
+	}, gates)
+}
+
+func TestRunPodChecks(t *testing.T) {
+	var ctx = context.TODO()
+	// an owner-less Pod with finalizers fails the owner check first
+	pod := &PodDetails{PodName: "foo", PodNamespace: "default", Phase: corev1.PodPending, Finalizers: []string{"example.com/finalizer"}}
+	client := kubeClient{clientSet: fake.NewSimpleClientset()}
+
+	err := client.runPodChecks(ctx, pod)
+	assert.Equal(t, DecisionSkippedNoOwner, DecisionOf(err))
+
+	client.opts.DeleteOrphans = true
+	err = client.runPodChecks(ctx, pod)
+	assert.Equal(t, DecisionSkippedFinalizers, DecisionOf(err))
+
+	// a check can delete the Pod without running the remaining checks
+	client.opts.DeleteWithFinalizers = true
+	client.opts.MaxContainerRestarts = 1
+	pod.Phase = corev1.PodRunning
+	pod.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", Ready: true, RestartCount: 5}}
+	require.NoError(t, client.runPodChecks(ctx, pod))
+}
+
+func TestRunPodChecksBoundPending(t *testing.T) {
+	var ctx = context.TODO()
+	pod := &PodDetails{PodName: "foo", PodNamespace: "default", Phase: corev1.PodPending, NodeName: "node1"}
+	client := kubeClient{clientSet: fake.NewSimpleClientset(), opts: Options{DeleteOrphans: true}}
+
+	// bound Pending Pods are deleted unless SkipBoundPending is set
+	require.NoError(t, client.runPodChecks(ctx, pod))
+
+	client.opts.SkipBoundPending = true
+	err := client.runPodChecks(ctx, pod)
+	assert.Equal(t, DecisionSkippedBoundPending, DecisionOf(err))
+
+	// unscheduled Pending Pods are still deleted
+	pod.NodeName = ""
+	require.NoError(t, client.runPodChecks(ctx, pod))
+}
+
+func TestRunDeletionGates(t *testing.T) {
+	var ctx = context.TODO()
+	breaker := NewCircuitBreaker(1, time.Hour, time.Hour, clock.RealClock{})
+	require.True(t, breaker.Allow())
+	budget := NewDeletionBudget(1)
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "uid1")),
+		opts:      Options{CircuitBreaker: breaker, DeletionBudget: budget},
+	}
+
+	// the open circuit breaker skips the Pod before a deletion is reserved from the budget
+	err := client.runDeletionGates(ctx, &podDeletion{pod: "foo", namespace: "default"})
+	assert.Equal(t, DecisionSkippedCircuitBreaker, DecisionOf(err))
+	assert.True(t, budget.Reserve(), "the budget should not be used up by Pods skipped by an earlier gate")
+
+	// Pod details are fetched once for all gates
+	d := &podDeletion{pod: "foo", namespace: "default"}
+	require.NotNil(t, d.podDetails(ctx, &client))
+	require.NoError(t, client.clientSet.CoreV1().Pods("default").Delete(ctx, "foo", metav1.DeleteOptions{}))
+	assert.NotNil(t, d.podDetails(ctx, &client))
+}
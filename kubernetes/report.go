@@ -0,0 +1,165 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// report formats supported by WriteReport
+const (
+	ReportFormatTable = "table"
+	ReportFormatCSV   = "csv"
+	ReportFormatJSON  = "json"
+	ReportFormatYAML  = "yaml"
+)
+
+// ReportFormats lists the report formats supported by WriteReport
+var ReportFormats = []string{ReportFormatTable, ReportFormatCSV, ReportFormatJSON, ReportFormatYAML}
+
+// ReportEntry holds a Pod that would be deleted and the Event message it matched
+type ReportEntry struct {
+	PodName      string
+	PodNamespace string
+	OwnerName    string
+	OwnerKind    string
+	Age          time.Duration
+	MatchedError string
+	Decision     Decision
+}
+
+// reportRecord is the stable schema of a report entry in the json and yaml formats
+type reportRecord struct {
+	Pod          string   `json:"pod"`
+	Namespace    string   `json:"namespace"`
+	Owner        string   `json:"owner"`
+	Kind         string   `json:"kind"`
+	Age          string   `json:"age"`
+	MatchedError string   `json:"matchedError"`
+	Decision     Decision `json:"decision"`
+}
+
+// GenerateReport returns the Pods that match Event Reason and Error Message and pass all PodChecks, without deleting them
+// entries are sorted by namespace and Pod name
+func (c *kubeClient) GenerateReport(ctx context.Context, namespace, eventReason, errorMessage string) ([]ReportEntry, error) {
+	eventList, err := c.getMatchingEvents(ctx, namespace, eventReason, errorMessage, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ReportEntry
+	seen := make(map[string]bool)
+	for _, event := range eventList {
+		if seen[string(event.UID)] {
+			continue
+		}
+		seen[string(event.UID)] = true
+
+		err := c.PodChecks(ctx, event.PodName, event.PodNamespace)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		podInfo, err := c.GetPodDetails(ctx, event.PodName, event.PodNamespace)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		entry := ReportEntry{
+			PodName:      podInfo.PodName,
+			PodNamespace: podInfo.PodNamespace,
+			MatchedError: event.Message,
+			Decision:     DecisionDryRun,
+		}
+		// fixtures might not set a creation timestamp
+		if !podInfo.CreationTimestamp.IsZero() {
+			entry.Age = c.clock().Since(podInfo.CreationTimestamp).Truncate(time.Second)
+		}
+		if ref := controllerRef(podInfo.OwnerReferences); ref != nil {
+			entry.OwnerName = ref.Name
+			entry.OwnerKind = ref.Kind
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].PodNamespace != entries[j].PodNamespace {
+			return entries[i].PodNamespace < entries[j].PodNamespace
+		}
+		return entries[i].PodName < entries[j].PodName
+	})
+	return entries, nil
+}
+
+// WriteReport writes report entries to w as an aligned table, CSV, JSON or YAML
+func WriteReport(w io.Writer, entries []ReportEntry, format string) error {
+	if format == ReportFormatJSON || format == ReportFormatYAML {
+		return writeReportRecords(w, entries, format)
+	}
+
+	header := []string{"NAMESPACE", "POD", "OWNER KIND", "AGE", "MATCHED ERROR"}
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{entry.PodNamespace, entry.PodName, entry.OwnerKind, entry.Age.String(), entry.MatchedError})
+	}
+
+	switch format {
+	case ReportFormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, row := range append([][]string{header}, rows...) {
+			for i, value := range row {
+				if i > 0 {
+					fmt.Fprint(tw, "\t")
+				}
+				fmt.Fprint(tw, value)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	case ReportFormatCSV:
+		cw := csv.NewWriter(w)
+		return cw.WriteAll(append([][]string{header}, rows...))
+	default:
+		return fmt.Errorf("Report format %q is not supported, use one of %v", format, ReportFormats)
+	}
+}
+
+// writeReportRecords writes report entries to w as a JSON or YAML list
+// an empty report is written as an empty list, so consumers do not need to handle null
+func writeReportRecords(w io.Writer, entries []ReportEntry, format string) error {
+	records := make([]reportRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, reportRecord{
+			Pod:          entry.PodName,
+			Namespace:    entry.PodNamespace,
+			Owner:        entry.OwnerName,
+			Kind:         entry.OwnerKind,
+			Age:          entry.Age.String(),
+			MatchedError: entry.MatchedError,
+			Decision:     entry.Decision,
+		})
+	}
+
+	var data []byte
+	var err error
+	if format == ReportFormatJSON {
+		data, err = json.MarshalIndent(records, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(records)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not encode report as %s: %w", format, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
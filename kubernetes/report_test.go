@@ -0,0 +1,111 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGenerateReport(t *testing.T) {
+	message := "container veth name provided (eth0) already exists"
+	older := makeOwnedPod("pod_b", "default", corev1.PodPending, nil)
+	older.ObjectMeta.CreationTimestamp = metav1.Time{Time: time.Now().Add(-time.Hour)}
+	mockedObjects := []runtime.Object{
+		older,
+		makeOwnedPod("pod_a", "default", corev1.PodPending, nil),
+		makeOwnedPod("pod_c", "test", corev1.PodPending, nil),
+		makePod("orphan", "default", 1, corev1.PodPending, "orphan"),
+		makeOwnedPod("healthy", "default", corev1.PodRunning, nil),
+		makeEvent("pod_c", "test", "FailedCreatePodSandBox", message, "Warning", 1, "pod_c"),
+		makeEvent("pod_b", "default", "FailedCreatePodSandBox", message, "Warning", 1, "pod_b"),
+		makeEvent("pod_b", "default", "FailedCreatePodSandBox", message, "Warning", 2, "pod_b"),
+		makeEvent("pod_a", "default", "FailedCreatePodSandBox", message, "Warning", 1, "pod_a"),
+		makeEvent("orphan", "default", "FailedCreatePodSandBox", message, "Warning", 1, "orphan"),
+		makeEvent("healthy", "default", "FailedCreatePodSandBox", message, "Warning", 1, "healthy"),
+	}
+	client := kubeClient{clientSet: fake.NewSimpleClientset(mockedObjects...)}
+	var ctx = context.TODO()
+
+	entries, err := client.GenerateReport(ctx, "", "FailedCreatePodSandBox", message)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "default/pod_a", entries[0].PodNamespace+"/"+entries[0].PodName)
+	assert.Equal(t, "default/pod_b", entries[1].PodNamespace+"/"+entries[1].PodName)
+	assert.Equal(t, "test/pod_c", entries[2].PodNamespace+"/"+entries[2].PodName)
+	assert.Equal(t, "ReplicaSet", entries[1].OwnerKind)
+	assert.Equal(t, message, entries[1].MatchedError)
+	assert.GreaterOrEqual(t, entries[1].Age, time.Hour)
+}
+
+func TestWriteReport(t *testing.T) {
+	entries := []ReportEntry{
+		{PodName: "pod_1", PodNamespace: "default", OwnerName: "foo-rs", OwnerKind: "ReplicaSet", Age: 90 * time.Second, MatchedError: "already exists, eth0", Decision: DecisionDryRun},
+	}
+	tests := map[string]struct {
+		format   string
+		expected string
+		wantErr  bool
+	}{
+		"Verify table format": {
+			format: ReportFormatTable,
+			expected: "NAMESPACE   POD     OWNER KIND   AGE     MATCHED ERROR\n" +
+				"default     pod_1   ReplicaSet   1m30s   already exists, eth0\n",
+		},
+		"Verify csv format": {
+			format: ReportFormatCSV,
+			expected: "NAMESPACE,POD,OWNER KIND,AGE,MATCHED ERROR\n" +
+				"default,pod_1,ReplicaSet,1m30s,\"already exists, eth0\"\n",
+		},
+		"Verify json format": {
+			format: ReportFormatJSON,
+			expected: `[
+  {
+    "pod": "pod_1",
+    "namespace": "default",
+    "owner": "foo-rs",
+    "kind": "ReplicaSet",
+    "age": "1m30s",
+    "matchedError": "already exists, eth0",
+    "decision": "DRY_RUN"
+  }
+]
+`,
+		},
+		"Verify yaml format": {
+			format: ReportFormatYAML,
+			expected: `- age: 1m30s
+  decision: DRY_RUN
+  kind: ReplicaSet
+  matchedError: already exists, eth0
+  namespace: default
+  owner: foo-rs
+  pod: pod_1
+`,
+		},
+		"Verify unsupported format returns error": {
+			format:  "xml",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteReport(&buf, entries, tc.format)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	v1 "k8s.io/api/core/v1"
+	e "k8s.io/apimachinery/pkg/api/errors"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+)
+
+// stuckTerminatingReason is the reason of the Event recorded on deleted Pods that are stuck terminating
+const stuckTerminatingReason = "StuckTerminating"
+
+// trackedDeletion is a Pod deleted by pod-restarter that is not gone yet
+type trackedDeletion struct {
+	namespace string
+	pod       string
+	uid       types.UID
+	deletedAt time.Time
+	reported  bool
+}
+
+// TerminatingTracker tracks Pods deleted by pod-restarter until they are gone
+// Pods still there after the grace window (eg: stuck on finalizers) are reported once, they need manual intervention
+type TerminatingTracker struct {
+	mu      sync.Mutex
+	grace   time.Duration
+	deleted map[string]*trackedDeletion // by namespace/name
+	clock   clock.PassiveClock
+}
+
+// NewTerminatingTracker returns a TerminatingTracker that reports deleted Pods still there after grace, measured with clk
+func NewTerminatingTracker(grace time.Duration, clk clock.PassiveClock) *TerminatingTracker {
+	return &TerminatingTracker{
+		grace:   grace,
+		deleted: make(map[string]*trackedDeletion),
+		clock:   clk,
+	}
+}
+
+// Record starts tracking a deleted Pod
+func (t *TerminatingTracker) Record(namespace, pod string, uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.deleted[namespace+"/"+pod] = &trackedDeletion{namespace: namespace, pod: pod, uid: uid, deletedAt: t.clock.Now()}
+}
+
+// due returns the tracked Pods deleted longer than grace ago that were not reported yet, in deletion order
+func (t *TerminatingTracker) due() []trackedDeletion {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var due []trackedDeletion
+	for _, d := range t.deleted {
+		if !d.reported && t.clock.Now().Sub(d.deletedAt) >= t.grace {
+			due = append(due, *d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].deletedAt.Before(due[j].deletedAt) })
+	return due
+}
+
+// forget stops tracking a Pod that is gone
+func (t *TerminatingTracker) forget(namespace, pod string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.deleted, namespace+"/"+pod)
+}
+
+// markReported remembers a Pod was reported, it is tracked until it is gone so it is not reported again
+func (t *TerminatingTracker) markReported(namespace, pod string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d, ok := t.deleted[namespace+"/"+pod]; ok {
+		d.reported = true
+	}
+}
+
+// CheckStuckTerminating reports Pods deleted longer than the TerminatingTracker grace ago that are still there
+// these Pods are logged, counted and get a Warning Event, returns the number of Pods reported
+func (c *kubeClient) CheckStuckTerminating(ctx context.Context) int {
+	tracker := c.opts.TerminatingTracker
+	if tracker == nil {
+		return 0
+	}
+
+	reported := 0
+	for _, d := range tracker.due() {
+		podInfo, err := c.GetPodDetails(ctx, d.pod, d.namespace)
+		// a replacement Pod with the same name (eg: StatefulSet Pods) has a different UID
+		if e.IsNotFound(err) || (err == nil && d.uid != "" && podInfo.UID != d.uid) {
+			tracker.forget(d.namespace, d.pod)
+			continue
+		} else if err != nil {
+			log.Printf("WARNING: Could not check if deleted Pod %s/%s is gone: %v", d.namespace, d.pod, err)
+			continue
+		}
+
+		message := fmt.Sprintf(
+			"Pod is stuck terminating %v after it was deleted by pod-restarter (finalizers: %v), it needs manual intervention",
+			tracker.clock.Now().Sub(d.deletedAt).Truncate(time.Second), podInfo.Finalizers,
+		)
+		log.Printf("WARNING: STUCK TERMINATING: %s: %s/%s", message, d.namespace, d.pod)
+		metrics.PodStuckTerminating(d.namespace)
+		tracker.markReported(d.namespace, d.pod)
+		reported++
+
+		// recording the Event is best effort, the warning and the metric are already out
+		err = c.recordEvent(ctx, v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  d.namespace,
+			Name:       d.pod,
+			UID:        podInfo.UID,
+		}, stuckTerminatingReason, message)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return reported
+}
@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestCheckStuckTerminating(t *testing.T) {
+	var ctx = context.TODO()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	tracker := NewTerminatingTracker(10*time.Minute, fakeClock)
+
+	stuck := makePod("stuck", "default", 1, corev1.PodRunning, "uid1")
+	stuck.Finalizers = []string{"example.com/cleanup"}
+	clientSet := fake.NewSimpleClientset(stuck, makePod("gone", "default", 1, corev1.PodRunning, "uid2"))
+	// the Pod with a finalizer is only marked for deletion
+	clientSet.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return action.(k8stesting.DeleteAction).GetName() == "stuck", nil, nil
+	})
+	client := kubeClient{clientSet: clientSet, opts: Options{TerminatingTracker: tracker}}
+
+	require.NoError(t, client.DeletePod(ctx, "stuck", "default"))
+	require.NoError(t, client.DeletePod(ctx, "gone", "default"))
+
+	// Pods are not reported within the grace window
+	assert.Equal(t, 0, client.CheckStuckTerminating(ctx))
+
+	// the Pod still there after the grace window is reported once, with an Event
+	fakeClock.Step(11 * time.Minute)
+	assert.Equal(t, 1, client.CheckStuckTerminating(ctx))
+	assert.Equal(t, 0, client.CheckStuckTerminating(ctx))
+	events, err := clientSet.CoreV1().Events("default").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	assert.Equal(t, stuckTerminatingReason, events.Items[0].Reason)
+	assert.Equal(t, "stuck", events.Items[0].InvolvedObject.Name)
+	assert.Contains(t, events.Items[0].Message, "example.com/cleanup")
+
+	// Pods that are gone are not tracked anymore
+	assert.Len(t, tracker.deleted, 1)
+	require.NoError(t, clientSet.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), "default", "stuck"))
+	tracker.Record("default", "stuck", "uid1")
+	fakeClock.Step(11 * time.Minute)
+	assert.Equal(t, 0, client.CheckStuckTerminating(ctx))
+	assert.Empty(t, tracker.deleted)
+}
+
+func TestCheckStuckTerminatingReplacedPod(t *testing.T) {
+	var ctx = context.TODO()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	tracker := NewTerminatingTracker(time.Minute, fakeClock)
+	tracker.Record("default", "db-0", "uid-old")
+
+	// a replacement Pod with the same name is not the deleted Pod
+	client := kubeClient{
+		clientSet: fake.NewSimpleClientset(makePod("db-0", "default", 1, corev1.PodPending, "uid-new")),
+		opts:      Options{TerminatingTracker: tracker},
+	}
+	fakeClock.Step(2 * time.Minute)
+	assert.Equal(t, 0, client.CheckStuckTerminating(ctx))
+	assert.Empty(t, tracker.deleted)
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	e "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultThrottleDelay is how long a throttled request waits before it is retried, if the API server does not suggest a delay
+const defaultThrottleDelay = time.Second
+
+// retryThrottled calls fn and retries it up to ThrottleRetries times while the API server throttles it (429 Too Many Requests)
+// client-go already retries throttled requests, this covers the requests it gives up on when the API server is under heavy load
+// every retry waits for the Retry-After delay suggested by the API server, instead of a local backoff
+func (c *kubeClient) retryThrottled(ctx context.Context, fn func() error) error {
+	err := fn()
+	for retry := 1; retry <= c.opts.ThrottleRetries && e.IsTooManyRequests(err); retry++ {
+		delay := throttleDelay(err)
+		log.Printf("Throttled by the API server, retrying after %v (retry %d/%d): %v", delay, retry, c.opts.ThrottleRetries, err)
+		if c.waitThrottled(ctx, delay) != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// throttleDelay returns the Retry-After delay suggested by the API server for a throttled request, or defaultThrottleDelay
+func throttleDelay(err error) time.Duration {
+	if seconds, ok := e.SuggestsClientDelay(err); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultThrottleDelay
+}
+
+// waitThrottled waits for d, returns ctx error if ctx is cancelled first
+func (c *kubeClient) waitThrottled(ctx context.Context, d time.Duration) error {
+	timer := c.clock().NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// throttleLoggingTransport logs throttled requests before client-go retries them
+type throttleLoggingTransport struct {
+	next http.RoundTripper
+}
+
+// logThrottled returns a transport that logs requests throttled by the API server
+func logThrottled(rt http.RoundTripper) http.RoundTripper {
+	return &throttleLoggingTransport{next: rt}
+}
+
+func (t *throttleLoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		log.Printf("WARNING: throttled by the API server: %s %s (Retry-After: %q)", req.Method, req.URL.Path, resp.Header.Get("Retry-After"))
+	}
+	return resp, err
+}
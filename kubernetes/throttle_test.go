@@ -0,0 +1,215 @@
+package kubernetes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestRetryThrottled(t *testing.T) {
+	var ctx = context.TODO()
+
+	tests := map[string]struct {
+		throttled     int
+		retries       int
+		expectedCalls int
+		expectedWaits []time.Duration
+		wantErr       bool
+	}{
+		"Throttled once, retried after Retry-After": {
+			throttled:     1,
+			retries:       3,
+			expectedCalls: 2,
+			expectedWaits: []time.Duration{2 * time.Second},
+		},
+		"Throttled more than retries": {
+			throttled:     5,
+			retries:       2,
+			expectedCalls: 3,
+			expectedWaits: []time.Duration{2 * time.Second, 2 * time.Second},
+			wantErr:       true,
+		},
+		"Retries disabled": {
+			throttled:     1,
+			retries:       0,
+			expectedCalls: 1,
+			wantErr:       true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientSet := fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1"))
+			calls := 0
+			clientSet.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				calls++
+				if calls <= tc.throttled {
+					return true, nil, apierrors.NewTooManyRequests("slow down", 2)
+				}
+				return false, nil, nil
+			})
+			fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+			client := kubeClient{
+				clientSet: clientSet,
+				opts:      Options{ThrottleRetries: tc.retries, Clock: fakeClock},
+			}
+
+			var pod *PodDetails
+			var err error
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				pod, err = client.GetPodDetails(ctx, "foo", "default")
+			}()
+			// every retry waits for the Retry-After delay, not less
+			for _, wait := range tc.expectedWaits {
+				require.Eventually(t, fakeClock.HasWaiters, time.Second, time.Millisecond)
+				fakeClock.Step(wait - time.Millisecond)
+				assert.True(t, fakeClock.HasWaiters())
+				fakeClock.Step(time.Millisecond)
+			}
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("throttled request was not retried after the expected waits")
+			}
+			assert.Equal(t, tc.expectedCalls, calls)
+			if tc.wantErr {
+				assert.True(t, apierrors.IsTooManyRequests(err), err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "foo", pod.PodName)
+			}
+		})
+	}
+}
+
+func TestRetryThrottledCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	calls := 0
+	client := kubeClient{opts: Options{ThrottleRetries: 3}}
+	err := client.retryThrottled(ctx, func() error {
+		calls++
+		return apierrors.NewTooManyRequests("slow down", 60)
+	})
+	assert.True(t, apierrors.IsTooManyRequests(err), err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestThrottleDelay(t *testing.T) {
+	assert.Equal(t, 5*time.Second, throttleDelay(apierrors.NewTooManyRequests("slow down", 5)))
+	assert.Equal(t, defaultThrottleDelay, throttleDelay(apierrors.NewTooManyRequests("slow down", 0)))
+}
+
+func TestLogThrottled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := logThrottled(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+// runWithFakeClock runs fn, stepping fakeClock while fn waits on it
+func runWithFakeClock(t *testing.T, fakeClock *clocktesting.FakeClock, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			return
+		case <-timeout:
+			t.Fatal("fn did not return")
+		case <-time.After(time.Millisecond):
+			if fakeClock.HasWaiters() {
+				fakeClock.Step(time.Second)
+			}
+		}
+	}
+}
+
+func TestRetryThrottledOwners(t *testing.T) {
+	var ctx = context.TODO()
+	isController := true
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web-rs",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Controller: &isController},
+		},
+	}}
+	first := makeEvent("web", "default", "FailedCreate", "exceeded quota: compute-resources", "Warning", 1, "web")
+	first.InvolvedObject.Kind = "Deployment"
+	second := first.DeepCopy()
+	second.Name = "web.second"
+	clientSet := fake.NewSimpleClientset(replicaSet)
+
+	// every call is throttled once, Events are returned in two pages
+	throttled := map[string]bool{}
+	throttleOnce := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		key := action.GetVerb() + "/" + action.GetResource().Resource
+		if list, ok := action.(k8stesting.ListAction); ok {
+			key += "/" + list.GetListRestrictions().Fields.String()
+		}
+		if !throttled[key] {
+			throttled[key] = true
+			return true, nil, apierrors.NewTooManyRequests("slow down", 1)
+		}
+		return false, nil, nil
+	}
+	clientSet.PrependReactor("get", "replicasets", throttleOnce)
+	pages := 0
+	clientSet.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if handled, obj, err := throttleOnce(action); handled {
+			return handled, obj, err
+		}
+		// the fake clientset does not paginate, nor record the continue token
+		pages++
+		if pages == 1 {
+			return true, &corev1.EventList{Items: []corev1.Event{*first}, ListMeta: metav1.ListMeta{Continue: "page-2"}}, nil
+		}
+		return true, &corev1.EventList{Items: []corev1.Event{*second}}, nil
+	})
+
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+	client := kubeClient{clientSet: clientSet, opts: Options{ThrottleRetries: 1, ListPageSize: 1, Clock: fakeClock}}
+
+	var chain []Owner
+	var events []PodEvent
+	var err error
+	runWithFakeClock(t, fakeClock, func() {
+		chain, err = client.ownerChain(ctx, "default", []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-rs", Controller: &isController}})
+		if err == nil {
+			events, err = client.getOwnerEvents(ctx, chain[1], "FailedCreate", "exceeded quota")
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Deployment/default/web", chain[1].String())
+	assert.Len(t, events, 2)
+	assert.Equal(t, 2, pages)
+}
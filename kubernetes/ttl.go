@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+)
+
+// deleteAfterAnnotation is set on matched Pods when DeleteTTL is enabled, Pods are only deleted once this time has passed
+// operators can cancel the deletion by setting the annotation to a value that is not a timestamp, eg: "never"
+const deleteAfterAnnotation = "pod-restarter.io/delete-after"
+
+// checkDeleteAfter returns nil if the Pod delete-after time has passed
+// Pods without the annotation are annotated with now + DeleteTTL and skipped until a later cycle
+func (c *kubeClient) checkDeleteAfter(ctx context.Context, pod, namespace string) error {
+	podInfo, err := c.GetPodDetails(ctx, pod, namespace)
+	if err != nil {
+		return err
+	}
+
+	value, ok := podInfo.Annotations[deleteAfterAnnotation]
+	if !ok {
+		deleteAfter := c.clock().Now().Add(c.opts.DeleteTTL).UTC().Format(time.RFC3339)
+		err := c.annotatePod(ctx, pod, namespace, deleteAfterAnnotation, deleteAfter)
+		if err != nil {
+			return err
+		}
+		return skip(DecisionDeleteScheduled, fmt.Errorf("Pod %s/%s will be deleted after %s if it still matches", namespace, pod, deleteAfter))
+	}
+
+	deleteAfter, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return skip(DecisionSkippedAnnotation, fmt.Errorf("Skipping Pod %s/%s: annotation %s=%q is not a timestamp", namespace, pod, deleteAfterAnnotation, value))
+	}
+	if c.clock().Now().Before(deleteAfter) {
+		return skip(DecisionSkippedDeleteTTL, fmt.Errorf("Skipping Pod %s/%s: Pod will be deleted after %s", namespace, pod, value))
+	}
+	return nil
+}
+
+// annotatePod sets an annotation on a Pod
+func (c *kubeClient) annotatePod(ctx context.Context, pod, namespace, key, value string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{key: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = c.retryThrottled(ctx, func() error {
+		_, err := c.clientSet.CoreV1().Pods(namespace).Patch(ctx, pod, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not annotate Pod %s/%s: %w", namespace, pod, err)
+	}
+	log.Printf("Annotated Pod %s/%s with %s=%s", namespace, pod, key, value)
+	return nil
+}
@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDeletePodDeleteTTL(t *testing.T) {
+	tests := map[string]struct {
+		annotations      map[string]string
+		expectedDecision Decision
+		expectDeleted    bool
+	}{
+		"Verify Pod is annotated on first match": {
+			annotations:      nil,
+			expectedDecision: DecisionDeleteScheduled,
+		},
+		"Verify Pod is not deleted before delete-after time": {
+			annotations:      map[string]string{deleteAfterAnnotation: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+			expectedDecision: DecisionSkippedDeleteTTL,
+		},
+		"Verify Pod is deleted after delete-after time": {
+			annotations:   map[string]string{deleteAfterAnnotation: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)},
+			expectDeleted: true,
+		},
+		"Verify Pod is not deleted when operator overrides annotation": {
+			annotations:      map[string]string{deleteAfterAnnotation: "never"},
+			expectedDecision: DecisionSkippedAnnotation,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ctx = context.TODO()
+			pod := makePod("foo", "default", 1, corev1.PodPending, "abc1")
+			pod.ObjectMeta.Annotations = tc.annotations
+			clt := kubeClient{
+				clientSet: fake.NewSimpleClientset(pod),
+				opts:      Options{DeleteTTL: 10 * time.Minute},
+			}
+
+			err := clt.DeletePod(ctx, "foo", "default")
+			if tc.expectDeleted {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedDecision, DecisionOf(err))
+
+			podInfo, err := clt.GetPodDetails(ctx, "foo", "default")
+			require.NoError(t, err, "Pod should not have been deleted")
+			deleteAfter, err := time.Parse(time.RFC3339, podInfo.Annotations[deleteAfterAnnotation])
+			if tc.annotations == nil {
+				require.NoError(t, err)
+				assert.WithinDuration(t, time.Now().Add(10*time.Minute), deleteAfter, time.Minute)
+			}
+		})
+	}
+}
+
+func TestDeletePodDeleteTTLFakeClock(t *testing.T) {
+	var ctx = context.TODO()
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+	clt := kubeClient{
+		clientSet: fake.NewSimpleClientset(makePod("foo", "default", 1, corev1.PodPending, "abc1")),
+		opts:      Options{DeleteTTL: 10 * time.Minute, Clock: fakeClock},
+	}
+
+	err := clt.DeletePod(ctx, "foo", "default")
+	assert.Equal(t, DecisionDeleteScheduled, DecisionOf(err))
+	podInfo, err := clt.GetPodDetails(ctx, "foo", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "2022-11-20T10:10:00Z", podInfo.Annotations[deleteAfterAnnotation])
+
+	// one second before the delete-after time the Pod is still skipped
+	fakeClock.SetTime(fakeClock.Now().Add(10*time.Minute - time.Second))
+	err = clt.DeletePod(ctx, "foo", "default")
+	assert.Equal(t, DecisionSkippedDeleteTTL, DecisionOf(err))
+
+	fakeClock.SetTime(fakeClock.Now().Add(time.Second))
+	require.NoError(t, clt.DeletePod(ctx, "foo", "default"))
+}
@@ -1,30 +1,133 @@
 package kubernetes
 
 import (
+	"regexp"
+	"sync"
 	"time"
 
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/clock"
 )
 
 // kubeClient holds K8s parameters
 type kubeClient struct {
 	clientSet kubernetes.Interface
+	opts      Options
+
+	// node conditions are cached for the lifetime of the client, which is created every cycle
+	nodeConditionsMu sync.Mutex
+	nodeConditions   map[string][]v1.NodeCondition
+	missingNodes     map[string]error // Nodes that do not exist, by name
+
+	// Pods checked by the client, so every Pod is evaluated at most once per cycle
+	// and details of the Pods that passed the checks, by namespace/name, so DeletePod does not fetch them again
+	checkedPodsMu  sync.Mutex
+	checkedPods    map[string]bool
+	checkedDetails map[string]*PodDetails
+
+	// owner chains resolved in this cycle, by kind/namespace/name of the ReplicaSet or Job, and top-level owners of deleted Pods, by namespace/name
+	ownersMu      sync.Mutex
+	ownerChains   map[string][]Owner
+	deletedOwners map[string]string
+
+	// Events Pods were matched for in this cycle, by namespace/name
+	matchedEvents map[string]PodEvent
+
+	// categories extracted by ErrorRegex from the Events Pods were matched for in this cycle, by namespace/name
+	matchedCategories map[string]string
+
+	// ages of the Pods matched in this cycle, by namespace
+	matchedPodAges map[string][]time.Duration
+
+	// API calls made by the client (nil for clients not created by NewK8sClient)
+	apiCalls *apiCallCounter
+}
+
+// Options holds pod-restarter settings used by kubeClient
+type Options struct {
+	UserAgent             string                   // user agent sent with every request to the kubernetes API
+	InstanceName          string                   // name of this pod-restarter instance, set on the Events it records (empty omits it)
+	MaxContainerRestarts  int32                    // delete Pods with containers restarted more than this many times, regardless of phase (0 disables)
+	StatusFallback        bool                     // match Pod container states and conditions when listing Events is forbidden
+	ErrorMessagesAll      []string                 // match Pods only if all messages appear across their Events, regardless of Reason
+	ErrorMessages         *ErrorMessages           // also match Events with any of these messages, eg: loaded from --error-message-file (nil disables)
+	ErrorRegex            *regexp.Regexp           // also match Events with a message matching this regex, its reason group is the category of the Pod (nil disables)
+	DumpDir               string                   // directory where Pod manifests are saved before deletion (empty disables)
+	SkipPriorityAbove     *int32                   // skip Pods with priority at or above this value (nil disables)
+	ListPageSize          int64                    // maximum number of items returned by a single List call (0 disables pagination)
+	AllowCachedList       bool                     // retry a List that timed out from the API server cache, which can be slightly stale
+	ThrottleRetries       int                      // retry requests throttled by the API server (429) this many times, after their Retry-After delay (0 disables)
+	VerifyDeletion        bool                     // wait for deleted Pods to be gone
+	VerifyDeletionTimeout time.Duration            // how long to wait for a deleted Pod to be gone
+	CheckOwnerEvents      bool                     // also match Events of the owning controllers of Pods, eg: ReplicaSet and Deployment
+	EventSource           string                   // match only Events reported by this source component, eg: kubelet (empty matches all)
+	EventType             string                   // match only Events of this type, Normal or Warning (empty matches all)
+	TargetContainer       string                   // match only Events about this container, eg: a sidecar (empty matches all)
+	NodeName              string                   // delete only Pods assigned to this node (empty matches all)
+	SkipBoundPending      bool                     // skip Pending Pods already bound to a node, delete only unscheduled Pending Pods
+	RequireNodeCondition  v1.NodeConditionType     // delete only Pending Pods assigned to a node with this condition True, eg: MemoryPressure (empty disables)
+	DeleteWithFinalizers  bool                     // delete Pods with finalizers, their deletion waits for the finalizers to be removed
+	AllowMirrorPods       bool                     // delete mirror Pods of static Pods, the kubelet recreates them right away
+	RequireAnnotations    map[string]string        // delete only Pods that have all these annotations with these values (empty disables)
+	DeleteOrphans         bool                     // delete Pods without owner/controller, they are not recreated
+	UnschedulableTimeout  time.Duration            // also match Pending Pods that have been unschedulable for longer than this (0 disables)
+	OrphanedNodePods      bool                     // also match and force delete Pods assigned to a Node that does not exist anymore
+	CaseInsensitive       bool                     // ignore case when matching messages
+	AnnotateOwner         bool                     // annotate the owning controller with restart count and time when deleting its Pods
+	ReasonAnnotation      string                   // annotate the owning controller with the reason its Pod was deleted for, under this key (empty disables)
+	IgnoreMessages        []string                 // exclude Pods with matching Events that also contain any of these messages
+	MinMatchCount         int32                    // match only Pods whose matching Events occurred at least this many times in total (0 disables)
+	MaxEventsPerPod       int                      // keep at most this many matching Events per Pod (0 keeps all)
+	IgnorePVCPending      bool                     // skip Pods Pending on PersistentVolumeClaim binding
+	RespectAutoscaler     bool                     // skip Pods Pending on a scale-up in progress triggered by cluster-autoscaler
+	MaxPendingByOwner     map[string]time.Duration // delete Pending Pods only if they are Pending longer than the threshold of their owner kind or "default" (empty disables)
+	InitWaitingReasons    []string                 // also match Pods with init containers waiting for any of these reasons, eg: CreateContainerConfigError (empty disables)
+	TerminationReasons    []string                 // also match Pods with containers that last terminated for any of these reasons, eg: OOMKilled (empty disables)
+	Matchers              []Matcher                // match Pods with these matchers in addition to Events (nil uses the built-in matchers enabled in the options)
+	MatchAll              bool                     // match Pods only if they are matched by Events and by all matchers, instead of by any of them
+	MatchJSONPath         string                   // also match Pods for which this JSONPath expression has a non-empty result (empty disables)
+	ScheduleMessageRegex  *regexp.Regexp           // delete only Pods with a scheduling failure message matching this regex (nil disables)
+	MinStableDuration     time.Duration            // skip Pods modified or with a condition transition more recently than this (0 disables)
+	IgnoreCreatedBefore   time.Time                // match only Pods created at or after this time, eg: when pod-restarter started (zero disables)
+	MinEventOffset        time.Duration            // match only Events that happened at least this long after Pod creation (0 disables)
+	DeleteTTL             time.Duration            // annotate matched Pods and delete them only once this time has passed (0 deletes immediately)
+	DeleteDryRunCheck     bool                     // confirm with a server-side dry-run deletion that the deletion would be admitted
+	CircuitBreaker        *CircuitBreaker          // pause deletions when too many Pods are deleted within a rolling window (nil disables)
+	DeletionBudget        *DeletionBudget          // stop deleting once this many Pods were deleted over the process lifetime (nil disables)
+	PersistentFailures    *OwnerFailureTracker     // stop deleting Pods of owners whose Pods were deleted too many times within a rolling window (nil disables)
+	DeletionHistory       *DeletionHistory         // ignore Events from before a Pod with the same name was last deleted (nil disables)
+	DecisionCache         *DecisionCache           // reuse the decision of Pods that did not change since they were last evaluated (nil disables)
+	TerminatingTracker    *TerminatingTracker      // report deleted Pods that are still there after a grace window, eg: stuck on finalizers (nil disables)
+	Notifier              notify.Notifier          // publish deleted Pods, eg: to a webhook (nil disables)
+	Clock                 clock.WithTicker         // source of the current time and of the waits of time-based checks (nil uses the real clock)
 }
 
 // PodDetails holds data associated with a Pod
 type PodDetails struct {
-	UID               types.UID
-	PodName           string
-	PodNamespace      string
-	ResourceVersion   string
-	OwnerReferences   []metav1.OwnerReference
-	Phase             v1.PodPhase
-	ContainerStatuses []v1.ContainerStatus
-	CreationTimestamp time.Time
-	DeletionTimestamp *metav1.Time
+	UID                   types.UID
+	PodName               string
+	PodNamespace          string
+	ResourceVersion       string
+	OwnerReferences       []metav1.OwnerReference
+	Phase                 v1.PodPhase
+	ContainerStatuses     []v1.ContainerStatus
+	InitContainerStatuses []v1.ContainerStatus
+	Conditions            []v1.PodCondition
+	CreationTimestamp     time.Time
+	LastChangeTimestamp   time.Time
+	DeletionTimestamp     *metav1.Time
+	Finalizers            []string
+	Priority              *int32
+	PriorityClassName     string
+	NodeName              string
+	Annotations           map[string]string
+	MirrorPod             bool   // the Pod is the mirror Pod of a static Pod, managed by the kubelet
+	JSONPathMatch         string // result of the MatchJSONPath expression (empty if the Pod does not match)
+	NodeMissing           bool   // the Node of the Pod does not exist anymore (only set when matching with OrphanedNodePods)
 }
 
 // PodEvent holds events data associated with a Pod
@@ -36,6 +139,9 @@ type PodEvent struct {
 	EventType       string
 	Reason          string
 	Message         string
+	SourceComponent string
+	FieldPath       string // part of the Pod the Event is about, eg: spec.containers{mycontainer} (empty for Pod level Events)
+	Count           int32  // number of times the Event occurred, 0 for Events synthesized from Pod status
 	FirstTimestamp  time.Time
 	LastTimestamp   time.Time
 }
@@ -5,41 +5,124 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"time"
+
+	v1 "k8s.io/api/core/v1"
+	e "k8s.io/apimachinery/pkg/api/errors"
+	types "k8s.io/apimachinery/pkg/types"
 )
 
 // PodChecks returns nil if Pod
 // 1. exists
-// 2. has Owner
-// 3. has not been scheduled to be deleted
-// 4. and is not in a Healthy state (eg: Pending, Failed or Running with unhealthy containers)
-func (c *kubeClient) PodChecks(ctx context.Context, podName, podNamespace string) error {
+// 2. has not been checked in this cycle already (by namespace/name and UID)
+// and passes the decision pipeline of podChecks, that runs the remaining steps in this order
+// the decision of a Pod that did not change since it was last evaluated is reused instead (if DecisionCache is set)
+// 3. is not a mirror Pod of a static Pod managed by the kubelet (unless AllowMirrorPods is set)
+// 4. has all RequireAnnotations (if enabled)
+// 5. has Owner (unless DeleteOrphans is set)
+// 6. has not been scheduled to be deleted
+// 7. has no finalizers (unless DeleteWithFinalizers is set)
+// 8. has priority below SkipPriorityAbove (if enabled)
+// 9. is assigned to NodeName (if enabled)
+// 10. is not Pending while bound to a node (if SkipBoundPending is set), the pending category is logged for every Pending Pod
+// 11. is assigned to a Node that does not exist anymore (if OrphanedNodePods is set), the remaining checks are skipped
+// 12. is Pending on a node with the RequireNodeCondition condition True (if enabled)
+// 13. is not Pending on PersistentVolumeClaim binding (if IgnorePVCPending is set)
+// 14. is not Pending on a scale-up in progress triggered by cluster-autoscaler (if RespectAutoscaler is set)
+// 15. has been Pending longer than the MaxPendingByOwner threshold of its owner kind (if enabled)
+// 16. has a scheduling failure message that matches ScheduleMessageRegex (if enabled)
+// 17. has not changed within MinStableDuration (if enabled)
+// 18. and has containers restarted more than MaxContainerRestarts times (if enabled)
+// 19. or is not Running with crashlooping containers (current state might differ from the state the Pod was matched in)
+// 20. and is not in a Healthy state (eg: Pending, Failed or Running with unhealthy containers)
+func (c *kubeClient) PodChecks(ctx context.Context, podName, podNamespace string) (err error) {
 	// verify if Pod exists
 	podInfo, err := c.GetPodDetails(ctx, podName, podNamespace)
-	if err != nil {
-		return err
+	if isNamespaceTerminating(err) {
+		return skip(DecisionSkippedNamespaceTerminating, err)
+	} else if e.IsNotFound(err) {
+		return skip(DecisionSkippedNotFound, err)
+	} else if IsCanceled(ctx, err) {
+		return skip(DecisionCanceled, err)
+	} else if err != nil {
+		return skip(DecisionErrorGetPod, err)
 	}
 
-	// verify Pod has owner
-	err = podInfo.verifyPodHasOwner()
+	// verify Pod was not checked already, eg: because it was matched by more than one scanning path
+	// this guards against double deletions, which fail with confusing NotFound errors
+	err = c.markPodChecked(podInfo)
 	if err != nil {
-		return err
+		return skip(DecisionSkippedDuplicate, err)
 	}
 
-	// verify Pod is scheduled to be deleted
-	err = podInfo.verifyPodScheduledToBeDeleted()
-	if err != nil {
-		return err
+	// reuse the decision of a Pod that did not change since it was last evaluated
+	if c.opts.DecisionCache != nil {
+		if cached := c.opts.DecisionCache.Lookup(podInfo); cached != nil {
+			return cached
+		}
+		defer func() { c.opts.DecisionCache.Store(podInfo, err) }()
 	}
 
-	// verify Pod is in an Unhealthy state
-	err = podInfo.verifyPodStatus()
-	if err != nil {
+	// run the decision pipeline
+	err = c.runPodChecks(ctx, podInfo)
+	if err == nil {
+		c.rememberCheckedDetails(podInfo)
+	}
+	return err
+}
+
+// verifyPodPriority returns error if Pod priority is at or above maxPriority
+// the priority is resolved from the PriorityClass when only the class name is set on the Pod
+func (c *kubeClient) verifyPodPriority(ctx context.Context, p *PodDetails, maxPriority int32) error {
+	var priority int32
+	switch {
+	case p.Priority != nil:
+		priority = *p.Priority
+	case p.PriorityClassName != "":
+		value, err := c.getPriorityClassValue(ctx, p.PriorityClassName)
+		if err != nil {
+			return err
+		}
+		priority = value
+	default:
+		return nil
+	}
+
+	if priority >= maxPriority {
+		msg := fmt.Sprintf(
+			"Pod has protected priority %d (PriorityClass: %q): %s/%s",
+			priority, p.PriorityClassName, p.PodNamespace, p.PodName,
+		)
+		return skip(DecisionSkippedPriority, errors.New(msg))
+	}
+	return nil
+}
+
+// verifyPodNotCrashLooping returns error if Pod is Running and has containers in CrashLoopBackOff
+// or containers that terminated with errors after being restarted
+func (p *PodDetails) verifyPodNotCrashLooping() error {
+	if p.Phase != v1.PodRunning {
 		return nil
-	} else {
-		msg := fmt.Sprintf("Pod is in a Healthy State: %s/%s", podNamespace, podName)
+	}
+	for _, cst := range p.ContainerStatuses {
+		reason := ""
+		switch {
+		case cst.State.Waiting != nil && cst.State.Waiting.Reason == "CrashLoopBackOff":
+			reason = cst.State.Waiting.Reason
+		case cst.State.Terminated != nil && cst.State.Terminated.ExitCode != 0 && cst.RestartCount > 0:
+			reason = fmt.Sprintf("terminated with exit code %d after %d restarts", cst.State.Terminated.ExitCode, cst.RestartCount)
+		default:
+			continue
+		}
+		msg := fmt.Sprintf(
+			"Pod is in a %s state but container %s is crashlooping (%s): %s/%s",
+			p.Phase, cst.Name, reason, p.PodNamespace, p.PodName,
+		)
 		return errors.New(msg)
 	}
+	return nil
 }
 
 // verifyPodStatus returns error if Pod is in a Pending, Failed or Running (with unhealthy containers) state
@@ -113,6 +196,351 @@ func (p *PodDetails) verifyPodStatus() error {
 	return errors.New(msg)
 }
 
+// pendingCategory returns whether a Pending Pod is bound to a node (the kubelet did not start it yet) or unscheduled
+func (p *PodDetails) pendingCategory() string {
+	if p.NodeName != "" {
+		return "bound to node " + p.NodeName
+	}
+	return "unscheduled"
+}
+
+// verifyPodNotBoundPending returns error if Pod is Pending and already bound to a node
+// the kubelet might just be slow to start these Pods
+func (p *PodDetails) verifyPodNotBoundPending() error {
+	if p.Phase != v1.PodPending || p.NodeName == "" {
+		return nil
+	}
+	msg := fmt.Sprintf(
+		"Pod is Pending and already bound to node %s, the kubelet might still start it: %s/%s",
+		p.NodeName, p.PodNamespace, p.PodName,
+	)
+	return errors.New(msg)
+}
+
+// verifyPodNode returns error if Pod is not assigned to node
+// unscheduled Pods (empty nodeName) are not assigned to any node
+func (p *PodDetails) verifyPodNode(node string) error {
+	if p.NodeName == node {
+		return nil
+	}
+	nodeName := p.NodeName
+	if nodeName == "" {
+		nodeName = "<unscheduled>"
+	}
+	msg := fmt.Sprintf(
+		"Pod is assigned to node %s instead of %s: %s/%s",
+		nodeName, node, p.PodNamespace, p.PodName,
+	)
+	return errors.New(msg)
+}
+
+// verifyNodeCondition returns error if Pod is not Pending on a node with the condition True
+func (c *kubeClient) verifyNodeCondition(ctx context.Context, p *PodDetails, condition v1.NodeConditionType) error {
+	if p.Phase != v1.PodPending || p.NodeName == "" {
+		msg := fmt.Sprintf(
+			"Pod is not Pending on a node, %s cannot be checked: %s/%s",
+			condition, p.PodNamespace, p.PodName,
+		)
+		return errors.New(msg)
+	}
+
+	conditions, err := c.getNodeConditions(ctx, p.NodeName)
+	if err != nil {
+		return err
+	}
+	for _, cond := range conditions {
+		if cond.Type == condition && cond.Status == v1.ConditionTrue {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf(
+		"Pod node %s does not have condition %s: %s/%s",
+		p.NodeName, condition, p.PodNamespace, p.PodName,
+	)
+	return errors.New(msg)
+}
+
+// ParseAnnotations parses a list of key=value annotations, eg: pod-restarter.io/enabled=true
+func ParseAnnotations(values []string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	for _, item := range values {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not valid, use key=value, eg: pod-restarter.io/enabled=true", item)
+		}
+		annotations[key] = value
+	}
+	return annotations, nil
+}
+
+// defaultOwnerKind is the MaxPendingByOwner key used for owner kinds without their own threshold
+const defaultOwnerKind = "default"
+
+// ParseOwnerDurations parses a comma separated list of Kind=duration, eg: DaemonSet=2m,Deployment=10m,default=5m
+func ParseOwnerDurations(value string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	if value == "" {
+		return durations, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		kind, duration, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || kind == "" {
+			return nil, fmt.Errorf("%q is not valid, use Kind=duration, eg: DaemonSet=2m", item)
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not valid: %w", item, err)
+		}
+		durations[kind] = d
+	}
+	return durations, nil
+}
+
+// verifyPendingAge returns error if Pod is Pending for less than the MaxPendingByOwner threshold of its owner kind
+// Pods owned by a ReplicaSet of a Deployment use the Deployment threshold, Pods in other phases are not checked
+func (c *kubeClient) verifyPendingAge(ctx context.Context, p *PodDetails) error {
+	if p.Phase != v1.PodPending {
+		return nil
+	}
+
+	// orphan Pods use the default threshold
+	kind := defaultOwnerKind
+	if len(p.OwnerReferences) > 0 {
+		owner, err := c.resolveOwner(ctx, p.PodNamespace, p.OwnerReferences)
+		if err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+		kind = owner.Kind
+	}
+	threshold, ok := c.opts.MaxPendingByOwner[kind]
+	if !ok {
+		threshold, ok = c.opts.MaxPendingByOwner[defaultOwnerKind]
+	}
+	if !ok {
+		return nil
+	}
+
+	age := c.clock().Since(p.CreationTimestamp)
+	if age < threshold {
+		msg := fmt.Sprintf(
+			"Pod owned by %s is Pending for %v, less than %v: %s/%s",
+			kind, age.Truncate(time.Second), threshold, p.PodNamespace, p.PodName,
+		)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// verifyScheduleMessage returns error if Pod does not have a PodScheduled condition that is False with a message matching re
+// eg: "0/5 nodes are available: 3 Insufficient memory, 2 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate."
+func (p *PodDetails) verifyScheduleMessage(re *regexp.Regexp) error {
+	for _, cond := range p.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse && re.MatchString(cond.Message) {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf(
+		"Pod does not have a scheduling failure message matching %q: %s/%s",
+		re.String(), p.PodNamespace, p.PodName,
+	)
+	return errors.New(msg)
+}
+
+// unschedulableSince returns when a Pending Pod was last found unschedulable and the scheduling failure message
+// returns false if the Pod is not Pending, is assigned to a node or is not unschedulable
+func (p *PodDetails) unschedulableSince() (time.Time, string, bool) {
+	if p.Phase != v1.PodPending || p.NodeName != "" {
+		return time.Time{}, "", false
+	}
+	for _, cond := range p.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse && cond.Reason == v1.PodReasonUnschedulable {
+			return cond.LastTransitionTime.Time, cond.Message, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// verifyPodStable returns error if Pod changed less than minStable before now
+func (p *PodDetails) verifyPodStable(now time.Time, minStable time.Duration) error {
+	sinceChange := now.Sub(p.LastChangeTimestamp)
+	if sinceChange < minStable {
+		msg := fmt.Sprintf(
+			"Pod changed %v ago, less than %v: %s/%s",
+			sinceChange.Truncate(time.Second), minStable, p.PodNamespace, p.PodName,
+		)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// pvcBindingMessages are PodScheduled condition messages of Pods Pending on PersistentVolumeClaim binding
+// eg: "0/3 nodes are available: pod has unbound immediate PersistentVolumeClaims"
+var pvcBindingMessages = []string{
+	"persistentvolumeclaim",
+	"waiting for first consumer",
+	"persistent volumes to bind",
+}
+
+// IsPVCBindingMessage returns true if message is related to PersistentVolumeClaim binding
+func IsPVCBindingMessage(message string) bool {
+	return containsAny(message, pvcBindingMessages, true)
+}
+
+// verifyPodNotWaitingForPVC returns error if Pod is Pending and unschedulable because of PersistentVolumeClaim binding
+func (p *PodDetails) verifyPodNotWaitingForPVC() error {
+	if p.Phase != v1.PodPending {
+		return nil
+	}
+	for _, cond := range p.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse && IsPVCBindingMessage(cond.Message) {
+			msg := fmt.Sprintf(
+				"Pod is Pending on PersistentVolumeClaim binding (%s): %s/%s",
+				cond.Message, p.PodNamespace, p.PodName,
+			)
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
+// verifyContainerRestarts returns error if any init or app container restarted more than maxRestarts times
+func (p *PodDetails) verifyContainerRestarts(maxRestarts int32) error {
+	statuses := append([]v1.ContainerStatus{}, p.InitContainerStatuses...)
+	statuses = append(statuses, p.ContainerStatuses...)
+	for _, cst := range statuses {
+		if cst.RestartCount > maxRestarts {
+			msg := fmt.Sprintf(
+				"Pod container %s restarted %d times (max %d): %s/%s",
+				cst.Name, cst.RestartCount, maxRestarts, p.PodNamespace, p.PodName,
+			)
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
+// matchesStatus returns the message of the first container waiting state or Pod condition that matches Reason and matchesMessage
+func (p *PodDetails) matchesStatus(reason string, matchesMessage func(message string) bool) (string, bool) {
+	statuses := append([]v1.ContainerStatus{}, p.InitContainerStatuses...)
+	statuses = append(statuses, p.ContainerStatuses...)
+	for _, cst := range statuses {
+		if cst.State.Waiting == nil {
+			continue
+		}
+		if cst.State.Waiting.Reason == reason && matchesMessage(cst.State.Waiting.Message) {
+			return cst.State.Waiting.Message, true
+		}
+	}
+	for _, cond := range p.Conditions {
+		if cond.Reason == reason && matchesMessage(cond.Message) {
+			return cond.Message, true
+		}
+	}
+	return "", false
+}
+
+// initContainerWaiting returns the waiting reason and message of the first init container waiting for one of reasons
+// init containers run before app containers, a Pod stuck on an init container is Pending (Init:CreateContainerConfigError in kubectl)
+func (p *PodDetails) initContainerWaiting(reasons []string) (string, string, bool) {
+	for _, cst := range p.InitContainerStatuses {
+		if cst.State.Waiting == nil || !contains(reasons, cst.State.Waiting.Reason) {
+			continue
+		}
+		message := fmt.Sprintf("init container %s is waiting: %s", cst.Name, cst.State.Waiting.Reason)
+		if cst.State.Waiting.Message != "" {
+			message += ": " + cst.State.Waiting.Message
+		}
+		return cst.State.Waiting.Reason, message, true
+	}
+	return "", "", false
+}
+
+// lastTerminated returns the last termination reason and a message of the first container last terminated for one of reasons
+// the reason of a container that was restarted (eg: OOMKilled) is only kept in its last state
+func (p *PodDetails) lastTerminated(reasons []string) (string, string, bool) {
+	for _, cst := range p.ContainerStatuses {
+		terminated := cst.LastTerminationState.Terminated
+		if terminated == nil || !contains(reasons, terminated.Reason) {
+			continue
+		}
+		message := fmt.Sprintf("container %s last terminated: %s (exit code %d)", cst.Name, terminated.Reason, terminated.ExitCode)
+		if terminated.Message != "" {
+			message += ": " + terminated.Message
+		}
+		return terminated.Reason, message, true
+	}
+	return "", "", false
+}
+
+// matchesEventFilters returns true if Event passes the Event filters common to all matching modes
+// the filters are also applied server-side with eventFieldSelector, this check covers servers that ignore field selectors
+func (c *kubeClient) matchesEventFilters(event PodEvent) bool {
+	if c.opts.EventSource != "" && event.SourceComponent != c.opts.EventSource {
+		return false
+	}
+	if c.opts.EventType != "" && event.EventType != c.opts.EventType {
+		return false
+	}
+	// Pod level Events (eg: FailedScheduling) do not reference a container
+	if c.opts.TargetContainer != "" && eventContainer(event.FieldPath) != c.opts.TargetContainer {
+		return false
+	}
+	return true
+}
+
+// eventContainer returns the name of the container referenced by an Event field path, eg: mycontainer for spec.containers{mycontainer}
+// init and ephemeral containers are referenced the same way, returns empty for Pod level Events
+func eventContainer(fieldPath string) string {
+	for _, prefix := range []string{"spec.containers{", "spec.initContainers{", "spec.ephemeralContainers{"} {
+		if strings.HasPrefix(fieldPath, prefix) && strings.HasSuffix(fieldPath, "}") {
+			return strings.TrimSuffix(strings.TrimPrefix(fieldPath, prefix), "}")
+		}
+	}
+	return ""
+}
+
+// eventFieldSelector returns a field selector with selectors and the Event filters common to all matching modes
+// eg: type=Warning,source=default-scheduler
+func (c *kubeClient) eventFieldSelector(selectors ...string) string {
+	if c.opts.EventType != "" {
+		selectors = append(selectors, fmt.Sprintf("type=%s", c.opts.EventType))
+	}
+	if c.opts.EventSource != "" {
+		selectors = append(selectors, fmt.Sprintf("source=%s", c.opts.EventSource))
+	}
+	return strings.Join(selectors, ",")
+}
+
+// isNamespaceTerminating returns true if err is caused by a namespace being deleted or already gone
+func isNamespaceTerminating(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e.HasStatusCause(err, v1.NamespaceTerminatingCause) {
+		return true
+	}
+	if (e.IsConflict(err) || e.IsForbidden(err)) && strings.Contains(err.Error(), "is being terminated") {
+		return true
+	}
+	var statusError e.APIStatus
+	if e.IsNotFound(err) && errors.As(err, &statusError) {
+		details := statusError.Status().Details
+		return details != nil && details.Kind == "namespaces"
+	}
+	return false
+}
+
+// isTimeout returns true if a request to the API server timed out
+func isTimeout(err error) bool {
+	return e.IsTimeout(err) || e.IsServerTimeout(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsCanceled returns true if err is caused by ctx being cancelled or expiring, eg: during shutdown
+// these errors are expected and should not be logged as errors
+func IsCanceled(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
 // verify if element in slice
 func contains(elems []string, v string) bool {
 	for _, s := range elems {
@@ -123,6 +551,79 @@ func contains(elems []string, v string) bool {
 	return false
 }
 
+// containsMessage returns true if s contains substring, ignoring case if caseInsensitive is set
+func containsMessage(s, substring string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(substring))
+	}
+	return strings.Contains(s, substring)
+}
+
+// containsAny returns true if s contains any of the substrings
+func containsAny(s string, substrings []string, caseInsensitive bool) bool {
+	for _, substring := range substrings {
+		if containsMessage(s, substring, caseInsensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// markPodChecked returns error if Pod was already checked by this client
+// Pods are identified by namespace/name and UID, a replacement Pod with the same name is checked again
+func (c *kubeClient) markPodChecked(p *PodDetails) error {
+	c.checkedPodsMu.Lock()
+	defer c.checkedPodsMu.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%s", p.PodNamespace, p.PodName, p.UID)
+	if c.checkedPods[key] {
+		msg := fmt.Sprintf("Pod was already checked in this cycle: %s/%s", p.PodNamespace, p.PodName)
+		return errors.New(msg)
+	}
+	if c.checkedPods == nil {
+		c.checkedPods = make(map[string]bool)
+	}
+	c.checkedPods[key] = true
+	return nil
+}
+
+// forgetPodChecked forgets that Pod was checked by this client, so it can be checked again in this cycle
+// eg: to re-evaluate a Pod after its deletion conflicted with a controller
+func (c *kubeClient) forgetPodChecked(namespace, pod string) {
+	c.checkedPodsMu.Lock()
+	defer c.checkedPodsMu.Unlock()
+
+	prefix := fmt.Sprintf("%s/%s/", namespace, pod)
+	for key := range c.checkedPods {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.checkedPods, key)
+		}
+	}
+	delete(c.checkedDetails, namespace+"/"+pod)
+}
+
+// rememberCheckedDetails keeps the details of a Pod that passed PodChecks, for DeletePod
+func (c *kubeClient) rememberCheckedDetails(p *PodDetails) {
+	c.checkedPodsMu.Lock()
+	defer c.checkedPodsMu.Unlock()
+
+	if c.checkedDetails == nil {
+		c.checkedDetails = make(map[string]*PodDetails)
+	}
+	c.checkedDetails[p.PodNamespace+"/"+p.PodName] = p
+}
+
+// takeCheckedDetails returns and forgets the details of a Pod that passed PodChecks, nil if it was not checked
+func (c *kubeClient) takeCheckedDetails(namespace, pod string) *PodDetails {
+	c.checkedPodsMu.Lock()
+	defer c.checkedPodsMu.Unlock()
+
+	key := namespace + "/" + pod
+	p := c.checkedDetails[key]
+	delete(c.checkedDetails, key)
+	return p
+}
+
 // verifyPodHasOwner returns nil if Pod has owner
 func (p *PodDetails) verifyPodHasOwner() error {
 	if len(p.OwnerReferences) > 0 {
@@ -135,6 +636,45 @@ func (p *PodDetails) verifyPodHasOwner() error {
 	return errors.New(msg)
 }
 
+// verifyPodHasNoFinalizers returns error if Pod has finalizers
+func (p *PodDetails) verifyPodHasNoFinalizers() error {
+	if len(p.Finalizers) > 0 {
+		msg := fmt.Sprintf(
+			"Pod has finalizers %s: %s/%s",
+			strings.Join(p.Finalizers, ", "), p.PodNamespace, p.PodName,
+		)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// verifyPodNotMirror returns error if Pod is the mirror Pod of a static Pod managed by the kubelet
+func (p *PodDetails) verifyPodNotMirror() error {
+	if p.MirrorPod {
+		msg := fmt.Sprintf(
+			"Pod is a mirror Pod managed by the kubelet of node %s: %s/%s",
+			p.NodeName, p.PodNamespace, p.PodName,
+		)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// verifyPodAnnotations returns error if Pod does not have all annotations with their values
+func (p *PodDetails) verifyPodAnnotations(annotations map[string]string) error {
+	for key, value := range annotations {
+		actual, ok := p.Annotations[key]
+		if !ok || actual != value {
+			msg := fmt.Sprintf(
+				"Pod does not have required annotation %s=%s: %s/%s",
+				key, value, p.PodNamespace, p.PodName,
+			)
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
 // verifyPodScheduledToBeDeleted returns nil if Pod is not scheduled to be deleted
 func (p *PodDetails) verifyPodScheduledToBeDeleted() error {
 	// verify Pod has not been scheduled to be deleted
@@ -164,6 +704,92 @@ func getUniqueListOfPods(events []PodEvent) map[string]string {
 	return uniquePodList
 }
 
+// removeInfrequentPods returns the Events of Pods whose Events occurred at least minCount times in total
+// every Event counts as many times as it occurred (its Count), Events without a Count count once
+// Pods matched by a matcher are kept, their matcher Event is not an occurrence of an error
+func removeInfrequentPods(events []PodEvent, minCount int32) []PodEvent {
+	occurrences := make(map[types.UID]int32)
+	matched := make(map[types.UID]bool)
+	for _, event := range events {
+		if event.Reason == matcherEventReason {
+			matched[event.UID] = true
+		} else if event.Count > 1 {
+			occurrences[event.UID] += event.Count
+		} else {
+			occurrences[event.UID]++
+		}
+	}
+
+	var frequentEvents []PodEvent
+	for _, event := range events {
+		if !matched[event.UID] && occurrences[event.UID] < minCount {
+			continue
+		}
+		frequentEvents = append(frequentEvents, event)
+	}
+	return frequentEvents
+}
+
+// filterPodsMatchingAllMessages returns the Events of Pods that have every message in at least one of their Events
+// only Events that contain one of the messages are returned
+func filterPodsMatchingAllMessages(events []PodEvent, messages []string, caseInsensitive bool) []PodEvent {
+	var matchingEvents []PodEvent
+	podEvents := make(map[string][]PodEvent)
+	var podUIDs []string
+
+	for _, event := range events {
+		uid := string(event.UID)
+		if _, ok := podEvents[uid]; !ok {
+			podUIDs = append(podUIDs, uid)
+		}
+		podEvents[uid] = append(podEvents[uid], event)
+	}
+
+	for _, uid := range podUIDs {
+		var found []PodEvent
+		matchesAll := true
+		for _, message := range messages {
+			matched := false
+			for _, event := range podEvents[uid] {
+				if containsMessage(event.Message, message, caseInsensitive) {
+					found = append(found, event)
+					matched = true
+				}
+			}
+			if !matched {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			matchingEvents = append(matchingEvents, found...)
+		}
+	}
+	return matchingEvents
+}
+
+// removeIgnoredPods returns Events of Pods that have no Event containing any of the ignored messages
+func removeIgnoredPods(events []PodEvent, ignoreMessages []string, caseInsensitive bool) []PodEvent {
+	var keptEvents []PodEvent
+	ignoredUIDs := make(map[string]bool)
+
+	for _, event := range events {
+		if containsAny(event.Message, ignoreMessages, caseInsensitive) {
+			if !ignoredUIDs[string(event.UID)] {
+				log.Printf("Ignoring Pod with Event message: %s/%s: %s", event.PodNamespace, event.PodName, event.Message)
+			}
+			ignoredUIDs[string(event.UID)] = true
+		}
+	}
+	for _, event := range events {
+		if ignoredUIDs[string(event.UID)] {
+			continue
+		}
+		keptEvents = append(keptEvents, event)
+	}
+	return keptEvents
+}
+
 // removeOlderEvents returns a slice of latest Events not older than eventMaxAge
 func removeOlderEvents(events []PodEvent, eventMaxAge time.Time) []PodEvent {
 	var latestEvents []PodEvent
@@ -177,6 +803,39 @@ func removeOlderEvents(events []PodEvent, eventMaxAge time.Time) []PodEvent {
 	return latestEvents
 }
 
+// removeEarlyEvents returns a slice of Events that happened at least minOffset after their Pod was created
+// Events of Pods missing from podCreation are removed, these Pods do not exist anymore
+func removeEarlyEvents(events []PodEvent, podCreation map[types.UID]time.Time, minOffset time.Duration) []PodEvent {
+	var lateEvents []PodEvent
+	for _, event := range events {
+		created, ok := podCreation[event.UID]
+		if !ok || event.LastTimestamp.Sub(created) < minOffset {
+			continue
+		}
+		lateEvents = append(lateEvents, event)
+	}
+	return lateEvents
+}
+
+// removePreexistingPods removes Events of Pods created before createdAfter, or that do not exist anymore
+// returns the remaining Events and the number of pre-existing Pods removed
+func removePreexistingPods(events []PodEvent, podCreation map[types.UID]time.Time, createdAfter time.Time) ([]PodEvent, int) {
+	var newEvents []PodEvent
+	preexisting := make(map[types.UID]bool)
+	for _, event := range events {
+		created, ok := podCreation[event.UID]
+		if !ok {
+			continue
+		}
+		if created.Before(createdAfter) {
+			preexisting[event.UID] = true
+			continue
+		}
+		newEvents = append(newEvents, event)
+	}
+	return newEvents, len(preexisting)
+}
+
 // timeTrack calculates how long it takes to execute a function
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
@@ -1,14 +1,19 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestVerifyPodStatus(t *testing.T) {
@@ -256,3 +261,872 @@ func TestVerifyPodScheduledToBeDeleted(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyContainerRestarts(t *testing.T) {
+	type Inputs struct {
+		pod         PodDetails
+		maxRestarts int32
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify no error is thrown when containers are below restart threshold": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{
+						{Name: "nginx", RestartCount: 3},
+					},
+				},
+				maxRestarts: 5,
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify error is thrown when app container exceeds restart threshold": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodRunning,
+					ContainerStatuses: []v1.ContainerStatus{
+						{Name: "nginx", RestartCount: 6},
+					},
+				},
+				maxRestarts: 5,
+			},
+			expected: Expected{err: fmt.Errorf("Pod container nginx restarted 6 times (max 5): default/foo")},
+		},
+		"Verify error is thrown when init container exceeds restart threshold": {
+			inputs: Inputs{
+				pod: PodDetails{
+					PodName:      "foo",
+					PodNamespace: "default",
+					Phase:        v1.PodPending,
+					InitContainerStatuses: []v1.ContainerStatus{
+						{Name: "init", RestartCount: 10},
+					},
+					ContainerStatuses: []v1.ContainerStatus{
+						{Name: "nginx", RestartCount: 0},
+					},
+				},
+				maxRestarts: 5,
+			},
+			expected: Expected{err: fmt.Errorf("Pod container init restarted 10 times (max 5): default/foo")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := tc.inputs.pod.verifyContainerRestarts(tc.inputs.maxRestarts)
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestVerifyPodPriority(t *testing.T) {
+	highPriority := int32(2000000000)
+	lowPriority := int32(100)
+
+	type Inputs struct {
+		pod         PodDetails
+		maxPriority int32
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify no error is thrown when pod has no priority": {
+			inputs: Inputs{
+				pod:         PodDetails{PodName: "foo", PodNamespace: "default"},
+				maxPriority: 1000,
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify no error is thrown when pod priority is below threshold": {
+			inputs: Inputs{
+				pod:         PodDetails{PodName: "foo", PodNamespace: "default", Priority: &lowPriority},
+				maxPriority: 1000,
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify error is thrown when pod priority is at or above threshold": {
+			inputs: Inputs{
+				pod:         PodDetails{PodName: "foo", PodNamespace: "default", Priority: &highPriority, PriorityClassName: "system-cluster-critical"},
+				maxPriority: 2000000000,
+			},
+			expected: Expected{err: fmt.Errorf("Pod has protected priority 2000000000 (PriorityClass: \"system-cluster-critical\"): default/foo")},
+		},
+		"Verify priority is resolved from PriorityClass when only class name is set": {
+			inputs: Inputs{
+				pod:         PodDetails{PodName: "foo", PodNamespace: "default", PriorityClassName: "critical"},
+				maxPriority: 1000,
+			},
+			expected: Expected{err: fmt.Errorf("Pod has protected priority 5000 (PriorityClass: \"critical\"): default/foo")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(&schedulingv1.PriorityClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "critical"},
+				Value:      5000,
+			})
+			err := clt.verifyPodPriority(context.TODO(), &tc.inputs.pod, tc.inputs.maxPriority)
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestVerifyPodNode(t *testing.T) {
+	type Inputs struct {
+		pod  PodDetails
+		node string
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify no error is thrown when pod is assigned to node": {
+			inputs: Inputs{
+				pod:  PodDetails{PodName: "foo", PodNamespace: "default", NodeName: "node1"},
+				node: "node1",
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify error is thrown when pod is assigned to another node": {
+			inputs: Inputs{
+				pod:  PodDetails{PodName: "foo", PodNamespace: "default", NodeName: "node2"},
+				node: "node1",
+			},
+			expected: Expected{err: fmt.Errorf("Pod is assigned to node node2 instead of node1: default/foo")},
+		},
+		"Verify error is thrown when pod is not scheduled": {
+			inputs: Inputs{
+				pod:  PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending},
+				node: "node1",
+			},
+			expected: Expected{err: fmt.Errorf("Pod is assigned to node <unscheduled> instead of node1: default/foo")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := tc.inputs.pod.verifyPodNode(tc.inputs.node)
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestVerifyPodNotBoundPending(t *testing.T) {
+	type Inputs struct {
+		pod PodDetails
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify no error is thrown when Pending pod is not scheduled": {
+			inputs:   Inputs{pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending}},
+			expected: Expected{err: nil},
+		},
+		"Verify no error is thrown when pod bound to a node is not Pending": {
+			inputs:   Inputs{pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodFailed, NodeName: "node1"}},
+			expected: Expected{err: nil},
+		},
+		"Verify error is thrown when Pending pod is bound to a node": {
+			inputs:   Inputs{pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, NodeName: "node1"}},
+			expected: Expected{err: fmt.Errorf("Pod is Pending and already bound to node node1, the kubelet might still start it: default/foo")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := tc.inputs.pod.verifyPodNotBoundPending()
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestVerifyPodNotWaitingForPVC(t *testing.T) {
+	type Inputs struct {
+		pod PodDetails
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	unbound := "0/3 nodes are available: 3 pod has unbound immediate PersistentVolumeClaims."
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify error is thrown when pending pod has unbound PersistentVolumeClaims": {
+			inputs: Inputs{
+				pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: "Unschedulable", Message: unbound},
+				}},
+			},
+			expected: Expected{err: fmt.Errorf("Pod is Pending on PersistentVolumeClaim binding (%s): default/foo", unbound)},
+		},
+		"Verify no error is thrown when pending pod is unschedulable for other reasons": {
+			inputs: Inputs{
+				pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available: 3 Insufficient cpu."},
+				}},
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify no error is thrown when pending pod is scheduled": {
+			inputs: Inputs{
+				pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: v1.ConditionTrue},
+				}},
+			},
+			expected: Expected{err: nil},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := tc.inputs.pod.verifyPodNotWaitingForPVC()
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestVerifyScheduleMessage(t *testing.T) {
+	type Inputs struct {
+		pod   PodDetails
+		regex string
+	}
+
+	type Expected struct {
+		err error
+	}
+
+	unschedulable := func(message string) PodDetails {
+		return PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, Conditions: []v1.PodCondition{
+			{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: "Unschedulable", Message: message},
+		}}
+	}
+	tests := map[string]struct {
+		inputs   Inputs
+		expected Expected
+	}{
+		"Verify no error is thrown when scheduling failure message matches": {
+			inputs: Inputs{
+				pod:   unschedulable("0/5 nodes are available: 5 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate."),
+				regex: `^0/(\d+) nodes are available: (\d+) node\(s\) had taint \{dedicated: gpu\}`,
+			},
+			expected: Expected{err: nil},
+		},
+		"Verify error is thrown when scheduling failure message does not match": {
+			inputs: Inputs{
+				pod:   unschedulable("0/5 nodes are available: 5 Insufficient memory."),
+				regex: `had taint \{dedicated: gpu\}`,
+			},
+			expected: Expected{err: fmt.Errorf(`Pod does not have a scheduling failure message matching "had taint \\{dedicated: gpu\\}": default/foo`)},
+		},
+		"Verify error is thrown when pod is scheduled": {
+			inputs: Inputs{
+				pod:   PodDetails{PodName: "foo", PodNamespace: "default", Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionTrue}}},
+				regex: `Insufficient`,
+			},
+			expected: Expected{err: fmt.Errorf(`Pod does not have a scheduling failure message matching "Insufficient": default/foo`)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := tc.inputs.pod.verifyScheduleMessage(regexp.MustCompile(tc.inputs.regex))
+
+			if tc.expected.err != nil {
+				require.Error(tc.expected.err)
+				assert.EqualError(err, tc.expected.err.Error(), "Expected error: %v Got: %v", tc.expected.err, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestPodChecksStateChangedBetweenPasses(t *testing.T) {
+	// Pods were matched as Pending from Events, PodChecks re-fetches their current state
+	tests := map[string]struct {
+		pod         *v1.Pod
+		expectedErr error
+	}{
+		"Verify Pod still Pending is deleted": {
+			pod: makeOwnedPod("foo", "default", v1.PodPending, []v1.ContainerStatus{
+				{Name: "nginx", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+			}),
+			expectedErr: nil,
+		},
+		"Verify Pod now Running in CrashLoopBackOff is not deleted": {
+			pod: makeOwnedPod("foo", "default", v1.PodRunning, []v1.ContainerStatus{
+				{Name: "nginx", RestartCount: 3, State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			}),
+			expectedErr: fmt.Errorf("Pod is in a Running state but container nginx is crashlooping (CrashLoopBackOff): default/foo"),
+		},
+		"Verify Pod now Running with restarted failing container is not deleted": {
+			pod: makeOwnedPod("foo", "default", v1.PodRunning, []v1.ContainerStatus{
+				{Name: "nginx", RestartCount: 2, State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}}},
+			}),
+			expectedErr: fmt.Errorf("Pod is in a Running state but container nginx is crashlooping (terminated with exit code 1 after 2 restarts): default/foo"),
+		},
+		"Verify Pod now Running and healthy is not deleted": {
+			pod: makeOwnedPod("foo", "default", v1.PodRunning, []v1.ContainerStatus{
+				{Name: "nginx", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			}),
+			expectedErr: fmt.Errorf("Pod is in a Healthy State: default/foo"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.pod)
+			err := clt.PodChecks(context.TODO(), "foo", "default")
+
+			if tc.expectedErr != nil {
+				assert.EqualError(err, tc.expectedErr.Error(), "Expected error: %v Got: %v", tc.expectedErr, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestPodChecksOrphans(t *testing.T) {
+	tests := map[string]struct {
+		deleteOrphans bool
+		expectedErr   error
+	}{
+		"Verify orphan Pod is skipped by default": {
+			deleteOrphans: false,
+			expectedErr:   fmt.Errorf("Pod does not have owner/controller: default/foo"),
+		},
+		"Verify orphan Pod is deleted with delete orphans": {
+			deleteOrphans: true,
+			expectedErr:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(makePod("foo", "default", 1, v1.PodPending, "abc1"))
+			clt.opts.DeleteOrphans = tc.deleteOrphans
+			err := clt.PodChecks(context.TODO(), "foo", "default")
+
+			if tc.expectedErr != nil {
+				assert.EqualError(err, tc.expectedErr.Error(), "Expected error: %v Got: %v", tc.expectedErr, err)
+			} else {
+				require.NoError(err)
+			}
+		})
+	}
+}
+
+func TestPodChecksDecision(t *testing.T) {
+	terminating := makeOwnedPod("foo", "default", v1.PodPending, nil)
+	terminating.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	tests := map[string]struct {
+		pod              *v1.Pod
+		expectedDecision Decision
+	}{
+		"Verify decision for Pod that does not exist anymore": {
+			pod:              makeOwnedPod("bar", "default", v1.PodPending, nil),
+			expectedDecision: DecisionSkippedNotFound,
+		},
+		"Verify decision for Pod without owner": {
+			pod:              makePod("foo", "default", 1, v1.PodPending, "abc1"),
+			expectedDecision: DecisionSkippedNoOwner,
+		},
+		"Verify decision for Pod scheduled to be deleted": {
+			pod:              terminating,
+			expectedDecision: DecisionSkippedTerminating,
+		},
+		"Verify decision for healthy Pod": {
+			pod: makeOwnedPod("foo", "default", v1.PodRunning, []v1.ContainerStatus{
+				{Name: "nginx", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			}),
+			expectedDecision: DecisionSelfHealed,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.pod)
+			err := clt.PodChecks(context.TODO(), "foo", "default")
+
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedDecision, DecisionOf(err))
+		})
+	}
+}
+
+func TestVerifyPendingAge(t *testing.T) {
+	makeAgedPod := func(kind string, phase v1.PodPhase, age time.Duration) *v1.Pod {
+		pod := makeOwnedPod("foo", "default", phase, nil)
+		pod.ObjectMeta.OwnerReferences[0].Kind = kind
+		pod.ObjectMeta.CreationTimestamp = metav1.Time{Time: time.Now().Add(-age)}
+		return pod
+	}
+	thresholds := map[string]time.Duration{"DaemonSet": 2 * time.Minute, "Deployment": 10 * time.Minute, "default": 5 * time.Minute}
+	deployment := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo-rs",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "foo"}},
+		},
+	}
+
+	tests := map[string]struct {
+		pod         *v1.Pod
+		expectError bool
+	}{
+		"Verify DaemonSet Pod Pending longer than its threshold is deleted": {
+			pod: makeAgedPod("DaemonSet", v1.PodPending, 3*time.Minute),
+		},
+		"Verify Deployment Pod Pending shorter than its threshold is skipped": {
+			pod:         makeAgedPod("ReplicaSet", v1.PodPending, 3*time.Minute),
+			expectError: true,
+		},
+		"Verify StatefulSet Pod uses the default threshold": {
+			pod: makeAgedPod("StatefulSet", v1.PodPending, 6*time.Minute),
+		},
+		"Verify Running Pod is not checked": {
+			pod: makeAgedPod("DaemonSet", v1.PodRunning, time.Second),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(tc.pod, deployment)
+			clt.opts.MaxPendingByOwner = thresholds
+			podInfo := newPodDetails(tc.pod)
+
+			err := clt.verifyPendingAge(context.TODO(), &podInfo)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseOwnerDurations(t *testing.T) {
+	durations, err := ParseOwnerDurations("DaemonSet=2m, Deployment=10m")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"DaemonSet": 2 * time.Minute, "Deployment": 10 * time.Minute}, durations)
+
+	_, err = ParseOwnerDurations("DaemonSet")
+	assert.Error(t, err)
+	_, err = ParseOwnerDurations("DaemonSet=soon")
+	assert.Error(t, err)
+}
+
+func TestVerifyPodStable(t *testing.T) {
+	now := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-time.Hour))
+	recently := metav1.NewTime(now.Add(-time.Minute))
+
+	tests := map[string]struct {
+		pod         *v1.Pod
+		expectedErr string
+	}{
+		"Verify no error is thrown when pod did not change since creation": {
+			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", CreationTimestamp: created}},
+		},
+		"Verify error is thrown when pod condition transitioned recently": {
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", CreationTimestamp: created},
+				Status: v1.PodStatus{Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: v1.ConditionFalse, LastTransitionTime: recently},
+				}},
+			},
+			expectedErr: "Pod changed 1m0s ago, less than 10m0s: default/foo",
+		},
+		"Verify error is thrown when pod managed fields changed recently": {
+			pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name: "foo", Namespace: "default", CreationTimestamp: created,
+				ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kube-scheduler", Time: &recently}},
+			}},
+			expectedErr: "Pod changed 1m0s ago, less than 10m0s: default/foo",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			podInfo := newPodDetails(tc.pod)
+			err := podInfo.verifyPodStable(now, 10*time.Minute)
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPodChecksFinalizers(t *testing.T) {
+	pod := makeOwnedPod("foo", "default", v1.PodPending, nil)
+	pod.ObjectMeta.Finalizers = []string{"example.com/cleanup", "example.com/backup"}
+
+	tests := map[string]struct {
+		deleteWithFinalizers bool
+		expectedErr          error
+		expectedDecision     Decision
+	}{
+		"Verify pod with finalizers is skipped": {
+			deleteWithFinalizers: false,
+			expectedErr:          fmt.Errorf("Pod has finalizers example.com/cleanup, example.com/backup: default/foo"),
+			expectedDecision:     DecisionSkippedFinalizers,
+		},
+		"Verify pod with finalizers is deleted with DeleteWithFinalizers": {
+			deleteWithFinalizers: true,
+			expectedErr:          nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(pod)
+			clt.opts.DeleteWithFinalizers = tc.deleteWithFinalizers
+			err := clt.PodChecks(context.TODO(), "foo", "default")
+
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+				assert.Equal(t, tc.expectedDecision, DecisionOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPodChecksMirrorPods(t *testing.T) {
+	pod := makePod("kube-apiserver-node1", "kube-system", 1, v1.PodPending, "uid1")
+	pod.ObjectMeta.Annotations = map[string]string{v1.MirrorPodAnnotationKey: "3c5ab2e5b0f3c0c5d1d2f2e6b0a4b8c1"}
+	pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node1", UID: "node1"}}
+	pod.Spec.NodeName = "node1"
+
+	tests := map[string]struct {
+		allowMirrorPods  bool
+		expectedErr      error
+		expectedDecision Decision
+	}{
+		"Verify mirror pod is skipped": {
+			allowMirrorPods:  false,
+			expectedErr:      fmt.Errorf("Pod is a mirror Pod managed by the kubelet of node node1: kube-system/kube-apiserver-node1"),
+			expectedDecision: DecisionSkippedMirrorPod,
+		},
+		"Verify mirror pod is deleted with AllowMirrorPods": {
+			allowMirrorPods: true,
+			expectedErr:     nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(pod)
+			clt.opts.AllowMirrorPods = tc.allowMirrorPods
+			err := clt.PodChecks(context.TODO(), "kube-apiserver-node1", "kube-system")
+
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+				assert.Equal(t, tc.expectedDecision, DecisionOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPodChecksRequireAnnotations(t *testing.T) {
+	optedIn := makePod("foo", "default", 1, v1.PodPending, "uid1")
+	optedIn.ObjectMeta.Annotations = map[string]string{"pod-restarter.io/enabled": "true", "team": "payments"}
+	optedIn.ObjectMeta.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "foo", UID: "rs1"}}
+	other := makePod("bar", "default", 1, v1.PodPending, "uid2")
+	other.ObjectMeta.Annotations = map[string]string{"pod-restarter.io/enabled": "false"}
+	other.ObjectMeta.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar", UID: "rs2"}}
+
+	tests := map[string]struct {
+		pod                 string
+		requiredAnnotations map[string]string
+		expectedErr         error
+	}{
+		"Verify pod with all required annotations is deleted": {
+			pod:                 "foo",
+			requiredAnnotations: map[string]string{"pod-restarter.io/enabled": "true", "team": "payments"},
+		},
+		"Verify pod with a different annotation value is skipped": {
+			pod:                 "bar",
+			requiredAnnotations: map[string]string{"pod-restarter.io/enabled": "true"},
+			expectedErr:         fmt.Errorf("Pod does not have required annotation pod-restarter.io/enabled=true: default/bar"),
+		},
+		"Verify pod without a required annotation is skipped": {
+			pod:                 "bar",
+			requiredAnnotations: map[string]string{"team": "payments"},
+			expectedErr:         fmt.Errorf("Pod does not have required annotation team=payments: default/bar"),
+		},
+		"Verify pods are not filtered without required annotations": {
+			pod: "bar",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(optedIn, other)
+			clt.opts.RequireAnnotations = tc.requiredAnnotations
+			err := clt.PodChecks(context.TODO(), tc.pod, "default")
+
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+				assert.Equal(t, DecisionSkippedRequiredAnnotation, DecisionOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	annotations, err := ParseAnnotations([]string{"pod-restarter.io/enabled=true", "team="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pod-restarter.io/enabled": "true", "team": ""}, annotations)
+
+	for _, value := range []string{"team", "=payments"} {
+		_, err := ParseAnnotations([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func TestVerifyNodeCondition(t *testing.T) {
+	pressured := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionTrue},
+			{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue},
+		}},
+	}
+	healthy := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node2"},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionTrue},
+			{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+		}},
+	}
+
+	tests := map[string]struct {
+		pod         PodDetails
+		expectedErr string
+	}{
+		"Verify no error is thrown when pod is Pending on a node under pressure": {
+			pod: PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, NodeName: "node1"},
+		},
+		"Verify error is thrown when pod is Pending on a healthy node": {
+			pod:         PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending, NodeName: "node2"},
+			expectedErr: "Pod node node2 does not have condition MemoryPressure: default/foo",
+		},
+		"Verify error is thrown when pod is not assigned to a node": {
+			pod:         PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodPending},
+			expectedErr: "Pod is not Pending on a node, MemoryPressure cannot be checked: default/foo",
+		},
+		"Verify error is thrown when pod is Running": {
+			pod:         PodDetails{PodName: "foo", PodNamespace: "default", Phase: v1.PodRunning, NodeName: "node1"},
+			expectedErr: "Pod is not Pending on a node, MemoryPressure cannot be checked: default/foo",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var clt kubeClient
+			clt.clientSet = fake.NewSimpleClientset(pressured, healthy)
+			err := clt.verifyNodeCondition(context.TODO(), &tc.pod, v1.NodeMemoryPressure)
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetNodeConditionsCached(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	clt := kubeClient{clientSet: clientSet}
+
+	for i := 0; i < 3; i++ {
+		_, err := clt.getNodeConditions(context.TODO(), "node1")
+		require.NoError(t, err)
+	}
+	assert.Len(t, clientSet.Actions(), 1, "Node should be fetched once per client")
+}
+
+func TestPodChecksDuplicates(t *testing.T) {
+	var ctx = context.TODO()
+	var clt kubeClient
+	clt.clientSet = fake.NewSimpleClientset(makeOwnedPod("foo", "default", v1.PodPending, nil))
+
+	// the same Pod matched twice in a cycle is evaluated once
+	require.NoError(t, clt.PodChecks(ctx, "foo", "default"))
+	err := clt.PodChecks(ctx, "foo", "default")
+	assert.EqualError(t, err, "Pod was already checked in this cycle: default/foo")
+	assert.Equal(t, DecisionSkippedDuplicate, DecisionOf(err))
+
+	// a replacement Pod with the same name is evaluated again
+	require.NoError(t, clt.clientSet.CoreV1().Pods("default").Delete(ctx, "foo", metav1.DeleteOptions{}))
+	replacement := makeOwnedPod("foo", "default", v1.PodPending, nil)
+	replacement.ObjectMeta.UID = "foo-replacement"
+	_, err = clt.clientSet.CoreV1().Pods("default").Create(ctx, replacement, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, clt.PodChecks(ctx, "foo", "default"))
+}
+
+func TestInitContainerWaiting(t *testing.T) {
+	tests := map[string]struct {
+		statuses        []v1.ContainerStatus
+		expectedReason  string
+		expectedMessage string
+		expectedMatch   bool
+	}{
+		"Verify init container waiting for a matching reason is matched": {
+			statuses: []v1.ContainerStatus{
+				{Name: "wait-for-db", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}}},
+				{Name: "setup", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CreateContainerConfigError", Message: "secret \"db\" not found"}}},
+			},
+			expectedReason:  "CreateContainerConfigError",
+			expectedMessage: "init container setup is waiting: CreateContainerConfigError: secret \"db\" not found",
+			expectedMatch:   true,
+		},
+		"Verify init container waiting for another reason is not matched": {
+			statuses: []v1.ContainerStatus{
+				{Name: "setup", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "PodInitializing"}}},
+			},
+		},
+		"Verify running init container is not matched": {
+			statuses: []v1.ContainerStatus{
+				{Name: "setup", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			pod := PodDetails{InitContainerStatuses: test.statuses}
+			reason, message, ok := pod.initContainerWaiting([]string{"CreateContainerConfigError", "InvalidImageName"})
+			assert.Equal(t, test.expectedMatch, ok)
+			assert.Equal(t, test.expectedReason, reason)
+			assert.Equal(t, test.expectedMessage, message)
+		})
+	}
+}
+
+func TestEventContainer(t *testing.T) {
+	tests := map[string]struct {
+		fieldPath         string
+		expectedContainer string
+	}{
+		"Verify container is returned":               {fieldPath: "spec.containers{mycontainer}", expectedContainer: "mycontainer"},
+		"Verify init container is returned":          {fieldPath: "spec.initContainers{setup}", expectedContainer: "setup"},
+		"Verify ephemeral container is returned":     {fieldPath: "spec.ephemeralContainers{debugger}", expectedContainer: "debugger"},
+		"Verify Pod level Event has no container":    {fieldPath: "", expectedContainer: ""},
+		"Verify other field paths have no container": {fieldPath: "spec.volumes{data}", expectedContainer: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expectedContainer, eventContainer(test.fieldPath))
+		})
+	}
+}
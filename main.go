@@ -6,40 +6,685 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/util/homedir"
+	"k8s.io/utils/clock"
 )
 
 // define variables
 var (
-	pollingInterval int
-	kubeconfig      *string
-	ctx             = context.TODO()
-	errorMessage    string
-	eventReason     string
-	namespace       string
-	dryRunMode      bool
-	healTime        time.Duration = 5 // allow Pending Pod time to self heal (seconds)
+	pollingInterval   int
+	kubeconfig        *string
+	errorMessage      string
+	eventReason       string
+	namespace         string
+	dryRunMode        bool
+	healTime          time.Duration = 5 // allow Pending Pod time to self heal (seconds)
+	userAgent         string
+	showVersion       bool
+	maxRestarts       int
+	statusFallback    bool
+	deleteConcurrency int
+	errorMessagesAll  stringSlice
+	dumpDir           string
+	skipPriorityAbove int
+	listPageSize      int64
+	verifyDeletion    bool
+	verifyTimeout     time.Duration
+	eventSource       string
+	targetContainer   string
+	eventType         string
+	checkOwnerEvents  bool
+	deleteFinalizers  bool
+	instanceName      string
+	logFormat         string
+	nodeName          string
+	skipBoundPending  bool
+	nodeCondition     string
+	httpAddr          string
+	pushgatewayURL    string
+	pushgatewayJob    string
+	metricsNsLabel    bool
+	startupDelay      time.Duration
+	deleteOrphans     bool
+	allowMirrorPods   bool
+	orphanedNodePods  bool
+	unschedulableTime time.Duration
+	exitOnPanic       bool
+	caseInsensitive   bool
+	annotateOwner     bool
+	reasonAnnotation  string
+	ignoreMessages    stringSlice
+	maxEventsPerPod   int
+	minMatchCount     int
+	reportMode        bool
+	reportFormat      string
+	fixturesDir       string
+	deletionOrder     string
+	notifyWebhookURL  string
+	alertmanagerURL   string
+	alerts            *notify.Alertmanager
+	criteriaConfigMap string
+	errorMessageFile  string
+	errorMessages     *k8s.ErrorMessages
+	errorRegexExpr    string
+	errorRegex        *regexp.Regexp
+	maxCategories     int
+	heartbeatLease    string
+	minReadyNodes     float64
+	minPendingPods    int
+	allowCachedList   bool
+	throttleRetries   int
+	deleteTTL         time.Duration
+	getFailureLimit   int
+	maxPendingByOwner string
+	drainOnShutdown   bool
+	drainTimeout      time.Duration
+	getFailures       = newGetFailureTracker()
+	recheckHealed     bool
+	healed            *healedTracker
+	clusterHealth     clusterHealthState
+	notifyTimeout     time.Duration
+	onDeleteExec      string
+	onDeleteTimeout   time.Duration
+	onDeleteHook      *notify.Async // runs --on-delete-exec in the background (nil if disabled)
+	minEventOffset    time.Duration
+	ignorePreexisting bool
+	scheduleMsgRegex  string
+	matchJSONPath     string
+	matchMode         string
+	initWaitReasons   stringSlice
+	lastTermReasons   stringSlice
+	requireAnnots     stringSlice
+	minStable         time.Duration
+	ignorePVCPending  bool
+	respectAutoscaler bool
+	deleteDryRunCheck bool
+	conflictRetries   int
+	observeNamespaces stringSlice
+	namespaceShards   int
+	observeOnly       = make(map[string]bool) // namespaces where matched Pods are only logged
+	breakerThreshold  int
+	breakerWindow     time.Duration
+	breakerCooldown   time.Duration
+	circuitBreaker    *k8s.CircuitBreaker
+	maxDeletions      int
+	exitOnBudget      bool
+	deletionBudget    *k8s.DeletionBudget
+	ownerFailureLimit int
+	ownerFailureWin   time.Duration
+	ownerFailures     *k8s.OwnerFailureTracker
+	ignoreOldEvents   bool
+	deletionHistory   *k8s.DeletionHistory
+	cacheDecisions    bool
+	terminatingGrace  time.Duration
+	terminating       *k8s.TerminatingTracker
+	decisionCache     *k8s.DecisionCache
+	deletionRate      string
+	activeWindowSpec  string
+	activeWindowTZ    string
+	deletionWindow    *activeWindow
+	windowState       activeWindowState
+	windowClosed      bool // deletions are suppressed in this cycle, outside --active-window
+	queue             *deletionQueue
+	clientOptions     k8s.Options
+	summaryFile       string
+	summaryAppend     bool
+	summary           *cycleSummary
+	clk               clock.WithTicker = clock.RealClock{} // source of the current time and timers, tests inject a fake clock
+)
+
+// clusterHealthState holds the result of the latest cluster health check
+type clusterHealthState struct {
+	mu                sync.Mutex
+	checked           bool
+	paused            bool
+	readyNodeFraction float64
+	checkedAt         time.Time
+}
+
+// getFailureTracker counts consecutive cycles in which matched Pods could not be fetched
+type getFailureTracker struct {
+	mu       sync.Mutex
+	failures map[string]int  // consecutive failures by namespace/name
+	seen     map[string]bool // Pods processed since the last prune
+}
+
+func newGetFailureTracker() *getFailureTracker {
+	return &getFailureTracker{
+		failures: make(map[string]int),
+		seen:     make(map[string]bool),
+	}
+}
+
+// record records whether fetching a Pod failed, returns the number of consecutive failures
+func (t *getFailureTracker) record(ns, pod string, failed bool) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := ns + "/" + pod
+	t.seen[key] = true
+	if !failed {
+		delete(t.failures, key)
+		return 0
+	}
+	t.failures[key]++
+	return t.failures[key]
+}
+
+// prune forgets Pods that were not processed since the last prune, eg: Pods that stopped matching
+func (t *getFailureTracker) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.failures {
+		if !t.seen[key] {
+			delete(t.failures, key)
+		}
+	}
+	t.seen = make(map[string]bool)
+}
+
+// snapshot returns a copy of the consecutive failures by namespace/name
+func (t *getFailureTracker) snapshot() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	failures := make(map[string]int, len(t.failures))
+	for key, count := range t.failures {
+		failures[key] = count
+	}
+	return failures
+}
+
+// healedTracker remembers self-healed Pods, so they are checked once more in the next cycle with --recheck-healed
+// this catches Pods that briefly flip to Running and back to Pending
+type healedTracker struct {
+	mu        sync.Mutex
+	pods      map[string]healedPod // self-healed Pods by namespace/name
+	rechecked map[string]bool      // Pods rechecked in the current cycle, they are not remembered again
+	ttl       time.Duration        // how long self-healed Pods are remembered
+	now       func() time.Time
+}
+
+// healedPod is a self-healed Pod waiting to be checked once more
+type healedPod struct {
+	ref     k8s.PodRef
+	expires time.Time
+}
+
+func newHealedTracker(ttl time.Duration) *healedTracker {
+	return &healedTracker{
+		pods:      make(map[string]healedPod),
+		rechecked: make(map[string]bool),
+		ttl:       ttl,
+		now:       clk.Now,
+	}
+}
+
+// record remembers a self-healed Pod, unless it was already rechecked in the current cycle
+func (t *healedTracker) record(ns, pod string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ref := k8s.PodRef{Name: pod, Namespace: ns}
+	if t.rechecked[ref.String()] {
+		return
+	}
+	t.pods[ref.String()] = healedPod{ref: ref, expires: t.now().Add(t.ttl)}
+}
+
+// take returns the remembered Pods that did not expire and are not matched already, and forgets all remembered Pods
+func (t *healedTracker) take(matched []map[string]string) map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	recheck := make(map[string]string)
+	t.rechecked = make(map[string]bool)
+	for key, healed := range t.pods {
+		if now.After(healed.expires) || isMatched(matched, healed.ref) {
+			continue
+		}
+		recheck[healed.ref.Name] = healed.ref.Namespace
+		t.rechecked[key] = true
+	}
+	t.pods = make(map[string]healedPod)
+	return recheck
+}
+
+// isMatched returns true if pod is in one of the matched Pod lists
+func isMatched(matched []map[string]string, pod k8s.PodRef) bool {
+	for _, podList := range matched {
+		if ns, ok := podList[pod.Name]; ok && ns == pod.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// deletionQueue holds matched Pods waiting to be deleted at --deletion-rate
+// deletions are allowed at a steady rate, unused deletions accumulate up to one period worth of deletions
+type deletionQueue struct {
+	mu       sync.Mutex
+	entries  []k8s.PodRef
+	queued   map[string]bool
+	count    int           // deletions allowed per period
+	interval time.Duration // time between deletions
+	tokens   float64       // deletions currently allowed
+	last     time.Time     // last time tokens were added
+	now      func() time.Time
+}
+
+func newDeletionQueue(count int, period time.Duration) *deletionQueue {
+	q := &deletionQueue{
+		queued:   make(map[string]bool),
+		count:    count,
+		interval: period / time.Duration(count),
+		tokens:   1,
+		now:      clk.Now,
+	}
+	q.last = q.now()
+	return q
+}
+
+// push appends Pods that are not queued yet, in order
+func (q *deletionQueue) push(pods []k8s.PodRef) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, pod := range pods {
+		if q.queued[pod.String()] {
+			continue
+		}
+		q.queued[pod.String()] = true
+		q.entries = append(q.entries, pod)
+	}
+	metrics.DeletionQueueDepth.Set(float64(len(q.entries)))
+}
+
+// next removes and returns the first queued Pod if a deletion is allowed
+func (q *deletionQueue) next() (k8s.PodRef, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	q.tokens += float64(now.Sub(q.last)) / float64(q.interval)
+	if q.tokens > float64(q.count) {
+		q.tokens = float64(q.count)
+	}
+	q.last = now
+
+	if len(q.entries) == 0 || q.tokens < 1 {
+		return k8s.PodRef{}, false
+	}
+	pod := q.entries[0]
+	q.entries = q.entries[1:]
+	delete(q.queued, pod.String())
+	metrics.DeletionQueueDepth.Set(float64(len(q.entries)))
+	return pod, true
+}
+
+// used takes one deletion from the allowed deletions
+func (q *deletionQueue) used() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tokens--
+}
+
+// deletionQueueState holds the deletion queue state
+type deletionQueueState struct {
+	Pods              []string `json:"pods"`
+	AllowedDeletions  float64  `json:"allowedDeletions"`
+	DeletionsInterval string   `json:"deletionsInterval"`
+}
+
+// state returns the queued Pods in deletion order and the deletions currently allowed
+func (q *deletionQueue) state() deletionQueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state := deletionQueueState{
+		Pods:              make([]string, 0, len(q.entries)),
+		AllowedDeletions:  q.tokens,
+		DeletionsInterval: q.interval.String(),
+	}
+	for _, pod := range q.entries {
+		state.Pods = append(state.Pods, pod.String())
+	}
+	return state
+}
+
+// cycleSummary holds the outcome of a cycle, written to --summary-file for systems that archive the runs
+type cycleSummary struct {
+	mu        sync.Mutex
+	Cycle     int                  `json:"cycle"`
+	StartedAt time.Time            `json:"startedAt"`
+	Duration  string               `json:"duration"`
+	Matched   int                  `json:"matched"`
+	Decisions map[k8s.Decision]int `json:"decisions"`
+	Deleted   []string             `json:"deleted"`
+	Owners    map[string]int       `json:"owners"` // deleted Pods by top-level owner, eg: Deployment/default/web
+}
+
+func newCycleSummary(cycle int, startedAt time.Time) *cycleSummary {
+	return &cycleSummary{
+		Cycle:     cycle,
+		StartedAt: startedAt.UTC(),
+		Decisions: make(map[k8s.Decision]int),
+		Deleted:   []string{},
+		Owners:    make(map[string]int),
+	}
+}
+
+// record counts the decision for a matched Pod, deleted Pods are listed and counted by owner (empty if the Pod has no owner)
+func (s *cycleSummary) record(pod, ns string, decision k8s.Decision, owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Decisions[decision]++
+	if decision == k8s.DecisionDeleted {
+		s.Deleted = append(s.Deleted, ns+"/"+pod)
+		if owner != "" {
+			s.Owners[owner]++
+		}
+	}
+}
+
+// write writes the summary as JSON to path, the file is overwritten
+// with appendTo the summary is appended as a single line instead, so the file holds one summary per cycle (JSON Lines)
+func (s *cycleSummary) write(path string, appendTo bool, finishedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Duration = finishedAt.Sub(s.StartedAt).Truncate(time.Millisecond).String()
+
+	if !appendTo {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Could not serialize cycle summary: %w", err)
+		}
+		err = os.WriteFile(path, append(data, '\n'), 0o644)
+		if err != nil {
+			return fmt.Errorf("Could not write cycle summary to %s: %w", path, err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("Could not serialize cycle summary: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("Could not write cycle summary to %s: %w", path, err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write cycle summary to %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseDeletionRate parses a deletion rate as count/duration, eg: 1/30s or 10/5m
+func parseDeletionRate(value string) (int, time.Duration, error) {
+	countValue, periodValue, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("Deletion rate %q is not valid, expected count/duration, eg: 1/30s", value)
+	}
+	count, err := strconv.Atoi(countValue)
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("Deletion rate %q is not valid, count must be a positive integer", value)
+	}
+	period, err := time.ParseDuration(periodValue)
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("Deletion rate %q is not valid, duration must be positive, eg: 30s", value)
+	}
+	return count, period, nil
+}
+
+// activeWindow is a daily time window during which deletions are allowed
+type activeWindow struct {
+	start time.Duration // since midnight
+	end   time.Duration // since midnight, before start for windows spanning midnight
+	loc   *time.Location
+	spec  string
+}
+
+// parseActiveWindow parses a daily time window as HH:MM-HH:MM in the IANA time zone tz
+func parseActiveWindow(value, tz string) (*activeWindow, error) {
+	startValue, endValue, ok := strings.Cut(value, "-")
+	if !ok {
+		return nil, fmt.Errorf("Active window %q is not valid, expected HH:MM-HH:MM, eg: 09:00-17:00", value)
+	}
+	start, err := time.Parse("15:04", startValue)
+	if err != nil {
+		return nil, fmt.Errorf("Active window %q is not valid, start must be HH:MM: %w", value, err)
+	}
+	end, err := time.Parse("15:04", endValue)
+	if err != nil {
+		return nil, fmt.Errorf("Active window %q is not valid, end must be HH:MM: %w", value, err)
+	}
+	if start.Equal(end) {
+		return nil, fmt.Errorf("Active window %q is not valid, start and end must differ", value)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("Active window time zone %q is not valid: %w", tz, err)
+	}
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &activeWindow{
+		start: start.Sub(midnight),
+		end:   end.Sub(midnight),
+		loc:   loc,
+		spec:  value + " " + loc.String(),
+	}, nil
+}
+
+// contains returns true if t is within the window, start included and end excluded
+func (w *activeWindow) contains(t time.Time) bool {
+	t = t.In(w.loc)
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start < w.end {
+		return sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	// the window spans midnight, eg: 22:00-06:00
+	return sinceMidnight >= w.start || sinceMidnight < w.end
+}
+
+// activeWindowState holds the result of the latest --active-window check
+type activeWindowState struct {
+	mu        sync.Mutex
+	checked   bool
+	active    bool
+	checkedAt time.Time
+}
+
+// deletionsAllowed returns false outside --active-window, the result is kept for /status
+// matched Pods are still checked and logged outside the window, they are not deleted
+func deletionsAllowed() bool {
+	if deletionWindow == nil {
+		return true
+	}
+	now := clk.Now()
+	active := deletionWindow.contains(now)
+	if !active {
+		log.Printf("Outside active window %s: matched Pods are checked and logged but not deleted in this cycle", deletionWindow.spec)
+	}
+
+	windowState.mu.Lock()
+	defer windowState.mu.Unlock()
+	windowState.checked = true
+	windowState.active = active
+	windowState.checkedAt = now.UTC()
+	return active
+}
+
+// stringSlice is a flag that can be set multiple times
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// build metadata, set at build time via -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
 )
 
 func initFlags() {
 	// define and parse cli params
+	flag.BoolVar(&showVersion, "version", false, "print version information and exit")
 	flag.BoolVar(&dryRunMode, "dry-run", false, "enable dry run mode (no changes are made, only logged)")
+	flag.BoolVar(&reportMode, "report", false, "print the Pods that would be deleted and exit, without deleting any Pods")
+	flag.StringVar(&reportFormat, "output", k8s.ReportFormatTable, "report output format: table, csv, json or yaml")
+	flag.StringVar(&reportFormat, "report-format", k8s.ReportFormatTable, "alias of --output")
+	flag.StringVar(&fixturesDir, "fixtures-dir", "", "load Pods and Events from YAML/JSON files in this directory instead of a cluster, print the Pods that would be deleted and exit")
 	flag.StringVar(&namespace, "namespace", "", "kubernetes namespace")
+	flag.IntVar(&namespaceShards, "namespace-shards", 0, "when scanning all namespaces, split them in this many shards and scan one shard per cycle, covering all namespaces over this many cycles (0 disables)")
+	flag.StringVar(&nodeCondition, "require-node-condition", "", "delete only Pending Pods assigned to a node with this condition True, eg: MemoryPressure or DiskPressure (empty disables)")
+	flag.StringVar(&nodeName, "node-name", "", "delete only Pods assigned to this node (unscheduled Pods are skipped)")
+	flag.BoolVar(&skipBoundPending, "skip-bound-pending", false, "skip Pending Pods already bound to a node (spec.nodeName is set), delete only unscheduled Pending Pods")
 	flag.StringVar(&eventReason, "reason", "FailedCreatePodSandBox", "restart Pods that match Event Reason")
+	flag.StringVar(&summaryFile, "summary-file", "", "write a JSON summary of every cycle to this file, eg: for CronJobs that archive their runs (empty disables)")
+	flag.BoolVar(&summaryAppend, "summary-file-append", false, "append the summary of every cycle to --summary-file as a JSON line, instead of overwriting it")
+	flag.StringVar(&heartbeatLease, "heartbeat-lease", "", "namespace/name of a Lease whose renew time is updated at the end of every cycle, so external monitoring can detect a stuck pod-restarter (created if it does not exist)")
+	flag.StringVar(&criteriaConfigMap, "criteria-configmap", "", "namespace/name of a ConfigMap with reason and error-message keys, reloaded every cycle, overrides --reason and --error-message")
+	flag.IntVar(&deleteConcurrency, "delete-concurrency", 1, "number of Pods checked and deleted in parallel")
+	flag.StringVar(&deletionOrder, "deletion-order", k8s.DeletionOrderOldestFirst, "order matched Pods are checked and deleted in: oldest-first, newest-first or random")
+	flag.BoolVar(&deleteFinalizers, "delete-with-finalizers", false, "delete Pods with finalizers, by default they are skipped because their deletion waits for the finalizers to be removed")
+	flag.BoolVar(&checkOwnerEvents, "check-owner-events", false, "also match Events of the owning ReplicaSet/Deployment of Pods, eg: FailedCreate because a quota is exceeded (adds API calls)")
+	flag.StringVar(&targetContainer, "target-container", "", "restart Pods only for Events about this container, eg: a sidecar (Pod level Events are not matched)")
+	flag.StringVar(&eventSource, "event-source", "", "restart Pods only for Events reported by this source component, eg: kubelet or default-scheduler")
+	flag.StringVar(&eventType, "event-type", "", "restart Pods only for Events of this type: Normal or Warning (empty matches all)")
+	flag.BoolVar(&drainOnShutdown, "drain-on-shutdown", false, "on SIGINT/SIGTERM run one final cycle before exiting")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 20*time.Second, "maximum duration of the final cycle run with --drain-on-shutdown, keep it below the Pod termination grace period")
+	flag.BoolVar(&exitOnPanic, "exit-on-panic", false, "exit when a cycle panics instead of recovering and continuing with the next cycle")
+	flag.DurationVar(&startupDelay, "startup-delay", 0, "time to wait before the first cycle, giving the cluster time to settle after a (re)start")
+	flag.IntVar(&minMatchCount, "min-match-count", 0, "match only Pods whose matching Events occurred at least this many times in total, using the Event count (0 disables)")
+	flag.IntVar(&maxEventsPerPod, "max-events-per-pod", 0, "keep at most this many matching Events per Pod, bounding memory during Event storms (0 keeps all)")
+	flag.DurationVar(&minStable, "min-stable-duration", 0, "skip Pods modified or with a status condition transition more recently than this, they might still be progressing (0 disables)")
+	flag.BoolVar(&ignorePreexisting, "ignore-preexisting", false, "match only Pods created after pod-restarter started, ignoring the backlog of Pods that already existed")
+	flag.DurationVar(&minEventOffset, "min-event-offset", 0, "restart Pods only for Events that happened at least this long after Pod creation, ignoring startup noise (0 disables)")
 	flag.IntVar(&pollingInterval, "polling-interval", 30, "number of seconds between iterations")
+	flag.BoolVar(&recheckHealed, "recheck-healed", false, "check Pods that self-healed once more in the next cycle, to catch Pods flapping between Running and Pending")
 	flag.StringVar(
 		&errorMessage,
 		"error-message",
 		"container veth name provided (eth0) already exists",
 		"number of seconds between iterations",
 	)
+	flag.IntVar(&maxRestarts, "max-container-restarts", 0, "delete matched Pods with containers restarted more than this many times, regardless of phase (0 disables)")
+	flag.BoolVar(&statusFallback, "status-fallback", false, "match Pod container states and conditions when listing Events is forbidden")
+	flag.IntVar(&skipPriorityAbove, "skip-priority-above", 0, "skip Pods with priority at or above this value (disabled if not set)")
+	flag.Int64Var(&listPageSize, "list-page-size", 500, "maximum number of Pods/Events returned by a single List call (0 disables pagination)")
+	flag.DurationVar(&deleteTTL, "delete-ttl", 0, "annotate matched Pods with a delete-after time and delete them in a later cycle once it has passed (0 deletes immediately)")
+	flag.IntVar(&getFailureLimit, "get-failure-threshold", 5, "warn when a matched Pod could not be fetched for this many consecutive cycles (0 disables)")
+	flag.IntVar(&conflictRetries, "conflict-retries", 1, "re-evaluate and delete again a Pod whose deletion conflicted (409 Conflict) up to this many times in the same cycle (0 disables)")
+	flag.BoolVar(&deleteDryRunCheck, "delete-dry-run-check", false, "before deleting a Pod, confirm with a server-side dry-run deletion that admission webhooks allow it")
+	flag.BoolVar(&allowCachedList, "allow-cached-list", false, "retry Pod/Event lists that timed out from the API server cache, which can be slightly stale")
+	flag.IntVar(&throttleRetries, "throttle-retries", 3, "retry requests throttled by the API server (429 Too Many Requests) up to this many times, after their Retry-After delay (0 disables)")
+	flag.BoolVar(&verifyDeletion, "verify-deletion", false, "after deleting a Pod, wait until it is gone and log if it is stuck terminating")
+	flag.DurationVar(&verifyTimeout, "verify-deletion-timeout", 60*time.Second, "how long to wait for a deleted Pod to be gone")
+	flag.DurationVar(&terminatingGrace, "terminating-grace", 0, "report Pods still terminating this long after they were deleted, eg: stuck on finalizers, checked every cycle (0 disables)")
+	flag.Float64Var(&minReadyNodes, "min-ready-node-fraction", 0, "skip deletions in a cycle if the fraction of Ready nodes is below this value, eg: 0.8 (0 disables)")
+	flag.IntVar(&minPendingPods, "min-pending-threshold", 0, "skip deletions in a cycle unless at least this many Pods matched in total, so only widespread issues are remediated (0 disables)")
+	flag.IntVar(&breakerThreshold, "circuit-breaker-threshold", 0, "pause all deletions when this many Pods are deleted within --circuit-breaker-window (0 disables)")
+	flag.DurationVar(&breakerWindow, "circuit-breaker-window", 10*time.Minute, "rolling window in which deletions are counted by the circuit breaker")
+	flag.DurationVar(&breakerCooldown, "circuit-breaker-cooldown", 30*time.Minute, "how long deletions are paused after the circuit breaker trips")
+	flag.IntVar(&ownerFailureLimit, "persistent-failure-threshold", 0, "stop deleting Pods of an owner once this many of its Pods were deleted within --persistent-failure-window and still match (0 disables)")
+	flag.DurationVar(&ownerFailureWin, "persistent-failure-window", time.Hour, "rolling window in which deletions are counted by owner for --persistent-failure-threshold")
+	flag.BoolVar(&cacheDecisions, "cache-decisions", false, "reuse the decision not to delete a matched Pod while the Pod does not change (same resourceVersion), instead of checking it again")
+	flag.BoolVar(&ignoreOldEvents, "ignore-events-before-deletion", false, "ignore Events from before a Pod with the same name was last deleted, eg: Events of a deleted StatefulSet Pod")
+	flag.StringVar(&activeWindowSpec, "active-window", "", "delete Pods only during this daily time window, as HH:MM-HH:MM, eg: 09:00-17:00 or 22:00-06:00 overnight (empty deletes at any time)")
+	flag.StringVar(&activeWindowTZ, "active-window-timezone", "UTC", "IANA time zone of --active-window, eg: Europe/London")
+	flag.StringVar(&deletionRate, "deletion-rate", "", "queue matched Pods and delete them at a steady rate across cycles, as count/duration, eg: 1/30s (empty deletes all matched Pods every cycle)")
+	flag.IntVar(&maxDeletions, "max-total-deletions", 0, "stop deleting Pods once this many Pods were deleted since pod-restarter started, Pods are still matched and logged (0 disables)")
+	flag.BoolVar(&exitOnBudget, "exit-on-budget-exhausted", false, "exit once --max-total-deletions Pods were deleted")
+	flag.StringVar(&maxPendingByOwner, "max-pending-by-owner", "", "delete Pending Pods only if Pending longer than the threshold of their owner kind, eg: DaemonSet=2m,Deployment=10m,default=5m")
+	flag.StringVar(&matchMode, "match-mode", "any", "how Events and the other match modes (eg: --unschedulable-timeout) are combined: any matches Pods matched by any of them, all by all of them")
+	flag.StringVar(&matchJSONPath, "match-jsonpath", "", "also match Pods for which this JSONPath expression has a non-empty result, eg: \"{.status.containerStatuses[?(@.restartCount>5)].name}\"")
+	flag.StringVar(&scheduleMsgRegex, "schedule-message-regex", "", "delete only Pods with a PodScheduled condition message matching this regex, eg: \"had taint \\{dedicated: gpu\\}\"")
+	flag.BoolVar(&ignorePVCPending, "ignore-pvc-pending", true, "skip Pods Pending on PersistentVolumeClaim binding, unless the targeted error message is about PersistentVolumeClaims")
+	flag.BoolVar(&respectAutoscaler, "respect-autoscaler", false, "skip Pending Pods with a scale-up in progress triggered by cluster-autoscaler (TriggeredScaleUp Event)")
+	flag.BoolVar(&allowMirrorPods, "allow-mirror-pods", false, "delete mirror Pods of static Pods managed by the kubelet (the kubelet recreates them right away)")
+	flag.BoolVar(&deleteOrphans, "delete-orphans", false, "delete Pods without owner/controller (they will not be recreated)")
+	flag.DurationVar(&unschedulableTime, "unschedulable-timeout", 0, "also delete Pending Pods that have been unschedulable for longer than this, without matching Events (0 disables)")
+	flag.BoolVar(&orphanedNodePods, "handle-orphaned-node-pods", false, "also match and force delete Pods assigned to a Node that does not exist anymore")
+	flag.StringVar(&reasonAnnotation, "reason-annotation", "", "annotate the owning controller with the reason its Pods were deleted for under this key, eg: pod-restarter.io/last-restart-reason (empty disables)")
+	flag.BoolVar(&annotateOwner, "annotate-owner", false, "annotate the owning Deployment/ReplicaSet/StatefulSet/DaemonSet with restart count and time when deleting its Pods")
+	flag.StringVar(&notifyWebhookURL, "notify-webhook-url", "", "post a JSON message to this URL for every deleted Pod (empty disables)")
+	flag.DurationVar(&notifyTimeout, "notify-timeout", 5*time.Second, "timeout of a single notification or Alertmanager request")
+	flag.StringVar(&alertmanagerURL, "alertmanager-url", "", "post alerts to this Alertmanager when the circuit breaker is open, an owner is a persistent failure or the deletion budget is exhausted, eg: http://alertmanager:9093 (empty disables)")
+	flag.StringVar(&onDeleteExec, "on-delete-exec", "", "run this command (program and arguments, not run by a shell) after every deleted Pod, with POD_RESTARTER_POD, POD_RESTARTER_NAMESPACE, POD_RESTARTER_OWNER and POD_RESTARTER_REASON set (empty disables)")
+	flag.DurationVar(&onDeleteTimeout, "on-delete-exec-timeout", 10*time.Second, "kill the --on-delete-exec command after this long (must be positive)")
+	flag.StringVar(&dumpDir, "dump-dir", "", "directory where Pod manifests are saved before deletion")
+	hostname, _ := os.Hostname()
+	flag.StringVar(&instanceName, "instance-name", hostname, "instance name added as a prefix to every log line (defaults to hostname)")
+	flag.StringVar(&logFormat, "log-format", "text", "log format: text or logfmt (key=value pairs, eg: level=info msg=\"...\")")
+	flag.StringVar(&pushgatewayURL, "pushgateway-url", "", "push the final metrics to this Prometheus Pushgateway when pod-restarter exits, eg: http://pushgateway:9091 (empty disables)")
+	flag.StringVar(&pushgatewayJob, "pushgateway-job", "pod-restarter", "job name the metrics are grouped by on the Pushgateway, eg: the CronJob name")
+	flag.StringVar(&httpAddr, "http-addr", "", "address to serve Prometheus metrics on /metrics, status on /status and in-memory state on /debug/state, eg: :8080 (empty disables)")
+	flag.BoolVar(&metricsNsLabel, "metrics-namespace-label", true, "add namespace label to metrics, disable in clusters with many namespaces")
+	flag.StringVar(&userAgent, "user-agent", "pod-restarter/"+version, "user agent sent with every request to the kubernetes API")
+	flag.BoolVar(&caseInsensitive, "case-insensitive", false, "ignore case when matching error messages")
+	flag.StringVar(&errorMessageFile, "error-message-file", "", "file with error messages to match in addition to --error-message, one per line (blank lines and lines starting with # are ignored), reloaded on SIGHUP")
+	flag.StringVar(&errorRegexExpr, "error-regex", "", "also match Events with a message matching this regex, the value of its (?P<reason>...) group is the category of the Pod in the decision log and metrics")
+	flag.IntVar(&maxCategories, "error-regex-max-categories", 20, "distinct categories extracted by --error-regex on the deleted Pods by category metric, later categories are counted as \"other\"")
+	flag.Var(
+		&errorMessagesAll,
+		"error-message-all",
+		"restart Pods only if all messages appear across their Events, regardless of Reason (repeat flag for each message)",
+	)
+	flag.Var(
+		&observeNamespaces,
+		"observe-namespace",
+		"only log and count matched Pods in this namespace, never delete them (repeat flag for each namespace)",
+	)
+	flag.Var(
+		&ignoreMessages,
+		"ignore-message",
+		"do not restart Pods with matching Events that also contain this message (repeat flag for each message)",
+	)
+	flag.Var(
+		&requireAnnots,
+		"require-annotation",
+		"delete only Pods with this annotation, eg: pod-restarter.io/enabled=true (repeat flag for each annotation, all are required)",
+	)
+	flag.Var(
+		&initWaitReasons,
+		"match-init-waiting-reason",
+		"also match Pods with an init container waiting for this reason, eg: CreateContainerConfigError (repeat flag for each reason)",
+	)
+	flag.Var(
+		&lastTermReasons,
+		"last-termination-reason",
+		"also match Pods with a container that last terminated for this reason, eg: OOMKilled (repeat flag for each reason)",
+	)
 	if home := homedir.HomeDir(); home != "" {
 		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
 	} else {
@@ -47,12 +692,539 @@ func initFlags() {
 	}
 }
 
+// serveHTTP serves Prometheus metrics and pod-restarter status
+func serveHTTP(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/status", statusHandler)
+	mux.HandleFunc("/debug/state", debugStateHandler)
+	log.Printf("Serving metrics on %s/metrics", addr)
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		log.Printf("HTTP server stopped: %v", err)
+	}
+}
+
+// statusHandler serves pod-restarter state as JSON
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	type clusterHealthStatus struct {
+		Paused            bool      `json:"paused"`
+		ReadyNodeFraction float64   `json:"readyNodeFraction"`
+		CheckedAt         time.Time `json:"checkedAt"`
+	}
+	type activeWindowStatus struct {
+		Window    string    `json:"window"`
+		Active    bool      `json:"active"`
+		CheckedAt time.Time `json:"checkedAt"`
+	}
+	status := struct {
+		Version        string                   `json:"version"`
+		CircuitBreaker *k8s.CircuitBreakerState `json:"circuitBreaker,omitempty"`
+		DeletionBudget *k8s.DeletionBudgetState `json:"deletionBudget,omitempty"`
+		ClusterHealth  *clusterHealthStatus     `json:"clusterHealth,omitempty"`
+		ActiveWindow   *activeWindowStatus      `json:"activeWindow,omitempty"`
+	}{
+		Version: version,
+	}
+	if circuitBreaker != nil {
+		state := circuitBreaker.State()
+		status.CircuitBreaker = &state
+	}
+	if deletionBudget != nil {
+		state := deletionBudget.State()
+		status.DeletionBudget = &state
+	}
+	clusterHealth.mu.Lock()
+	if clusterHealth.checked {
+		status.ClusterHealth = &clusterHealthStatus{
+			Paused:            clusterHealth.paused,
+			ReadyNodeFraction: clusterHealth.readyNodeFraction,
+			CheckedAt:         clusterHealth.checkedAt,
+		}
+	}
+	clusterHealth.mu.Unlock()
+	windowState.mu.Lock()
+	if windowState.checked {
+		status.ActiveWindow = &activeWindowStatus{
+			Window:    deletionWindow.spec,
+			Active:    windowState.active,
+			CheckedAt: windowState.checkedAt,
+		}
+	}
+	windowState.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Printf("Could not write status: %v", err)
+	}
+}
+
+// debugStateHandler serves the in-memory state that decides whether matched Pods are deleted as JSON
+// this answers "why isn't it deleting this Pod?" without attaching a debugger
+func debugStateHandler(w http.ResponseWriter, r *http.Request) {
+	state := struct {
+		CircuitBreaker     *k8s.CircuitBreakerState `json:"circuitBreaker,omitempty"`
+		DeletionBudget     *k8s.DeletionBudgetState `json:"deletionBudget,omitempty"`
+		DeletionQueue      *deletionQueueState      `json:"deletionQueue,omitempty"`
+		PersistentFailures *k8s.OwnerFailureState   `json:"persistentFailures,omitempty"`
+		GetFailures        map[string]int           `json:"getFailures"`
+	}{
+		GetFailures: getFailures.snapshot(),
+	}
+	if circuitBreaker != nil {
+		breakerState := circuitBreaker.State()
+		state.CircuitBreaker = &breakerState
+	}
+	if deletionBudget != nil {
+		budgetState := deletionBudget.State()
+		state.DeletionBudget = &budgetState
+	}
+	if queue != nil {
+		queueState := queue.state()
+		state.DeletionQueue = &queueState
+	}
+	if ownerFailures != nil {
+		ownerFailuresState := ownerFailures.State()
+		state.PersistentFailures = &ownerFailuresState
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(state)
+	if err != nil {
+		log.Printf("Could not write debug state: %v", err)
+	}
+}
+
+// processPod deletes a Pod that matched Event Reason if it passes all checks
+// the outcome is logged as a single decision line and returned
+// a deletion that conflicts with a controller (eg: the Pod is mid-transition) is re-evaluated up to --conflict-retries times
+func processPod(ctx context.Context, c k8s.K8sClient, pod, ns string) k8s.Decision {
+	for retry := 1; ; retry++ {
+		decision, detail, err := evaluatePod(ctx, c, pod, ns)
+		if apierrors.IsConflict(err) && retry <= conflictRetries && ctx.Err() == nil {
+			log.Printf("Deletion of Pod %s/%s conflicted, re-evaluating the Pod (retry %d/%d): %v", ns, pod, retry, conflictRetries, err)
+			continue
+		}
+		logDecision(pod, ns, decision, detail, c.MatchedPodCategory(pod, ns))
+		if summary != nil {
+			summary.record(pod, ns, decision, c.DeletedPodOwner(pod, ns))
+		}
+		return decision
+	}
+}
+
+// evaluatePod runs all checks for a Pod and deletes it if they pass
+// returns the decision, its detail and the error the Pod was not deleted for, if any
+func evaluatePod(ctx context.Context, c k8s.K8sClient, pod, ns string) (k8s.Decision, string, error) {
+	err := c.PodChecks(ctx, pod, ns)
+	trackGetFailures(pod, ns, k8s.DecisionOf(err) == k8s.DecisionErrorGetPod)
+	if healed != nil && k8s.DecisionOf(err) == k8s.DecisionSelfHealed {
+		healed.record(ns, pod)
+	}
+	if apierrors.IsNotFound(err) {
+		return k8s.DecisionSkippedNotFound, podGoneDetail, err
+	} else if err != nil {
+		return k8s.DecisionOf(err), err.Error(), err
+	}
+
+	if observeOnly[ns] {
+		metrics.PodObserved(ns)
+		return k8s.DecisionObserved, "namespace is observe-only, Pod would have been deleted", nil
+	}
+	if windowClosed {
+		return k8s.DecisionSkippedActiveWindow, "outside active window " + deletionWindow.spec + ", Pod would have been deleted", nil
+	}
+	if dryRunMode {
+		return k8s.DecisionDryRun, "dry run mode, Pod would have been deleted", nil
+	}
+	// delete Pod
+	err = c.DeletePod(ctx, pod, ns)
+	if apierrors.IsNotFound(err) {
+		return k8s.DecisionSkippedNotFound, podGoneDetail, err
+	} else if err != nil {
+		return k8s.DecisionOf(err), err.Error(), err
+	}
+	return k8s.DecisionDeleted, "", nil
+}
+
+// podGoneDetail is the detail of matched Pods deleted by someone else (eg: their controller or a human) during the heal time
+// the issue resolved without pod-restarter, this is not an error
+const podGoneDetail = "Pod is already gone, it was deleted by someone else since it was matched"
+
+// trackGetFailures escalates matched Pods that could not be fetched for --get-failure-threshold consecutive cycles
+// these Pods can neither be evaluated nor cleaned up
+func trackGetFailures(pod, ns string, failed bool) {
+	if getFailureLimit <= 0 {
+		return
+	}
+	failures := getFailures.record(ns, pod, failed)
+	if failures >= getFailureLimit {
+		log.Printf("WARNING: Pod %s/%s could not be fetched for %d consecutive cycles", ns, pod, failures)
+		metrics.PodGetFailed(ns)
+	}
+}
+
+// logDecision logs why a matched Pod was or was not deleted, as a machine-parsable key=value line
+// the category extracted by --error-regex is logged if there is one
+func logDecision(pod, ns string, decision k8s.Decision, detail, category string) {
+	if logFormat == "logfmt" {
+		line := fmt.Sprintf("DECISION pod=%s namespace=%s decision=%s detail=%s", logfmtValue(pod), logfmtValue(ns), decision, logfmtValue(detail))
+		if category != "" {
+			line += " category=" + logfmtValue(category)
+		}
+		log.Print(line)
+		return
+	}
+	line := fmt.Sprintf("DECISION pod=%s/%s decision=%s detail=%q", ns, pod, decision, detail)
+	if category != "" {
+		line += fmt.Sprintf(" category=%q", category)
+	}
+	log.Print(line)
+}
+
+// logFormats are the accepted values of --log-format
+var logFormats = []string{"text", "logfmt"}
+
+// logfmtWriter writes log lines as logfmt, eg: time=2022-11-20T10:00:00Z level=info msg="DELETED Pod default/foo"
+// lines starting with WARNING: are logged at level warn, DECISION lines keep their key=value fields
+type logfmtWriter struct {
+	out      io.Writer
+	instance string
+	now      func() time.Time
+}
+
+// Write writes a log line, the log package calls Write once per line
+func (w *logfmtWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	level := "info"
+	if strings.HasPrefix(line, "WARNING: ") {
+		level = "warn"
+		line = strings.TrimPrefix(line, "WARNING: ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time=%s level=%s", w.now().UTC().Format(time.RFC3339), level)
+	if w.instance != "" {
+		fmt.Fprintf(&b, " instance=%s", logfmtValue(w.instance))
+	}
+	if strings.HasPrefix(line, "DECISION ") {
+		fmt.Fprintf(&b, " msg=DECISION %s\n", strings.TrimPrefix(line, "DECISION "))
+	} else {
+		fmt.Fprintf(&b, " msg=%s\n", logfmtValue(line))
+	}
+	_, err := io.WriteString(w.out, b.String())
+	return len(p), err
+}
+
+// logfmtValue returns value quoted if it is empty or has spaces, quotes, equal signs, backslashes or control characters
+func logfmtValue(value string) string {
+	needsQuotes := strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f
+	}) >= 0
+	if value == "" || needsQuotes {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
 func main() {
 
+	// Pods created before pod-restarter started are ignored with --ignore-preexisting
+	startTime := clk.Now()
+
 	// parse CLI params
 	initFlags()
 	flag.Parse()
 
+	if showVersion {
+		fmt.Printf("pod-restarter version: %s, commit: %s, built at: %s\n", version, commit, date)
+		os.Exit(0)
+	}
+
+	if !contains(logFormats, logFormat) {
+		log.Printf("--log-format must be one of %v, got %s", logFormats, logFormat)
+		os.Exit(1)
+	}
+	// tag every log line, so logs of multiple instances can be told apart
+	if logFormat == "logfmt" {
+		log.SetFlags(0)
+		log.SetOutput(&logfmtWriter{out: os.Stderr, instance: instanceName, now: clk.Now})
+	} else if instanceName != "" {
+		log.SetPrefix(fmt.Sprintf("[%s] ", instanceName))
+	}
+
+	if deleteConcurrency < 1 {
+		log.Printf("--delete-concurrency must be at least 1, got %d", deleteConcurrency)
+		os.Exit(1)
+	}
+	if err := validateCycleTiming(pollingInterval, healTime); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	pendingByOwner, err := k8s.ParseOwnerDurations(maxPendingByOwner)
+	if err != nil {
+		log.Printf("--max-pending-by-owner %v", err)
+		os.Exit(1)
+	}
+	requiredAnnotations, err := k8s.ParseAnnotations(requireAnnots)
+	if err != nil {
+		log.Printf("--require-annotation %v", err)
+		os.Exit(1)
+	}
+	if matchJSONPath != "" {
+		if _, err := k8s.ParseJSONPath(matchJSONPath); err != nil {
+			log.Printf("--match-jsonpath is not valid: %v", err)
+			os.Exit(1)
+		}
+	}
+	var scheduleMessageRegex *regexp.Regexp
+	if scheduleMsgRegex != "" {
+		scheduleMessageRegex, err = regexp.Compile(scheduleMsgRegex)
+		if err != nil {
+			log.Printf("--schedule-message-regex is not valid: %v", err)
+			os.Exit(1)
+		}
+	}
+	if criteriaConfigMap != "" {
+		if _, _, err := k8s.ParseConfigMapRef(criteriaConfigMap); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+	if errorMessageFile != "" {
+		messages, err := k8s.ReadErrorMessageFile(errorMessageFile)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		log.Printf("Loaded %d error messages from %s", len(messages), errorMessageFile)
+		errorMessages = k8s.NewErrorMessages(messages)
+	}
+	if errorRegexExpr != "" {
+		errorRegex, err = k8s.CompileErrorRegex(errorRegexExpr)
+		if err != nil {
+			log.Printf("--error-regex is not valid: %v", err)
+			os.Exit(1)
+		}
+		if !k8s.HasCategory(errorRegex) {
+			log.Println("--error-regex has no (?P<reason>...) group, matched Pods are not categorized")
+		}
+	}
+	if maxCategories < 1 {
+		log.Println("--error-regex-max-categories must be at least 1")
+		os.Exit(1)
+	}
+	if activeWindowSpec != "" {
+		deletionWindow, err = parseActiveWindow(activeWindowSpec, activeWindowTZ)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+	if heartbeatLease != "" {
+		if _, _, err := k8s.ParseLeaseRef(heartbeatLease); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+	if err := k8s.ValidDeletionOrder(deletionOrder); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	if eventType != "" && eventType != corev1.EventTypeNormal && eventType != corev1.EventTypeWarning {
+		log.Printf("--event-type must be %s or %s, got %s", corev1.EventTypeNormal, corev1.EventTypeWarning, eventType)
+		os.Exit(1)
+	}
+	if namespaceShards > 1 && namespace != "" {
+		log.Printf("--namespace-shards requires scanning all namespaces, --namespace is set to %s", namespace)
+		os.Exit(1)
+	}
+	if !contains(k8s.MatchModes, matchMode) {
+		log.Printf("--match-mode must be one of %v, got %s", k8s.MatchModes, matchMode)
+		os.Exit(1)
+	}
+	if !contains(k8s.ReportFormats, reportFormat) {
+		log.Printf("--output must be one of %v, got %s", k8s.ReportFormats, reportFormat)
+		os.Exit(1)
+	}
+	// a namespace that is both active and observed is only observed
+	for _, ns := range observeNamespaces {
+		if ns == namespace {
+			log.Printf("WARNING: namespace %s is set with both --namespace and --observe-namespace, Pods will only be observed", ns)
+		}
+		observeOnly[ns] = true
+	}
+	// Pods Pending on PersistentVolumeClaim binding are not skipped if the user targets that error
+	if ignorePVCPending && targetsPVCBinding() {
+		log.Println("Targeted error message is about PersistentVolumeClaims, Pods Pending on PersistentVolumeClaim binding are not skipped")
+		ignorePVCPending = false
+	}
+	// only protect high priority Pods if --skip-priority-above is set
+	var maxPriority *int32
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "skip-priority-above" {
+			value := int32(skipPriorityAbove)
+			maxPriority = &value
+		}
+	})
+
+	// the circuit breaker outlives the k8s client, which is created every cycle
+	if breakerThreshold > 0 {
+		circuitBreaker = k8s.NewCircuitBreaker(breakerThreshold, breakerWindow, breakerCooldown, clk)
+	}
+	// self-healed Pods are remembered until the next cycle, with some slack for slow cycles
+	if recheckHealed {
+		healed = newHealedTracker(2 * time.Duration(pollingInterval) * time.Second)
+	}
+	if ownerFailureLimit > 0 {
+		ownerFailures = k8s.NewOwnerFailureTracker(ownerFailureLimit, ownerFailureWin, clk)
+	}
+	if ignoreOldEvents {
+		deletionHistory = k8s.NewDeletionHistory(clk)
+	}
+	if cacheDecisions {
+		decisionCache = k8s.NewDecisionCache(clk)
+	}
+	if terminatingGrace > 0 {
+		terminating = k8s.NewTerminatingTracker(terminatingGrace, clk)
+	}
+	if deletionRate != "" {
+		count, period, err := parseDeletionRate(deletionRate)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		queue = newDeletionQueue(count, period)
+	}
+	if maxDeletions > 0 {
+		deletionBudget = k8s.NewDeletionBudget(maxDeletions)
+	}
+
+	metrics.SetNamespaceLabel(metricsNsLabel)
+	metrics.SetMaxCategories(maxCategories)
+	if httpAddr != "" {
+		go serveHTTP(httpAddr)
+	}
+
+	log.Printf("Starting pod-restarter version: %s, commit: %s, built at: %s", version, commit, date)
+
+	var notifiers []notify.Notifier
+	if notifyWebhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhook(notifyWebhookURL, notifyTimeout))
+	}
+	if onDeleteExec != "" {
+		hook, err := notify.NewExec(onDeleteExec, onDeleteTimeout)
+		if err != nil {
+			log.Printf("--on-delete-exec is not valid: %v", err)
+			os.Exit(1)
+		}
+		// the hook runs in the background, a hanging command does not hold up deletions
+		onDeleteHook = notify.NewAsync(hook, onDeleteQueueSize)
+		notifiers = append(notifiers, onDeleteHook)
+	}
+	if alertmanagerURL != "" {
+		alerts = notify.NewAlertmanager(alertmanagerURL, notifyTimeout, clk)
+	}
+
+	var notifier notify.Notifier
+	if len(notifiers) > 0 {
+		notifier = notify.WithFields(notify.Multi(notifiers...), eventReason, instanceName)
+	}
+
+	var ignoreCreatedBefore time.Time
+	if ignorePreexisting {
+		ignoreCreatedBefore = startTime
+	}
+	clientOptions = k8s.Options{
+		UserAgent:             userAgent,
+		InstanceName:          instanceName,
+		MaxContainerRestarts:  int32(maxRestarts),
+		StatusFallback:        statusFallback,
+		ErrorMessagesAll:      errorMessagesAll,
+		ErrorMessages:         errorMessages,
+		ErrorRegex:            errorRegex,
+		DumpDir:               dumpDir,
+		SkipPriorityAbove:     maxPriority,
+		ListPageSize:          listPageSize,
+		AllowCachedList:       allowCachedList,
+		ThrottleRetries:       throttleRetries,
+		VerifyDeletion:        verifyDeletion,
+		VerifyDeletionTimeout: verifyTimeout,
+		EventSource:           eventSource,
+		TargetContainer:       targetContainer,
+		EventType:             eventType,
+		CheckOwnerEvents:      checkOwnerEvents,
+		DeleteWithFinalizers:  deleteFinalizers,
+		NodeName:              nodeName,
+		SkipBoundPending:      skipBoundPending,
+		RequireNodeCondition:  corev1.NodeConditionType(nodeCondition),
+		DeleteOrphans:         deleteOrphans,
+		AllowMirrorPods:       allowMirrorPods,
+		RequireAnnotations:    requiredAnnotations,
+		OrphanedNodePods:      orphanedNodePods,
+		UnschedulableTimeout:  unschedulableTime,
+		CaseInsensitive:       caseInsensitive,
+		AnnotateOwner:         annotateOwner,
+		ReasonAnnotation:      reasonAnnotation,
+		IgnoreMessages:        ignoreMessages,
+		MaxEventsPerPod:       maxEventsPerPod,
+		MinMatchCount:         int32(minMatchCount),
+		IgnorePVCPending:      ignorePVCPending,
+		RespectAutoscaler:     respectAutoscaler,
+		MaxPendingByOwner:     pendingByOwner,
+		ScheduleMessageRegex:  scheduleMessageRegex,
+		InitWaitingReasons:    initWaitReasons,
+		TerminationReasons:    lastTermReasons,
+		MatchJSONPath:         matchJSONPath,
+		MatchAll:              matchMode == "all",
+		MinStableDuration:     minStable,
+		MinEventOffset:        minEventOffset,
+		IgnoreCreatedBefore:   ignoreCreatedBefore,
+		DeleteTTL:             deleteTTL,
+		DeleteDryRunCheck:     deleteDryRunCheck,
+		CircuitBreaker:        circuitBreaker,
+		PersistentFailures:    ownerFailures,
+		DeletionHistory:       deletionHistory,
+		DecisionCache:         decisionCache,
+		TerminatingTracker:    terminating,
+		DeletionBudget:        deletionBudget,
+		Notifier:              notifier,
+		Clock:                 clk,
+	}
+	// every match mode is a matcher, custom matchers are added here
+	clientOptions.Matchers = k8s.PodMatchers(clientOptions)
+
+	if reportMode || fixturesDir != "" {
+		err := runReport(context.Background())
+		pushMetrics()
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// push the final metrics when pod-restarter stops, eg: when it runs as a CronJob
+	defer pushMetrics()
+	defer closeOnDeleteHook()
+
+	// cancel ctx on SIGINT/SIGTERM, so pod-restarter stops between Pods and cycles
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// reload --error-message-file on SIGHUP, eg: after the mounted ConfigMap was updated
+	if errorMessages != nil {
+		reloadOnSIGHUP(ctx, reloadErrorMessages)
+	}
+
+	// give the cluster time to settle, eg: Pods Pending because nodes just (re)started
+	if startupDelay > 0 {
+		log.Printf("Waiting %v before the first cycle", startupDelay)
+		if sleepContext(ctx, startupDelay) != nil {
+			log.Println("Shutting down")
+			return
+		}
+	}
+
 	// we use this counter in first iteration where we look at all Events in the cluster
 	// if counter > 0 we filter out events older than polling interval
 	counter := 0
@@ -60,44 +1232,599 @@ func main() {
 	for {
 		log.Printf("Running every %d seconds", pollingInterval)
 
-		// authenticate to k8s cluster and initialise k8s client
-		c, err := k8s.NewK8sClient(*kubeconfig)
+		err := safeRun(ctx, runOnce, counter)
 		if err != nil {
 			log.Println(err)
+			pushMetrics()
 			os.Exit(1)
 		}
 
-		// generate a unique list of Pods that match Event Reason
-		// we do this because a Pod might have multiple Events with the same Reason
-		uniquePodList, err := c.GenerateToBeDeletedPodList(ctx, namespace, eventReason, errorMessage, counter, pollingInterval)
+		if exitOnBudget && deletionBudget != nil && deletionBudget.State().Exhausted {
+			log.Printf("Exiting: deletion budget of %d Pods is exhausted", maxDeletions)
+			return
+		}
+
+		// sleep for n seconds
+		if sleepContext(ctx, cycleSleep(pollingInterval, healTime)) != nil {
+			if drainOnShutdown {
+				drain(runOnce, counter+1)
+			}
+			log.Println("Shutting down")
+			return
+		}
+		counter += 1
+	}
+}
+
+// validateCycleTiming returns error if the polling interval does not leave time between cycles after the heal time
+// every cycle sleeps heal time (between matching and deleting Pods) and then the rest of the polling interval
+func validateCycleTiming(pollingInterval int, healTime time.Duration) error {
+	if pollingInterval <= int(healTime) {
+		return fmt.Errorf(
+			"--polling-interval must be greater than the heal time of %d seconds, got %d: cycles would run back-to-back",
+			int(healTime), pollingInterval,
+		)
+	}
+	return nil
+}
+
+// cycleSleep returns how long to sleep after a cycle, so a cycle starts every polling interval
+// the heal time already slept during the cycle is subtracted, the result is never negative
+func cycleSleep(pollingInterval int, healTime time.Duration) time.Duration {
+	sleep := time.Duration(pollingInterval-int(healTime)) * time.Second
+	if sleep < 0 {
+		return 0
+	}
+	return sleep
+}
+
+// pushMetrics pushes the metrics to --pushgateway-url, push failures are logged and are not fatal
+func pushMetrics() {
+	if pushgatewayURL == "" {
+		return
+	}
+	err := metrics.Push(pushgatewayURL, pushgatewayJob)
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+		return
+	}
+	log.Printf("Pushed metrics to %s (job %s)", pushgatewayURL, pushgatewayJob)
+}
+
+// onDeleteQueueSize is how many --on-delete-exec commands can wait to run, the command is not run for Pods deleted while the queue is full
+const onDeleteQueueSize = 100
+
+// closeOnDeleteHook waits up to --drain-timeout for the queued --on-delete-exec commands to run
+func closeOnDeleteHook() {
+	if onDeleteHook == nil {
+		return
+	}
+	closeCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	err := onDeleteHook.Close(closeCtx)
+	if err != nil {
+		log.Printf("WARNING: --on-delete-exec: %v", err)
+	}
+}
+
+// drain runs one final cycle with a fresh context that expires after --drain-timeout
+func drain(cycle func(context.Context, int) error, counter int) {
+	log.Printf("Running a final drain cycle before shutting down (timeout %v)", drainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := safeRun(ctx, cycle, counter)
+	if err != nil {
+		log.Println(err)
+	}
+	log.Println("Drain cycle finished")
+}
+
+// sleepContext sleeps for d, returns ctx error if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// safeRun runs a single cycle and recovers from panics, so one bad cycle does not stop the control loop
+// with --exit-on-panic the panic is returned as an error instead
+func safeRun(ctx context.Context, cycle func(context.Context, int) error, counter int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(r)
+			if exitOnPanic {
+				err = fmt.Errorf("Exiting after panic: %v", r)
+			}
+		}
+	}()
+	return cycle(ctx, counter)
+}
+
+// logPanic logs a recovered panic with its stack trace and counts it
+func logPanic(r interface{}) {
+	log.Printf("Recovered from panic: %v\n%s", r, debug.Stack())
+	metrics.RecoveredPanics.Inc()
+}
+
+// reporter generates the report of Pods that would be deleted
+type reporter interface {
+	criteriaGetter
+	GenerateReport(ctx context.Context, namespace, eventReason, errorMessage string) ([]k8s.ReportEntry, error)
+}
+
+// runReport prints the Pods that would be deleted by a first cycle, sorted by namespace and name
+// with --fixtures-dir Pods and Events are loaded from files instead of the cluster
+// logs go to stderr, so the report on stdout can be redirected to a file
+func runReport(ctx context.Context) error {
+	var c reporter
+	var err error
+	if fixturesDir != "" {
+		c, err = k8s.NewFixturesClient(fixturesDir, clientOptions)
+	} else {
+		c, err = k8s.NewK8sClient(*kubeconfig, clientOptions)
+	}
+	if err != nil {
+		return err
+	}
+
+	if criteriaConfigMap != "" {
+		loadCriteria(ctx, c)
+	}
+	entries, err := c.GenerateReport(ctx, namespace, eventReason, errorMessage)
+	if err != nil {
+		return err
+	}
+	return k8s.WriteReport(os.Stdout, entries, reportFormat)
+}
+
+// runOnce runs a single cycle: it finds Pods that match Event Reason and deletes the ones that pass all checks
+// returns error only if the k8s client cannot be initialised
+func runOnce(ctx context.Context, counter int) error {
+	// authenticate to k8s cluster and initialise k8s client
+	c, err := k8s.NewK8sClient(*kubeconfig, clientOptions)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		log.Printf("This cycle made %s API calls", k8s.FormatAPICalls(c.APICalls()))
+	}()
+
+	// the summary is written when the cycle ends, also when it ends early (eg: on shutdown)
+	if summaryFile != "" {
+		summary = newCycleSummary(counter, clk.Now())
+		defer func() {
+			err := summary.write(summaryFile, summaryAppend, clk.Now().UTC())
+			if err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+		}()
+	}
+
+	// warn on startup if the targeted node does not exist
+	if counter == 0 && nodeName != "" {
+		err = c.VerifyNodeExists(ctx, nodeName)
 		if err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+	}
+
+	if criteriaConfigMap != "" {
+		loadCriteria(ctx, c)
+	}
+
+	// generate a unique list of Pods that match Event Reason, for every namespace that is scanned
+	// we do this because a Pod might have multiple Events with the same Reason
+	var podLists []map[string]string
+	matched := 0
+	namespaces, shardCounter, shardInterval := scannedNamespaces(), counter, pollingInterval
+	if namespaceShards > 1 {
+		namespaces = shardNamespaces(ctx, c, counter)
+		// every namespace is scanned once every namespaceShards cycles, Events since its previous scan are matched
+		shardCounter, shardInterval = counter/namespaceShards, pollingInterval*namespaceShards
+	}
+	for _, ns := range namespaces {
+		uniquePodList, err := c.GenerateToBeDeletedPodList(ctx, ns, eventReason, errorMessage, shardCounter, shardInterval)
+		// API calls in flight fail when shutting down, this is expected
+		if k8s.IsCanceled(ctx, err) {
+			log.Println("Shutting down: stopped matching Pods")
+			return nil
+		} else if err != nil {
 			log.Println(err)
 		}
+		for _, ns := range uniquePodList {
+			metrics.PodMatched(ns)
+		}
+		if summary != nil {
+			summary.Matched += len(uniquePodList)
+		}
+		matched += len(uniquePodList)
+		podLists = append(podLists, uniquePodList)
+	}
+	metrics.CycleMatchedPods.Set(float64(matched))
+	c.ReportMatchedPodAges()
 
-		// allow Pending Pods a few seconds to self heal
-		time.Sleep(healTime * time.Second)
+	// housekeeping runs on every path the cycle ends on from here, also while remediation is paused
+	defer finishCycle(ctx, c)
 
-		// iterate through the list of Pods that match Event Reason
-		for pod, ns := range uniquePodList {
+	// check Pods that self-healed in the previous cycle once more, even if they no longer match
+	if healed != nil {
+		recheck := healed.take(podLists)
+		if len(recheck) > 0 {
+			log.Printf("Rechecking %d Pods that self-healed in the previous cycle", len(recheck))
+			podLists = append(podLists, recheck)
+		}
+	}
 
-			err = c.PodChecks(ctx, pod, ns)
-			if err != nil {
-				log.Println(err)
+	// do not amplify an outage by deleting Pods while many nodes are NotReady
+	// the cycle still completed, pod-restarter is not stuck
+	if minReadyNodes > 0 && !clusterHealthy(ctx, c) {
+		return nil
+	}
+
+	// isolated Pending Pods might self-resolve, only remediate an issue that affects many Pods
+	if !widespreadIssue(matched) {
+		return nil
+	}
+
+	// allow Pending Pods a few seconds to self heal
+	if sleepContext(ctx, healTime*time.Second) != nil {
+		return nil
+	}
+
+	// the window is evaluated once per cycle, when the deletion phase starts
+	windowClosed = !deletionsAllowed()
+
+	for _, uniquePodList := range podLists {
+		if queue != nil {
+			queue.push(c.OrderPods(ctx, uniquePodList, deletionOrder))
+			continue
+		}
+		deletePods(ctx, c, c.OrderPods(ctx, uniquePodList, deletionOrder))
+	}
+	if queue != nil {
+		deleteQueuedPods(ctx, c, queue)
+	}
+	return nil
+}
+
+// cycleFinisher is the part of the k8s client used at the end of a cycle
+type cycleFinisher interface {
+	heartbeatRenewer
+	CheckStuckTerminating(ctx context.Context) int
+}
+
+// finishCycle runs the end of cycle housekeeping, whether Pods were deleted in the cycle or not
+// while remediation is paused (eg: the cluster is unhealthy) resolved alerts are still resolved and stuck Pods still reported
+func finishCycle(ctx context.Context, c cycleFinisher) {
+	// deletions that did not achieve their goal need manual intervention
+	if ctx.Err() == nil {
+		c.CheckStuckTerminating(ctx)
+	}
+	updateAlerts()
+	getFailures.prune()
+	renewHeartbeat(ctx, c)
+}
+
+// firingAlerts returns the conditions that need a human, as alerts labelled with the pod-restarter instance
+func firingAlerts() []notify.Alert {
+	var firing []notify.Alert
+	alert := func(name, summary string, labels map[string]string) {
+		labels["alertname"] = name
+		labels["severity"] = "warning"
+		labels["instance"] = instanceName
+		firing = append(firing, notify.Alert{Labels: labels, Annotations: map[string]string{"summary": summary}})
+	}
+	if circuitBreaker != nil {
+		if state := circuitBreaker.State(); state.Open {
+			alert("PodRestarterCircuitBreakerOpen", fmt.Sprintf(
+				"%d Pods were deleted within %s, deletions are paused until %s",
+				state.RecentDeletions, state.Window, state.OpenUntil.UTC().Format(time.RFC3339),
+			), map[string]string{})
+		}
+	}
+	if ownerFailures != nil {
+		state := ownerFailures.State()
+		for _, owner := range state.Flagged {
+			// flagged owners are cleared lazily, they are resolved once their deletions are out of the window
+			if state.RecentDeletions[owner] < state.Threshold {
 				continue
 			}
+			alert("PodRestarterPersistentFailure", fmt.Sprintf(
+				"Pods of %s were deleted %d times within %s and keep failing, they are not deleted anymore",
+				owner, state.RecentDeletions[owner], state.Window,
+			), map[string]string{"owner": owner})
+		}
+	}
+	if deletionBudget != nil {
+		if state := deletionBudget.State(); state.Exhausted {
+			alert("PodRestarterDeletionBudgetExhausted", fmt.Sprintf("All %d deletions of the deletion budget were used, Pods are not deleted anymore", state.Limit), map[string]string{})
+		}
+	}
+	return firing
+}
 
-			if dryRunMode {
-				log.Printf("[DRY-RUN]: Would have deleted Pod: %s/%s", ns, pod)
-				continue
+// updateAlerts posts firing and resolved alerts to --alertmanager-url, errors are logged and are not fatal
+// alerts are posted also when shutting down, requests are bounded by --notify-timeout
+func updateAlerts() {
+	if alerts == nil {
+		return
+	}
+	err := alerts.Update(context.Background(), firingAlerts())
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// heartbeatRenewer renews the heartbeat Lease
+type heartbeatRenewer interface {
+	RenewHeartbeat(ctx context.Context, namespace, name, holder string, duration time.Duration) error
+}
+
+// renewHeartbeat renews the --heartbeat-lease Lease at the end of a cycle, errors are logged and are not fatal
+// the Lease duration is two polling intervals, so a single slow cycle does not look like a stuck pod-restarter
+func renewHeartbeat(ctx context.Context, c heartbeatRenewer) {
+	if heartbeatLease == "" || ctx.Err() != nil {
+		return
+	}
+	leaseNamespace, leaseName, _ := k8s.ParseLeaseRef(heartbeatLease)
+	err := c.RenewHeartbeat(ctx, leaseNamespace, leaseName, instanceName, 2*time.Duration(pollingInterval)*time.Second)
+	if err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
+
+// reloadOnSIGHUP calls reload every time pod-restarter receives SIGHUP, until ctx is cancelled
+// SIGHUP is handled once reloadOnSIGHUP returns, instead of stopping pod-restarter
+func reloadOnSIGHUP(ctx context.Context, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload()
 			}
-			// delete Pod
-			err := c.DeletePod(ctx, pod, ns)
-			if err != nil {
-				log.Println(err)
+		}
+	}()
+}
+
+// reloadErrorMessages replaces the error messages with the contents of --error-message-file
+// the loaded messages are kept if the file cannot be read
+func reloadErrorMessages() {
+	messages, err := k8s.ReadErrorMessageFile(errorMessageFile)
+	if err != nil {
+		log.Printf("WARNING: %v. Keeping %d error messages loaded before", err, len(errorMessages.List()))
+		return
+	}
+	errorMessages.Set(messages)
+	log.Printf("Reloaded %d error messages from %s", len(messages), errorMessageFile)
+}
+
+// criteriaGetter reads matching criteria from a ConfigMap
+type criteriaGetter interface {
+	GetCriteria(ctx context.Context, namespace, name string) (*k8s.Criteria, error)
+}
+
+// loadCriteria sets Event Reason and Error Message from the criteria ConfigMap
+// the last good criteria are kept if the ConfigMap cannot be read or is not valid
+func loadCriteria(ctx context.Context, c criteriaGetter) {
+	cmNamespace, cmName, _ := k8s.ParseConfigMapRef(criteriaConfigMap)
+	criteria, err := c.GetCriteria(ctx, cmNamespace, cmName)
+	if err != nil {
+		log.Printf("WARNING: %v. Keeping Reason: %s and Message: %s", err, eventReason, errorMessage)
+		return
+	}
+	if criteria.Reason != eventReason || criteria.ErrorMessage != errorMessage {
+		log.Printf("Loaded criteria from ConfigMap %s: Reason: %s, Message: %s", criteriaConfigMap, criteria.Reason, criteria.ErrorMessage)
+		eventReason = criteria.Reason
+		errorMessage = criteria.ErrorMessage
+	}
+}
+
+// targetsPVCBinding returns true if the targeted error messages are about PersistentVolumeClaim binding
+func targetsPVCBinding() bool {
+	if len(errorMessagesAll) > 0 {
+		for _, msg := range errorMessagesAll {
+			if k8s.IsPVCBindingMessage(msg) {
+				return true
 			}
+		}
+		return false
+	}
+	return k8s.IsPVCBindingMessage(errorMessage)
+}
+
+// nodeHealthChecker returns the fraction of Ready nodes
+type nodeHealthChecker interface {
+	ReadyNodeFraction(ctx context.Context) (float64, error)
+}
+
+// clusterHealthy returns false if the fraction of Ready nodes is below --min-ready-node-fraction
+// or if nodes cannot be listed, the result is kept for /status
+func clusterHealthy(ctx context.Context, c nodeHealthChecker) bool {
+	fraction, err := c.ReadyNodeFraction(ctx)
+	if k8s.IsCanceled(ctx, err) {
+		return false
+	}
+	paused := err != nil || fraction < minReadyNodes
+	if err != nil {
+		log.Printf("WARNING: %v. Remediation is paused for this cycle", err)
+	} else if paused {
+		log.Printf("WARNING: %.2f of nodes are Ready (min %.2f). Remediation is paused for this cycle due to cluster health", fraction, minReadyNodes)
+	}
 
+	clusterHealth.mu.Lock()
+	defer clusterHealth.mu.Unlock()
+	clusterHealth.checked = true
+	clusterHealth.paused = paused
+	clusterHealth.readyNodeFraction = fraction
+	clusterHealth.checkedAt = clk.Now().UTC()
+	return !paused
+}
+
+// widespreadIssue returns false if fewer Pods than --min-pending-threshold matched in this cycle
+func widespreadIssue(matched int) bool {
+	if minPendingPods <= 0 || matched >= minPendingPods {
+		return true
+	}
+	if matched > 0 {
+		log.Printf("%d Pods matched (min %d). Deletions are skipped for this cycle, waiting for a widespread issue", matched, minPendingPods)
+	}
+	return false
+}
+
+// namespaceLister lists namespaces
+type namespaceLister interface {
+	ListNamespaces(ctx context.Context) ([]string, error)
+}
+
+// shardNamespaces returns the namespaces of the shard scanned in this cycle, shards are scanned in turn
+// namespaces are assigned to shards by name hash, so adding or removing a namespace does not move the others
+// all namespaces are scanned if namespaces cannot be listed
+func shardNamespaces(ctx context.Context, c namespaceLister, counter int) []string {
+	shard := counter % namespaceShards
+	namespaces, err := c.ListNamespaces(ctx)
+	if err != nil {
+		log.Printf("WARNING: %v. Scanning all namespaces in this cycle", err)
+		return []string{""}
+	}
+	var shardNamespaces []string
+	for _, ns := range namespaces {
+		if namespaceShard(ns, namespaceShards) == shard {
+			shardNamespaces = append(shardNamespaces, ns)
 		}
-		time.Sleep(time.Duration(pollingInterval-int(healTime)) * time.Second) // sleep for n seconds
-		counter += 1
 	}
+	log.Printf("Scanning namespace shard %d/%d: %d of %d namespaces", shard+1, namespaceShards, len(shardNamespaces), len(namespaces))
+	return shardNamespaces
+}
+
+// namespaceShard returns the shard of a namespace, between 0 and shards-1
+func namespaceShard(ns string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(ns))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// scannedNamespaces returns the namespaces Pods are matched in
+// observed namespaces are scanned in addition to --namespace, unless all namespaces are scanned
+func scannedNamespaces() []string {
+	if namespace == "" {
+		return []string{namespace}
+	}
+	namespaces := []string{namespace}
+	for _, ns := range observeNamespaces {
+		if !contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// contains returns true if elems contains v
+func contains(elems []string, v string) bool {
+	for _, s := range elems {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteQueuedPods checks and deletes queued Pods while deletions are allowed by --deletion-rate
+// Pods that self-healed or disappeared are dropped from the queue without using a deletion
+// returns the number of Pods processed
+func deleteQueuedPods(ctx context.Context, c k8s.K8sClient, q *deletionQueue) int {
+	workCtx, cancel := inFlightContext(ctx)
+	defer cancel()
+	processed := 0
+	for ctx.Err() == nil {
+		pod, ok := q.next()
+		if !ok {
+			break
+		}
+		processed++
+		switch processPod(workCtx, c, pod.Name, pod.Namespace) {
+		case k8s.DecisionDeleted, k8s.DecisionDryRun, k8s.DecisionObserved:
+			q.used()
+		}
+	}
+	return processed
+}
+
+// inFlightContext returns the context Pods are processed with, it is cancelled --drain-timeout after parent
+// a Pod being deleted when pod-restarter shuts down is not interrupted halfway, but deletion verification,
+// throttled retries and notifications do not delay the shutdown for longer than --drain-timeout
+// the drain cycle context expires already, it is used as is
+func inFlightContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	work, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-parent.Done():
+		case <-work.Done():
+			return
+		}
+		timer := clk.NewTimer(drainTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			cancel()
+		case <-work.Done():
+		}
+	}()
+	return work, cancel
+}
+
+// deletePods checks and deletes the list of Pods that match Event Reason, in list order
+// at most deleteConcurrency Pods are checked and deleted in parallel
+// once ctx is cancelled no more Pods are processed, Pods in flight get --drain-timeout to finish (see inFlightContext)
+// returns the number of Pods processed
+func deletePods(ctx context.Context, c k8s.K8sClient, podList []k8s.PodRef) int {
+	workCtx, cancel := inFlightContext(ctx)
+	defer cancel()
+	processed := 0
+
+	sem := make(chan struct{}, deleteConcurrency)
+	var wg sync.WaitGroup
+	for _, pod := range podList {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		// ctx might have been cancelled while waiting for a worker
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		processed++
+		wg.Add(1)
+		go func(pod, ns string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// panics in workers are not recovered by safeRun
+			defer func() {
+				if r := recover(); r != nil {
+					logPanic(r)
+				}
+			}()
+			processPod(workCtx, c, pod, ns)
+		}(pod.Name, pod.Namespace)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Shutting down: processed %d of %d matched Pods, %d Pods were not processed", processed, len(podList), len(podList)-processed)
+	}
+	return processed
 }
@@ -0,0 +1,980 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	k8s "github.com/andreistefanciprian/pod-restarter-go/kubernetes"
+	"github.com/andreistefanciprian/pod-restarter-go/metrics"
+	"github.com/andreistefanciprian/pod-restarter-go/notify"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// fakeClient records deleted Pods and cancels the control loop context after cancelAfter deletions
+type fakeClient struct {
+	mu          sync.Mutex
+	deleted     []string
+	cancelAfter int
+	cancel      context.CancelFunc
+	ctxErrors   []error
+	deleteErrs  []error // returned by the first deletions, in order
+	checkErr    error   // returned by PodChecks
+	checks      int
+	owners      map[string]string // top-level owners of deleted Pods, by namespace/name
+	categories  map[string]string // categories of matched Pods, by namespace/name
+}
+
+func (f *fakeClient) DeletePod(ctx context.Context, pod, namespace string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, pod)
+	if f.cancel != nil && len(f.deleted) == f.cancelAfter {
+		f.cancel()
+	}
+	f.ctxErrors = append(f.ctxErrors, ctx.Err())
+	if len(f.deleteErrs) > 0 {
+		err := f.deleteErrs[0]
+		f.deleteErrs = f.deleteErrs[1:]
+		return err
+	}
+	return nil
+}
+
+func (f *fakeClient) GenerateToBeDeletedPodList(ctx context.Context, namespace, eventReason, errorMessage string, counter, pollingInterval int) (map[string]string, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) DeletedPodOwner(pod, namespace string) string {
+	return f.owners[namespace+"/"+pod]
+}
+
+func (f *fakeClient) MatchedPodCategory(pod, namespace string) string {
+	return f.categories[namespace+"/"+pod]
+}
+
+func (f *fakeClient) PodChecks(ctx context.Context, podName, podNamespace string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checks++
+	return f.checkErr
+}
+
+func TestDeletePodsContextCancelled(t *testing.T) {
+	var podList []k8s.PodRef
+	for i := 0; i < 5; i++ {
+		podList = append(podList, k8s.PodRef{Name: fmt.Sprintf("pod_%d", i), Namespace: "default"})
+	}
+
+	defer func(concurrency int) { deleteConcurrency = concurrency }(deleteConcurrency)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deleteConcurrency = 1
+
+	client := &fakeClient{cancelAfter: 2, cancel: cancel}
+	processed := deletePods(ctx, client, podList)
+
+	assert.Equal(t, 2, processed)
+	assert.Equal(t, []string{"pod_0", "pod_1"}, client.deleted)
+	// the in-flight delete is not aborted by the cancellation
+	assert.Equal(t, []error{nil, nil}, client.ctxErrors)
+}
+
+// blockingClient blocks deletions until release is closed and panics when deleting panicPod
+type blockingClient struct {
+	*fakeClient
+	release     chan struct{}
+	panicPod    string
+	inFlight    int
+	maxInFlight int
+	calls       map[string]int
+}
+
+func (b *blockingClient) DeletePod(ctx context.Context, pod, namespace string) error {
+	b.mu.Lock()
+	b.calls[pod]++
+	b.inFlight++
+	if b.inFlight > b.maxInFlight {
+		b.maxInFlight = b.inFlight
+	}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	<-b.release
+	if pod == b.panicPod {
+		panic("deleting " + pod)
+	}
+	return b.fakeClient.DeletePod(ctx, pod, namespace)
+}
+
+func (b *blockingClient) running() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+func TestDeletePodsWorkerPool(t *testing.T) {
+	var podList []k8s.PodRef
+	for i := 0; i < 10; i++ {
+		podList = append(podList, k8s.PodRef{Name: fmt.Sprintf("pod_%d", i), Namespace: "default"})
+	}
+
+	defer func(concurrency int) { deleteConcurrency = concurrency }(deleteConcurrency)
+	deleteConcurrency = 3
+	panics := testutil.ToFloat64(metrics.RecoveredPanics)
+
+	client := &blockingClient{
+		fakeClient: &fakeClient{},
+		release:    make(chan struct{}),
+		panicPod:   "pod_4",
+		calls:      map[string]int{},
+	}
+	done := make(chan int)
+	go func() { done <- deletePods(context.Background(), client, podList) }()
+
+	// the pool fills up and no more deletions are started while they are all blocked
+	assert.Eventually(t, func() bool { return client.running() == deleteConcurrency }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, deleteConcurrency, client.running())
+
+	close(client.release)
+	select {
+	case processed := <-done:
+		assert.Equal(t, len(podList), processed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("deletePods did not return")
+	}
+
+	assert.LessOrEqual(t, client.maxInFlight, deleteConcurrency)
+	for _, pod := range podList {
+		assert.Equal(t, 1, client.calls[pod.Name], pod.Name)
+	}
+	// the panicking worker is recovered and the other Pods are still deleted
+	assert.Len(t, client.deleted, len(podList)-1)
+	assert.NotContains(t, client.deleted, "pod_4")
+	assert.Equal(t, panics+1, testutil.ToFloat64(metrics.RecoveredPanics))
+}
+
+func TestInFlightContext(t *testing.T) {
+	defer func(c clock.WithTicker, timeout time.Duration) {
+		clk, drainTimeout = c, timeout
+	}(clk, drainTimeout)
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+	clk = fakeClock
+	drainTimeout = 20 * time.Second
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	work, cancel := inFlightContext(parent)
+	defer cancel()
+
+	// Pods in flight keep going after the shutdown signal
+	cancelParent()
+	assert.Eventually(t, fakeClock.HasWaiters, time.Second, time.Millisecond)
+	assert.NoError(t, work.Err())
+
+	// and are stopped once the drain timeout is over
+	fakeClock.Step(drainTimeout)
+	assert.Eventually(t, func() bool { return work.Err() != nil }, time.Second, time.Millisecond)
+
+	// the drain cycle context already has a deadline
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), time.Hour)
+	work, cancel = inFlightContext(drainCtx)
+	defer cancel()
+	cancelDrain()
+	assert.Error(t, work.Err())
+}
+
+func TestSafeRunPanic(t *testing.T) {
+	defer func(exit bool) { exitOnPanic = exit }(exitOnPanic)
+	panics := testutil.ToFloat64(metrics.RecoveredPanics)
+	cycle := func(ctx context.Context, counter int) error {
+		panic(fmt.Sprintf("cycle %d", counter))
+	}
+
+	// the panic is recovered and the control loop keeps going
+	exitOnPanic = false
+	assert.NoError(t, safeRun(context.Background(), cycle, 1))
+	assert.Equal(t, panics+1, testutil.ToFloat64(metrics.RecoveredPanics))
+
+	// with --exit-on-panic the panic is returned
+	exitOnPanic = true
+	assert.EqualError(t, safeRun(context.Background(), cycle, 2), "Exiting after panic: cycle 2")
+	assert.Equal(t, panics+2, testutil.ToFloat64(metrics.RecoveredPanics))
+}
+
+// hangingClient blocks deletions until ctx is done
+type hangingClient struct {
+	*fakeClient
+}
+
+func (h *hangingClient) DeletePod(ctx context.Context, pod, namespace string) error {
+	<-ctx.Done()
+	return h.fakeClient.DeletePod(ctx, pod, namespace)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	defer func(timeout time.Duration, concurrency int) {
+		drainTimeout, deleteConcurrency = timeout, concurrency
+	}(drainTimeout, deleteConcurrency)
+	drainTimeout = 100 * time.Millisecond
+	deleteConcurrency = 1
+
+	client := &hangingClient{fakeClient: &fakeClient{}}
+	podList := []k8s.PodRef{{Name: "pod_0", Namespace: "default"}}
+	var processed int
+	cycle := func(ctx context.Context, counter int) error {
+		processed = deletePods(ctx, client, podList)
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		drain(cycle, 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not return")
+	}
+
+	// the hanging delete is stopped by the drain timeout
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, []error{context.DeadlineExceeded}, client.ctxErrors)
+}
+
+func TestObserveNamespaces(t *testing.T) {
+	defer func(ns string, observed stringSlice, only map[string]bool) {
+		namespace, observeNamespaces, observeOnly = ns, observed, only
+	}(namespace, observeNamespaces, observeOnly)
+
+	namespace = "active"
+	observeNamespaces = stringSlice{"observed", "active"}
+	observeOnly = map[string]bool{"observed": true}
+	assert.Equal(t, []string{"active", "observed"}, scannedNamespaces())
+
+	client := &fakeClient{}
+	processPod(context.TODO(), client, "pod_1", "observed")
+	processPod(context.TODO(), client, "pod_2", "active")
+	assert.Equal(t, []string{"pod_2"}, client.deleted)
+
+	// all namespaces are scanned once, observed namespaces included
+	namespace = ""
+	assert.Equal(t, []string{""}, scannedNamespaces())
+}
+
+// fakeCriteriaGetter returns criteria or err
+type fakeCriteriaGetter struct {
+	criteria *k8s.Criteria
+	err      error
+}
+
+func (f *fakeCriteriaGetter) GetCriteria(ctx context.Context, namespace, name string) (*k8s.Criteria, error) {
+	return f.criteria, f.err
+}
+
+func TestLoadCriteria(t *testing.T) {
+	defer func(cm, reason, message string) {
+		criteriaConfigMap, eventReason, errorMessage = cm, reason, message
+	}(criteriaConfigMap, eventReason, errorMessage)
+
+	criteriaConfigMap = "pod-restarter/criteria"
+	eventReason = "FailedCreatePodSandBox"
+	errorMessage = "container veth name provided (eth0) already exists"
+
+	loadCriteria(context.Background(), &fakeCriteriaGetter{criteria: &k8s.Criteria{Reason: "BackOff", ErrorMessage: "Back-off pulling image"}})
+	assert.Equal(t, "BackOff", eventReason)
+	assert.Equal(t, "Back-off pulling image", errorMessage)
+
+	// last good criteria are kept when the ConfigMap is not valid
+	loadCriteria(context.Background(), &fakeCriteriaGetter{err: errors.New("key reason is missing or empty")})
+	assert.Equal(t, "BackOff", eventReason)
+	assert.Equal(t, "Back-off pulling image", errorMessage)
+}
+
+// fakeNodeHealthChecker returns fraction or err
+type fakeNodeHealthChecker struct {
+	fraction float64
+	err      error
+}
+
+func (f *fakeNodeHealthChecker) ReadyNodeFraction(ctx context.Context) (float64, error) {
+	return f.fraction, f.err
+}
+
+func TestClusterHealthy(t *testing.T) {
+	defer func(min float64) { minReadyNodes = min }(minReadyNodes)
+	minReadyNodes = 0.8
+
+	tests := map[string]struct {
+		checker  *fakeNodeHealthChecker
+		expected bool
+	}{
+		"Verify cluster is healthy when enough nodes are Ready": {
+			checker:  &fakeNodeHealthChecker{fraction: 0.9},
+			expected: true,
+		},
+		"Verify cluster is not healthy when too few nodes are Ready": {
+			checker:  &fakeNodeHealthChecker{fraction: 0.5},
+			expected: false,
+		},
+		"Verify cluster is not healthy when nodes cannot be listed": {
+			checker:  &fakeNodeHealthChecker{err: errors.New("forbidden")},
+			expected: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, clusterHealthy(context.Background(), tc.checker))
+			assert.Equal(t, !tc.expected, clusterHealth.paused)
+		})
+	}
+}
+
+func TestWidespreadIssue(t *testing.T) {
+	defer func(min int) { minPendingPods = min }(minPendingPods)
+
+	tests := map[string]struct {
+		threshold int
+		matched   int
+		expected  bool
+	}{
+		"Verify deletions are allowed when the threshold is disabled": {
+			threshold: 0,
+			matched:   1,
+			expected:  true,
+		},
+		"Verify deletions are skipped when fewer Pods matched": {
+			threshold: 5,
+			matched:   4,
+			expected:  false,
+		},
+		"Verify deletions are allowed when enough Pods matched": {
+			threshold: 5,
+			matched:   5,
+			expected:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			minPendingPods = tc.threshold
+			assert.Equal(t, tc.expected, widespreadIssue(tc.matched))
+		})
+	}
+}
+
+func TestGetFailureTracker(t *testing.T) {
+	tracker := newGetFailureTracker()
+
+	assert.Equal(t, 1, tracker.record("default", "foo", true))
+	tracker.prune()
+	assert.Equal(t, 2, tracker.record("default", "foo", true))
+	assert.Equal(t, 1, tracker.record("default", "bar", true))
+	tracker.prune()
+
+	// a successful Get resets the count
+	assert.Equal(t, 0, tracker.record("default", "foo", false))
+	assert.Equal(t, 1, tracker.record("default", "foo", true))
+	tracker.prune()
+
+	// bar was not processed in the last cycle, its count was dropped
+	tracker.prune()
+	assert.Equal(t, 1, tracker.record("default", "bar", true))
+}
+
+func TestDeletionQueue(t *testing.T) {
+	now := time.Now()
+	q := newDeletionQueue(1, 30*time.Second)
+	q.now = func() time.Time { return now }
+	q.last = now
+
+	q.push([]k8s.PodRef{{Name: "foo", Namespace: "default"}, {Name: "bar", Namespace: "default"}})
+	// Pods already queued are not queued twice
+	q.push([]k8s.PodRef{{Name: "foo", Namespace: "default"}, {Name: "baz", Namespace: "default"}})
+
+	pod, ok := q.next()
+	assert.True(t, ok)
+	assert.Equal(t, "foo", pod.Name)
+	q.used()
+	_, ok = q.next()
+	assert.False(t, ok, "no deletion is allowed until the interval has passed")
+
+	// a Pod that does not use a deletion (eg: self-healed) lets the next Pod through
+	now = now.Add(30 * time.Second)
+	pod, ok = q.next()
+	assert.True(t, ok)
+	assert.Equal(t, "bar", pod.Name)
+	pod, ok = q.next()
+	assert.True(t, ok)
+	assert.Equal(t, "baz", pod.Name)
+	q.used()
+
+	// unused deletions do not accumulate beyond one period
+	now = now.Add(10 * time.Minute)
+	q.push([]k8s.PodRef{{Name: "foo", Namespace: "default"}, {Name: "bar", Namespace: "default"}})
+	_, ok = q.next()
+	assert.True(t, ok)
+	q.used()
+	_, ok = q.next()
+	assert.False(t, ok)
+}
+
+func TestParseDeletionRate(t *testing.T) {
+	tests := map[string]struct {
+		value          string
+		expectedCount  int
+		expectedPeriod time.Duration
+		wantErr        bool
+	}{
+		"Verify count and duration are parsed": {value: "1/30s", expectedCount: 1, expectedPeriod: 30 * time.Second},
+		"Verify multiple deletions per period": {value: "10/5m", expectedCount: 10, expectedPeriod: 5 * time.Minute},
+		"Verify missing separator is rejected": {value: "30s", wantErr: true},
+		"Verify zero count is rejected":        {value: "0/30s", wantErr: true},
+		"Verify invalid duration is rejected":  {value: "1/soon", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			count, period, err := parseDeletionRate(tc.value)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCount, count)
+			assert.Equal(t, tc.expectedPeriod, period)
+		})
+	}
+}
+
+func TestActiveWindow(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		window   string
+		tz       string
+		now      time.Time
+		expected bool
+		wantErr  bool
+	}{
+		"Verify time within the window is active": {
+			window: "09:00-17:00", tz: "UTC", now: time.Date(2022, 11, 21, 9, 0, 0, 0, time.UTC), expected: true,
+		},
+		"Verify window end is excluded": {
+			window: "09:00-17:00", tz: "UTC", now: time.Date(2022, 11, 21, 17, 0, 0, 0, time.UTC), expected: false,
+		},
+		"Verify window is evaluated in its time zone": {
+			window: "09:00-17:00", tz: "Europe/London", now: time.Date(2022, 7, 21, 16, 30, 0, 0, time.UTC), expected: false,
+		},
+		"Verify overnight window is active after midnight": {
+			window: "22:00-06:00", tz: "Europe/London", now: time.Date(2022, 11, 21, 2, 0, 0, 0, london), expected: true,
+		},
+		"Verify overnight window is inactive during the day": {
+			window: "22:00-06:00", tz: "UTC", now: time.Date(2022, 11, 21, 12, 0, 0, 0, time.UTC), expected: false,
+		},
+		"Verify missing separator is rejected": {window: "09:00", tz: "UTC", wantErr: true},
+		"Verify invalid time is rejected":      {window: "9am-5pm", tz: "UTC", wantErr: true},
+		"Verify empty window is rejected":      {window: "09:00-09:00", tz: "UTC", wantErr: true},
+		"Verify invalid time zone is rejected": {window: "09:00-17:00", tz: "Mars/Olympus", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			window, err := parseActiveWindow(tc.window, tc.tz)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, window.contains(tc.now))
+		})
+	}
+}
+
+func TestDeletionsAllowedOutsideActiveWindow(t *testing.T) {
+	defer func(window *activeWindow, closed bool) {
+		deletionWindow, windowClosed = window, closed
+		clk = clock.RealClock{}
+		windowState = activeWindowState{}
+	}(deletionWindow, windowClosed)
+	var err error
+	deletionWindow, err = parseActiveWindow("09:00-17:00", "UTC")
+	require.NoError(t, err)
+	clk = clocktesting.NewFakeClock(time.Date(2022, 11, 21, 20, 0, 0, 0, time.UTC))
+
+	// matched Pods are checked but not deleted outside the window
+	windowClosed = !deletionsAllowed()
+	require.True(t, windowClosed)
+	client := &fakeClient{}
+	assert.Equal(t, k8s.DecisionSkippedActiveWindow, processPod(context.TODO(), client, "foo", "default"))
+	assert.Equal(t, 1, client.checks)
+	assert.Empty(t, client.deleted)
+
+	// the window state is shown on /status
+	recorder := httptest.NewRecorder()
+	statusHandler(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status struct {
+		ActiveWindow struct {
+			Window string `json:"window"`
+			Active bool   `json:"active"`
+		} `json:"activeWindow"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	assert.Equal(t, "09:00-17:00 UTC", status.ActiveWindow.Window)
+	assert.False(t, status.ActiveWindow.Active)
+}
+
+func TestCycleTiming(t *testing.T) {
+	tests := map[string]struct {
+		pollingInterval int
+		healTime        time.Duration
+		expectedSleep   time.Duration
+		wantErr         bool
+	}{
+		"Verify polling interval greater than heal time sleeps the difference": {
+			pollingInterval: 30, healTime: 5, expectedSleep: 25 * time.Second,
+		},
+		"Verify polling interval equal to heal time is rejected": {
+			pollingInterval: 5, healTime: 5, expectedSleep: 0, wantErr: true,
+		},
+		"Verify polling interval lower than heal time is rejected and never sleeps a negative duration": {
+			pollingInterval: 2, healTime: 5, expectedSleep: 0, wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateCycleTiming(tc.pollingInterval, tc.healTime)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedSleep, cycleSleep(tc.pollingInterval, tc.healTime))
+		})
+	}
+}
+
+func TestSleepContextFakeClock(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	clk = fakeClock
+	defer func() { clk = clock.RealClock{} }()
+
+	done := make(chan error)
+	go func() { done <- sleepContext(context.Background(), 30*time.Second) }()
+	for !fakeClock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+
+	fakeClock.Step(29 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("sleepContext returned before the sleep duration passed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	fakeClock.Step(time.Second)
+	assert.NoError(t, <-done)
+}
+
+func TestDebugStateHandler(t *testing.T) {
+	defer func(tracker *getFailureTracker, b *k8s.CircuitBreaker, q *deletionQueue) {
+		getFailures, circuitBreaker, queue = tracker, b, q
+	}(getFailures, circuitBreaker, queue)
+
+	getFailures = newGetFailureTracker()
+	getFailures.record("default", "foo", true)
+	circuitBreaker = k8s.NewCircuitBreaker(5, time.Minute, time.Minute, clk)
+	queue = newDeletionQueue(1, 30*time.Second)
+	queue.push([]k8s.PodRef{{Name: "bar", Namespace: "default"}})
+
+	recorder := httptest.NewRecorder()
+	debugStateHandler(recorder, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var state struct {
+		CircuitBreaker *k8s.CircuitBreakerState `json:"circuitBreaker"`
+		DeletionBudget *k8s.DeletionBudgetState `json:"deletionBudget"`
+		DeletionQueue  *deletionQueueState      `json:"deletionQueue"`
+		GetFailures    map[string]int           `json:"getFailures"`
+	}
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&state))
+	assert.Equal(t, map[string]int{"default/foo": 1}, state.GetFailures)
+	require.NotNil(t, state.CircuitBreaker)
+	assert.False(t, state.CircuitBreaker.Open)
+	require.NotNil(t, state.DeletionQueue)
+	assert.Equal(t, []string{"default/bar"}, state.DeletionQueue.Pods)
+	// disabled features are not shown
+	assert.Nil(t, state.DeletionBudget)
+}
+
+func TestHealedTracker(t *testing.T) {
+	now := time.Now()
+	healed := newHealedTracker(time.Minute)
+	healed.now = func() time.Time { return now }
+
+	healed.record("default", "foo")
+	healed.record("default", "bar")
+
+	// Pods matched again in the next cycle are checked as usual, not rechecked
+	matched := []map[string]string{{"bar": "default"}}
+	assert.Equal(t, map[string]string{"foo": "default"}, healed.take(matched))
+
+	// a rechecked Pod that self-heals again is not rechecked once more
+	healed.record("default", "foo")
+	healed.record("default", "bar")
+	assert.Equal(t, map[string]string{"bar": "default"}, healed.take(nil))
+
+	// remembered Pods expire
+	healed.record("default", "baz")
+	now = now.Add(2 * time.Minute)
+	assert.Empty(t, healed.take(nil))
+}
+
+func TestProcessPodConflict(t *testing.T) {
+	defer func(retries int) {
+		conflictRetries = retries
+	}(conflictRetries)
+	conflict := apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "foo", errors.New("the object has been modified"))
+
+	tests := map[string]struct {
+		retries          int
+		deleteErrs       []error
+		expectedDecision k8s.Decision
+		expectedChecks   int
+	}{
+		"Verify Pod is re-evaluated and deleted after a conflict": {
+			retries:          1,
+			deleteErrs:       []error{conflict},
+			expectedDecision: k8s.DecisionDeleted,
+			expectedChecks:   2,
+		},
+		"Verify retries are bounded": {
+			retries:          1,
+			deleteErrs:       []error{conflict, conflict},
+			expectedDecision: k8s.DecisionError,
+			expectedChecks:   2,
+		},
+		"Verify conflicts are not retried when disabled": {
+			retries:          0,
+			deleteErrs:       []error{conflict},
+			expectedDecision: k8s.DecisionError,
+			expectedChecks:   1,
+		},
+		"Verify other errors are not retried": {
+			retries:          1,
+			deleteErrs:       []error{errors.New("etcdserver: request timed out")},
+			expectedDecision: k8s.DecisionError,
+			expectedChecks:   1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conflictRetries = tc.retries
+			client := &fakeClient{deleteErrs: tc.deleteErrs}
+			assert.Equal(t, tc.expectedDecision, processPod(context.TODO(), client, "foo", "default"))
+			assert.Equal(t, tc.expectedChecks, client.checks)
+		})
+	}
+}
+
+func TestProcessPodDeletedBetweenPasses(t *testing.T) {
+	defer func() { summary = nil }()
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "foo")
+
+	tests := map[string]struct {
+		checkErr   error
+		deleteErrs []error
+	}{
+		"Verify Pod deleted by someone else before it was checked is not an error": {
+			checkErr: fmt.Errorf("Pod default/foo does not exist anymore: %w", notFound),
+		},
+		"Verify Pod deleted by someone else after it was checked is not an error": {
+			deleteErrs: []error{notFound},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			summary = newCycleSummary(1, time.Now())
+			client := &fakeClient{checkErr: tc.checkErr, deleteErrs: tc.deleteErrs}
+			assert.Equal(t, k8s.DecisionSkippedNotFound, processPod(context.TODO(), client, "foo", "default"))
+			assert.Equal(t, map[k8s.Decision]int{k8s.DecisionSkippedNotFound: 1}, summary.Decisions)
+			assert.Empty(t, summary.Deleted)
+		})
+	}
+}
+
+func TestCycleSummary(t *testing.T) {
+	defer func() { summary = nil }()
+	startedAt := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+	summary = newCycleSummary(3, startedAt)
+	summary.Matched = 3
+
+	// decisions of processed Pods are counted, deleted Pods are listed and counted by owner
+	c := &fakeClient{
+		deleteErrs: []error{errors.New("etcdserver: request timed out")},
+		owners:     map[string]string{"default/foo": "Deployment/default/web", "default/bar": "Deployment/default/web"},
+	}
+	processPod(context.Background(), c, "foo", "default")
+	processPod(context.Background(), c, "bar", "default")
+	processPod(context.Background(), c, "baz", "test")
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	require.NoError(t, summary.write(path, false, startedAt.Add(1500*time.Millisecond)))
+	require.NoError(t, summary.write(path, false, startedAt.Add(2*time.Second)))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &written), "the file should be overwritten with a single summary")
+	assert.Equal(t, map[string]interface{}{
+		"cycle":     float64(3),
+		"startedAt": "2022-11-20T10:00:00Z",
+		"duration":  "2s",
+		"matched":   float64(3),
+		"decisions": map[string]interface{}{"DELETED": float64(2), "ERROR": float64(1)},
+		"deleted":   []interface{}{"default/bar", "test/baz"},
+		"owners":    map[string]interface{}{"Deployment/default/web": float64(1)},
+	}, written)
+
+	// with append, every summary is a line
+	path = filepath.Join(t.TempDir(), "summaries.jsonl")
+	require.NoError(t, summary.write(path, true, startedAt.Add(time.Second)))
+	require.NoError(t, summary.write(path, true, startedAt.Add(time.Second)))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &written))
+		assert.Equal(t, "1s", written["duration"])
+	}
+}
+
+func TestLogDecisionCategory(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+	defer func(format string) { logFormat = format }(logFormat)
+
+	logFormat = "text"
+	logDecision("foo", "default", k8s.DecisionDeleted, "", "cni")
+	logDecision("bar", "default", k8s.DecisionDeleted, "", "")
+	logFormat = "logfmt"
+	logDecision("foo", "default", k8s.DecisionDeleted, "", "volume mount")
+	assert.Equal(t, `DECISION pod=default/foo decision=DELETED detail="" category="cni"
+DECISION pod=default/bar decision=DELETED detail=""
+DECISION pod=foo namespace=default decision=DELETED detail="" category="volume mount"
+`, out.String())
+}
+
+func TestLogfmtValue(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected string
+	}{
+		"Verify plain values are not quoted":             {value: "default/foo", expected: "default/foo"},
+		"Verify empty values are quoted":                 {value: "", expected: `""`},
+		"Verify values with spaces are quoted":           {value: "Pod is in a Healthy State", expected: `"Pod is in a Healthy State"`},
+		"Verify quotes and backslashes are escaped":      {value: `say "hi" \o/`, expected: `"say \"hi\" \\o/"`},
+		"Verify values with equal signs are quoted":      {value: "a=b", expected: `"a=b"`},
+		"Verify newlines and tabs are escaped":           {value: "line1\nline2\tend", expected: `"line1\nline2\tend"`},
+		"Verify unicode values are kept without quoting": {value: "ünïcode", expected: "ünïcode"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, logfmtValue(tc.value))
+		})
+	}
+}
+
+func TestLogfmtWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &logfmtWriter{
+		out:      &out,
+		instance: "cluster a",
+		now:      func() time.Time { return time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC) },
+	}
+
+	_, err := w.Write([]byte("DELETED Pod default/foo\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("WARNING: Could not get Pod \"foo\":\nforbidden\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`DECISION pod=foo namespace=default decision=SKIPPED_NO_OWNER detail="Pod does not have owner/controller"` + "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, `time=2022-11-20T10:00:00Z level=info instance="cluster a" msg="DELETED Pod default/foo"
+time=2022-11-20T10:00:00Z level=warn instance="cluster a" msg="Could not get Pod \"foo\":\nforbidden"
+time=2022-11-20T10:00:00Z level=info instance="cluster a" msg=DECISION pod=foo namespace=default decision=SKIPPED_NO_OWNER detail="Pod does not have owner/controller"
+`, out.String())
+}
+
+type fakeNamespaceLister struct {
+	namespaces []string
+	err        error
+}
+
+func (f *fakeNamespaceLister) ListNamespaces(ctx context.Context) ([]string, error) {
+	return f.namespaces, f.err
+}
+
+func TestShardNamespaces(t *testing.T) {
+	defer func(shards int) { namespaceShards = shards }(namespaceShards)
+	namespaceShards = 4
+
+	var namespaces []string
+	for i := 0; i < 40; i++ {
+		namespaces = append(namespaces, fmt.Sprintf("team-%d", i))
+	}
+	lister := &fakeNamespaceLister{namespaces: namespaces}
+
+	// every namespace is scanned exactly once over namespaceShards cycles, then shards repeat
+	var scanned []string
+	for counter := 0; counter < namespaceShards; counter++ {
+		shard := shardNamespaces(context.Background(), lister, counter)
+		assert.Equal(t, shard, shardNamespaces(context.Background(), lister, counter+namespaceShards))
+		scanned = append(scanned, shard...)
+	}
+	assert.ElementsMatch(t, namespaces, scanned)
+
+	// adding a namespace does not move the others
+	before := shardNamespaces(context.Background(), lister, 1)
+	lister.namespaces = append(lister.namespaces, "new-team")
+	assert.Subset(t, shardNamespaces(context.Background(), lister, 1), before)
+
+	// all namespaces are scanned if namespaces cannot be listed
+	lister.err = errors.New("forbidden")
+	assert.Equal(t, []string{""}, shardNamespaces(context.Background(), lister, 0))
+}
+
+func TestReloadErrorMessages(t *testing.T) {
+	defer func(file string, messages *k8s.ErrorMessages) {
+		errorMessageFile, errorMessages = file, messages
+	}(errorMessageFile, errorMessages)
+	errorMessageFile = filepath.Join(t.TempDir(), "messages")
+	errorMessages = k8s.NewErrorMessages([]string{"no IP addresses available"})
+
+	// the loaded messages are kept if the file cannot be read
+	reloadErrorMessages()
+	assert.Equal(t, []string{"no IP addresses available"}, errorMessages.List())
+
+	require.NoError(t, os.WriteFile(errorMessageFile, []byte("# CNI\nno IP addresses available\nfailed to set up sandbox\n"), 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan struct{})
+	reloadOnSIGHUP(ctx, func() {
+		reloadErrorMessages()
+		close(reloaded)
+	})
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("error messages were not reloaded on SIGHUP")
+	}
+	assert.Equal(t, []string{"no IP addresses available", "failed to set up sandbox"}, errorMessages.List())
+}
+
+// fakeCycleFinisher counts the end of cycle calls
+type fakeCycleFinisher struct {
+	stuckChecks int
+}
+
+func (f *fakeCycleFinisher) CheckStuckTerminating(ctx context.Context) int {
+	f.stuckChecks++
+	return 0
+}
+
+func (f *fakeCycleFinisher) RenewHeartbeat(ctx context.Context, namespace, name, holder string, duration time.Duration) error {
+	return nil
+}
+
+func TestFinishCycle(t *testing.T) {
+	defer func(b *k8s.CircuitBreaker, am *notify.Alertmanager, failures *getFailureTracker) {
+		circuitBreaker, alerts, getFailures = b, am, failures
+	}(circuitBreaker, alerts, getFailures)
+	posted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted++
+	}))
+	defer server.Close()
+	alerts = notify.NewAlertmanager(server.URL, time.Second, clk)
+	circuitBreaker = k8s.NewCircuitBreaker(1, time.Hour, time.Hour, clk)
+	require.True(t, circuitBreaker.Allow())
+	require.False(t, circuitBreaker.Allow())
+	getFailures = newGetFailureTracker()
+	getFailures.record("default", "foo", true)
+	getFailures.prune()
+
+	// a cycle that paused remediation still posts alerts, reports stuck Pods and forgets Pods that stopped matching
+	c := &fakeCycleFinisher{}
+	finishCycle(context.Background(), c)
+	assert.Equal(t, 1, c.stuckChecks)
+	assert.Equal(t, 1, posted)
+	assert.Empty(t, getFailures.snapshot())
+}
+
+func TestFiringAlerts(t *testing.T) {
+	defer func(b *k8s.CircuitBreaker, budget *k8s.DeletionBudget, owners *k8s.OwnerFailureTracker, instance string) {
+		circuitBreaker, deletionBudget, ownerFailures, instanceName = b, budget, owners, instance
+	}(circuitBreaker, deletionBudget, ownerFailures, instanceName)
+	instanceName = "pod-restarter-0"
+	circuitBreaker = k8s.NewCircuitBreaker(1, time.Hour, time.Hour, clk)
+	deletionBudget = k8s.NewDeletionBudget(1)
+	ownerFailures = nil
+
+	// nothing needs a human
+	assert.Empty(t, firingAlerts())
+
+	require.True(t, circuitBreaker.Allow())
+	require.False(t, circuitBreaker.Allow())
+	require.True(t, deletionBudget.Reserve())
+	var names []string
+	for _, alert := range firingAlerts() {
+		assert.Equal(t, "pod-restarter-0", alert.Labels["instance"])
+		assert.NotEmpty(t, alert.Annotations["summary"])
+		names = append(names, alert.Labels["alertname"])
+	}
+	assert.Equal(t, []string{"PodRestarterCircuitBreakerOpen", "PodRestarterDeletionBudgetExhausted"}, names)
+
+	// every persistent failure is a separate alert
+	circuitBreaker, deletionBudget = nil, nil
+	ownerFailures = k8s.NewOwnerFailureTracker(2, time.Hour, clk)
+	ownerFailures.Record("Deployment/default/web")
+	ownerFailures.Record("Deployment/default/web")
+	allowed, _ := ownerFailures.Allow("Deployment/default/web")
+	require.False(t, allowed)
+	firing := firingAlerts()
+	require.Len(t, firing, 1)
+	assert.Equal(t, "PodRestarterPersistentFailure", firing[0].Labels["alertname"])
+	assert.Equal(t, "Deployment/default/web", firing[0].Labels["owner"])
+}
@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// namespaceLabel enables the namespace label on counters
+// disable it in clusters with thousands of namespaces to limit cardinality
+var namespaceLabel = true
+
+// maxCategories limits the number of distinct category label values, later categories are counted as otherCategory
+// categories are extracted from free-text Event messages, unbounded values would blow up cardinality
+var maxCategories = 20
+
+// otherCategory is the category label value of categories beyond maxCategories
+const otherCategory = "other"
+
+// seenCategories are the category label values in use
+var (
+	seenCategoriesMu sync.Mutex
+	seenCategories   = make(map[string]bool)
+)
+
+var (
+	// MatchedPods counts Pods that matched Event Reason and Message
+	MatchedPods = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_matched_pods_total",
+			Help: "Number of Pods that matched Event Reason and Message.",
+		},
+		[]string{"namespace"},
+	)
+
+	// DeletedPods counts Pods deleted by pod-restarter
+	DeletedPods = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_deleted_pods_total",
+			Help: "Number of Pods deleted.",
+		},
+		[]string{"namespace"},
+	)
+
+	// DeletedPodsByCategory counts Pods deleted by pod-restarter by the category extracted from their matched Events
+	DeletedPodsByCategory = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_deleted_pods_by_category_total",
+			Help: "Number of Pods deleted by the category extracted from their matched Events.",
+		},
+		[]string{"namespace", "category"},
+	)
+
+	// ObservedPods counts Pods that would have been deleted in observe-only namespaces
+	ObservedPods = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_observed_pods_total",
+			Help: "Number of Pods that would have been deleted in observe-only namespaces.",
+		},
+		[]string{"namespace"},
+	)
+
+	// StuckPods counts matched Pods that could not be fetched for too many consecutive cycles
+	StuckPods = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_stuck_pods_total",
+			Help: "Number of times a matched Pod could not be fetched for too many consecutive cycles.",
+		},
+		[]string{"namespace"},
+	)
+
+	// StuckTerminatingPods counts deleted Pods that were still terminating after the grace window
+	StuckTerminatingPods = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_stuck_terminating_pods_total",
+			Help: "Number of deleted Pods that were still terminating after the grace window.",
+		},
+		[]string{"namespace"},
+	)
+
+	// PersistentFailures counts owners flagged as persistent failures, their Pods are no longer deleted
+	PersistentFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_persistent_failures_total",
+			Help: "Number of times an owner was flagged as a persistent failure and its Pods were no longer deleted.",
+		},
+		[]string{"namespace"},
+	)
+
+	// APICalls counts kubernetes API calls by verb and resource
+	APICalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_api_calls_total",
+			Help: "Number of kubernetes API calls by verb and resource.",
+		},
+		[]string{"verb", "resource"},
+	)
+
+	// DecisionCacheLookups counts decision cache lookups by result (hit or miss)
+	DecisionCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_decision_cache_lookups_total",
+			Help: "Number of decision cache lookups by result (hit or miss).",
+		},
+		[]string{"result"},
+	)
+
+	// RecoveredPanics counts panics recovered in the control loop
+	RecoveredPanics = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_recovered_panics_total",
+			Help: "Number of panics recovered in the control loop.",
+		},
+	)
+
+	// CircuitBreakerTrips counts how many times the circuit breaker paused deletions
+	CircuitBreakerTrips = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "pod_restarter_circuit_breaker_trips_total",
+			Help: "Number of times the circuit breaker paused deletions.",
+		},
+	)
+
+	// DeletionBudgetRemaining is the number of deletions left in the lifetime deletion budget
+	DeletionBudgetRemaining = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "pod_restarter_deletion_budget_remaining",
+			Help: "Number of deletions left in the lifetime deletion budget.",
+		},
+	)
+
+	// CycleMatchedPods is the number of Pods matched in the last cycle
+	CycleMatchedPods = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "pod_restarter_cycle_matched_pods",
+			Help: "Number of Pods matched in the last cycle.",
+		},
+	)
+
+	// MatchedPodAges are quantiles of the ages of Pods matched in the last cycle, by namespace
+	MatchedPodAges = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pod_restarter_matched_pod_age_seconds",
+			Help: "Quantiles of the ages of Pods matched in the last cycle, since Pod creation.",
+		},
+		[]string{"namespace", "quantile"},
+	)
+
+	// DeletionQueueDepth is the number of matched Pods waiting to be deleted at the deletion rate
+	DeletionQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "pod_restarter_deletion_queue_depth",
+			Help: "Number of matched Pods waiting to be deleted at the deletion rate.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(MatchedPods, DeletedPods, DeletedPodsByCategory, ObservedPods, StuckPods, StuckTerminatingPods, PersistentFailures, APICalls, DecisionCacheLookups, RecoveredPanics, CircuitBreakerTrips, DeletionBudgetRemaining, CycleMatchedPods, MatchedPodAges, DeletionQueueDepth)
+}
+
+// SetNamespaceLabel enables or disables the namespace label on counters
+func SetNamespaceLabel(enabled bool) {
+	namespaceLabel = enabled
+}
+
+// SetMaxCategories sets the number of distinct category label values, later categories are counted as "other"
+func SetMaxCategories(max int) {
+	maxCategories = max
+}
+
+// categoryLabelValue returns the category label value, otherCategory once maxCategories categories are in use
+func categoryLabelValue(category string) string {
+	seenCategoriesMu.Lock()
+	defer seenCategoriesMu.Unlock()
+
+	if !seenCategories[category] {
+		if len(seenCategories) >= maxCategories {
+			return otherCategory
+		}
+		seenCategories[category] = true
+	}
+	return category
+}
+
+// namespaceLabelValue returns the namespace label value, empty if the namespace label is disabled
+func namespaceLabelValue(namespace string) string {
+	if !namespaceLabel {
+		return ""
+	}
+	return namespace
+}
+
+// PodMatched increments the matched Pods counter
+func PodMatched(namespace string) {
+	MatchedPods.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// PodDeletedWithCategory increments the deleted Pods by category counter
+func PodDeletedWithCategory(namespace, category string) {
+	DeletedPodsByCategory.WithLabelValues(namespaceLabelValue(namespace), categoryLabelValue(category)).Inc()
+}
+
+// PodObserved increments the observed Pods counter
+func PodObserved(namespace string) {
+	ObservedPods.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// PodGetFailed increments the stuck Pods counter
+func PodGetFailed(namespace string) {
+	StuckPods.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// PodDeleted increments the deleted Pods counter
+func PodDeleted(namespace string) {
+	DeletedPods.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// PodStuckTerminating increments the stuck terminating Pods counter
+func PodStuckTerminating(namespace string) {
+	StuckTerminatingPods.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// OwnerPersistentFailure increments the persistent failures counter
+func OwnerPersistentFailure(namespace string) {
+	PersistentFailures.WithLabelValues(namespaceLabelValue(namespace)).Inc()
+}
+
+// APICall increments the API calls counter
+func APICall(verb, resource string) {
+	APICalls.WithLabelValues(verb, resource).Inc()
+}
+
+// DecisionCacheLookup increments the decision cache lookups counter
+func DecisionCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	DecisionCacheLookups.WithLabelValues(result).Inc()
+}
+
+// matchedPodAgeQuantiles are the quantiles of matched Pod ages, 1 is the oldest matched Pod
+var matchedPodAgeQuantiles = []float64{0.5, 0.9, 1}
+
+// SetMatchedPodAges replaces the matched Pod ages quantiles with the quantiles of ages, by namespace
+// namespaces without matched Pods are dropped, all ages are aggregated if the namespace label is disabled
+func SetMatchedPodAges(ages map[string][]time.Duration) {
+	byLabel := make(map[string][]float64)
+	for namespace, podAges := range ages {
+		label := namespaceLabelValue(namespace)
+		for _, age := range podAges {
+			byLabel[label] = append(byLabel[label], age.Seconds())
+		}
+	}
+
+	MatchedPodAges.Reset()
+	for label, seconds := range byLabel {
+		sort.Float64s(seconds)
+		for _, q := range matchedPodAgeQuantiles {
+			MatchedPodAges.WithLabelValues(label, fmt.Sprint(q)).Set(quantile(seconds, q))
+		}
+	}
+}
+
+// quantile returns the q quantile of sorted values, using the nearest rank
+func quantile(sorted []float64, q float64) float64 {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// Push pushes all registered metrics to a Prometheus Pushgateway, grouped by job
+// the metrics pushed earlier for the same job are replaced
+func Push(url, job string) error {
+	err := push.New(url, job).Gatherer(prometheus.DefaultGatherer).Push()
+	if err != nil {
+		return fmt.Errorf("Could not push metrics to %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceLabel(t *testing.T) {
+	tests := map[string]struct {
+		namespaceLabel bool
+		namespace      string
+		expectedLabel  string
+	}{
+		"Verify namespace label is set when enabled": {
+			namespaceLabel: true,
+			namespace:      "default",
+			expectedLabel:  "default",
+		},
+		"Verify namespace label is empty when disabled": {
+			namespaceLabel: false,
+			namespace:      "test",
+			expectedLabel:  "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			MatchedPods.Reset()
+			DeletedPods.Reset()
+			ObservedPods.Reset()
+			SetNamespaceLabel(tc.namespaceLabel)
+			defer SetNamespaceLabel(true)
+
+			PodMatched(tc.namespace)
+			PodMatched(tc.namespace)
+			PodDeleted(tc.namespace)
+			PodObserved(tc.namespace)
+
+			assert.Equal(t, float64(2), testutil.ToFloat64(MatchedPods.WithLabelValues(tc.expectedLabel)))
+			assert.Equal(t, float64(1), testutil.ToFloat64(DeletedPods.WithLabelValues(tc.expectedLabel)))
+			assert.Equal(t, float64(1), testutil.ToFloat64(ObservedPods.WithLabelValues(tc.expectedLabel)))
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	PodDeleted("default")
+	require.NoError(t, Push(server.URL, "pod-restarter-cron"))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/pod-restarter-cron", path)
+	assert.Contains(t, string(body), "pod_restarter_deleted_pods_total")
+
+	// push failures are returned, callers decide if they are fatal
+	server.Close()
+	assert.Error(t, Push(server.URL, "pod-restarter-cron"))
+}
+
+func TestSetMatchedPodAges(t *testing.T) {
+	ages := map[string][]time.Duration{
+		"default": {3 * time.Minute, time.Minute, 2 * time.Minute, 10 * time.Minute},
+		"test":    {30 * time.Second},
+	}
+
+	SetMatchedPodAges(ages)
+	assert.Equal(t, float64(120), testutil.ToFloat64(MatchedPodAges.WithLabelValues("default", "0.5")))
+	assert.Equal(t, float64(600), testutil.ToFloat64(MatchedPodAges.WithLabelValues("default", "0.9")))
+	assert.Equal(t, float64(600), testutil.ToFloat64(MatchedPodAges.WithLabelValues("default", "1")))
+	assert.Equal(t, float64(30), testutil.ToFloat64(MatchedPodAges.WithLabelValues("test", "0.5")))
+
+	// namespaces without matched Pods in the last cycle are dropped
+	SetMatchedPodAges(map[string][]time.Duration{"test": {30 * time.Second}})
+	assert.Equal(t, 3, testutil.CollectAndCount(MatchedPodAges))
+
+	// ages of all namespaces are aggregated without the namespace label
+	SetNamespaceLabel(false)
+	defer SetNamespaceLabel(true)
+	SetMatchedPodAges(ages)
+	assert.Equal(t, 3, testutil.CollectAndCount(MatchedPodAges))
+	assert.Equal(t, float64(120), testutil.ToFloat64(MatchedPodAges.WithLabelValues("", "0.5")))
+	assert.Equal(t, float64(600), testutil.ToFloat64(MatchedPodAges.WithLabelValues("", "1")))
+}
+
+func TestPodDeletedWithCategory(t *testing.T) {
+	SetMaxCategories(2)
+	defer SetMaxCategories(20)
+
+	PodDeletedWithCategory("default", "cni")
+	PodDeletedWithCategory("default", "volume")
+	PodDeletedWithCategory("default", "cni")
+	assert.Equal(t, float64(2), testutil.ToFloat64(DeletedPodsByCategory.WithLabelValues("default", "cni")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(DeletedPodsByCategory.WithLabelValues("default", "volume")))
+
+	// categories beyond the limit are counted as other
+	PodDeletedWithCategory("default", "image")
+	PodDeletedWithCategory("test", "dns")
+	assert.Equal(t, float64(1), testutil.ToFloat64(DeletedPodsByCategory.WithLabelValues("default", "other")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(DeletedPodsByCategory.WithLabelValues("test", "other")))
+	assert.Equal(t, 4, testutil.CollectAndCount(DeletedPodsByCategory))
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// alertResendInterval is how often alerts still firing are posted again
+// Alertmanager resolves alerts that are not posted again within its resolve_timeout (5m by default)
+const alertResendInterval = time.Minute
+
+// Alert is a condition to alert on, identified by its labels
+type Alert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// key returns the labels of the alert as a sorted string, alerts with the same labels are the same alert
+func (a Alert) key() string {
+	pairs := make([]string, 0, len(a.Labels))
+	for name, value := range a.Labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// postableAlert is an alert as accepted by the Alertmanager v2 API
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+}
+
+// firingAlert is an alert posted to Alertmanager that did not resolve yet
+type firingAlert struct {
+	alert    Alert
+	startsAt time.Time
+	sentAt   time.Time
+}
+
+// Alertmanager posts alerts to the Alertmanager v2 API
+// alerts are posted when they start firing, again at most every alertResendInterval while they are firing
+// and a last time with endsAt when they resolve, so Alertmanager groups and resolves them
+type Alertmanager struct {
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	firing map[string]*firingAlert // by key
+	clock  clock.PassiveClock
+}
+
+// NewAlertmanager returns an Alertmanager that posts alerts to the Alertmanager at url, requests time out after timeout
+// alerts are timestamped with clk
+func NewAlertmanager(url string, timeout time.Duration, clk clock.PassiveClock) *Alertmanager {
+	return &Alertmanager{
+		url:    strings.TrimSuffix(url, "/") + "/api/v2/alerts",
+		client: &http.Client{Timeout: timeout},
+		firing: make(map[string]*firingAlert),
+		clock:  clk,
+	}
+}
+
+// Update sets the alerts that are firing, alerts firing before that are not in alerts are resolved
+// nothing is posted if no alert started, resolved or is due to be posted again
+// returns error if the alerts could not be posted, they are posted again on the next Update
+func (a *Alertmanager) Update(ctx context.Context, alerts []Alert) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	firing := make(map[string]*firingAlert, len(alerts))
+	var posted []postableAlert
+	for _, alert := range alerts {
+		key := alert.key()
+		f, ok := a.firing[key]
+		if !ok {
+			f = &firingAlert{startsAt: now}
+		}
+		f.alert = alert
+		firing[key] = f
+		if !ok || now.Sub(f.sentAt) >= alertResendInterval {
+			posted = append(posted, postable(f, ""))
+		}
+	}
+	for key, f := range a.firing {
+		if _, ok := firing[key]; !ok {
+			posted = append(posted, postable(f, now.UTC().Format(time.RFC3339)))
+		}
+	}
+	if len(posted) == 0 {
+		a.firing = firing
+		return nil
+	}
+
+	err := a.post(ctx, posted)
+	if err != nil {
+		// keep resolved alerts, so their resolution is posted again on the next Update
+		for key, f := range a.firing {
+			if _, ok := firing[key]; !ok {
+				firing[key] = f
+			}
+		}
+		a.firing = firing
+		return err
+	}
+	for _, f := range firing {
+		f.sentAt = now
+	}
+	a.firing = firing
+	return nil
+}
+
+// postable returns the firing alert as posted to Alertmanager, resolved if endsAt is set
+func postable(f *firingAlert, endsAt string) postableAlert {
+	return postableAlert{
+		Labels:      f.alert.Labels,
+		Annotations: f.alert.Annotations,
+		StartsAt:    f.startsAt.UTC().Format(time.RFC3339),
+		EndsAt:      endsAt,
+	}
+}
+
+// post posts alerts to Alertmanager, returns error if Alertmanager does not respond with a 2xx status code
+func (a *Alertmanager) post(ctx context.Context, alerts []postableAlert) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Could not post alerts to Alertmanager: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Could not post alerts to Alertmanager: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
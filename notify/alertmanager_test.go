@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestAlertmanager(t *testing.T) {
+	var posts [][]postableAlert
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/alerts", r.URL.Path)
+		var alerts []postableAlert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alerts))
+		posts = append(posts, alerts)
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	fakeClock := clocktesting.NewFakeClock(time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC))
+	am := NewAlertmanager(server.URL+"/", time.Second, fakeClock)
+	ctx := context.TODO()
+	breaker := Alert{Labels: map[string]string{"alertname": "PodRestarterCircuitBreakerOpen"}, Annotations: map[string]string{"summary": "deletions are paused"}}
+	budget := Alert{Labels: map[string]string{"alertname": "PodRestarterDeletionBudgetExhausted"}}
+
+	// alerts are posted when they start firing
+	require.NoError(t, am.Update(ctx, []Alert{breaker}))
+	require.Len(t, posts, 1)
+	assert.Equal(t, []postableAlert{{Labels: breaker.Labels, Annotations: breaker.Annotations, StartsAt: "2022-11-20T10:00:00Z"}}, posts[0])
+
+	// alerts still firing are not posted again before the resend interval
+	fakeClock.Step(30 * time.Second)
+	require.NoError(t, am.Update(ctx, []Alert{breaker}))
+	assert.Len(t, posts, 1)
+
+	// only the alerts that are new or due are posted, with their original start
+	fakeClock.Step(30 * time.Second)
+	require.NoError(t, am.Update(ctx, []Alert{breaker, budget}))
+	require.Len(t, posts, 2)
+	assert.ElementsMatch(t, []postableAlert{
+		{Labels: breaker.Labels, Annotations: breaker.Annotations, StartsAt: "2022-11-20T10:00:00Z"},
+		{Labels: budget.Labels, StartsAt: "2022-11-20T10:01:00Z"},
+	}, posts[1])
+
+	// resolved alerts are posted with endsAt, those that could not be posted are posted on the next update
+	fakeClock.Step(10 * time.Second)
+	statusCode = http.StatusServiceUnavailable
+	assert.Error(t, am.Update(ctx, []Alert{budget}))
+	statusCode = http.StatusOK
+	require.NoError(t, am.Update(ctx, []Alert{budget}))
+	require.Len(t, posts, 4)
+	assert.Equal(t, []postableAlert{
+		{Labels: breaker.Labels, Annotations: breaker.Annotations, StartsAt: "2022-11-20T10:00:00Z", EndsAt: "2022-11-20T10:01:10Z"},
+	}, posts[3])
+
+	// resolved alerts are posted once
+	require.NoError(t, am.Update(ctx, []Alert{budget}))
+	assert.Len(t, posts, 4)
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Async passes Events to a Notifier in the background, so a slow notifier (eg: a hanging hook) does not hold up deletions
+// Events are queued up to a limit, Events that do not fit are dropped
+type Async struct {
+	notifier Notifier
+	queue    chan Event
+	done     chan struct{}
+
+	// cancels the notification in progress when Close gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsync returns an Async that queues up to size Events for notifier, errors of notifier are logged
+func NewAsync(notifier Notifier, size int) *Async {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Async{
+		notifier: notifier,
+		queue:    make(chan Event, size),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go a.run()
+	return a
+}
+
+// run notifies the queued Events one at a time, until the queue is closed
+func (a *Async) run() {
+	defer close(a.done)
+	for event := range a.queue {
+		if a.ctx.Err() != nil {
+			continue
+		}
+		err := a.notifier.Notify(a.ctx, event)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Notify queues event without waiting for it to be notified, returns error if the queue is full or closed
+func (a *Async) Notify(ctx context.Context, event Event) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return fmt.Errorf("Could not notify Pod %s/%s: shutting down", event.Namespace, event.Pod)
+	}
+	select {
+	case a.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("Could not notify Pod %s/%s: %d notifications are already queued", event.Namespace, event.Pod, cap(a.queue))
+	}
+}
+
+// Close stops accepting Events and waits for the queued Events to be notified
+// once ctx is done the notification in progress is cancelled, the remaining Events are not notified
+func (a *Async) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		a.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+	pending := len(a.queue)
+	a.cancel()
+	<-a.done
+	return fmt.Errorf("Stopped waiting for notifications: %w, %d queued notifications were cancelled", ctx.Err(), pending)
+}
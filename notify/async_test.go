@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsync(t *testing.T) {
+	var mu sync.Mutex
+	var notified []string
+	release := make(chan struct{})
+	blocking := notifierFunc(func(ctx context.Context, event Event) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, event.Pod)
+		return nil
+	})
+	async := NewAsync(blocking, 2)
+
+	// Notify does not wait for the notifier, Events that do not fit the queue are dropped
+	start := time.Now()
+	require.NoError(t, async.Notify(context.TODO(), Event{Pod: "pod_1"}))
+	require.Eventually(t, func() bool { return len(async.queue) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, async.Notify(context.TODO(), Event{Pod: "pod_2"}))
+	require.NoError(t, async.Notify(context.TODO(), Event{Pod: "pod_3"}))
+	assert.Error(t, async.Notify(context.TODO(), Event{Pod: "pod_4"}))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// queued Events are notified before Close returns
+	close(release)
+	require.NoError(t, async.Close(context.TODO()))
+	assert.Equal(t, []string{"pod_1", "pod_2", "pod_3"}, notified)
+	assert.Error(t, async.Notify(context.TODO(), Event{Pod: "pod_5"}))
+}
+
+func TestAsyncCloseTimeout(t *testing.T) {
+	hanging := notifierFunc(func(ctx context.Context, event Event) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	async := NewAsync(hanging, 10)
+	require.NoError(t, async.Notify(context.TODO(), Event{Pod: "pod_1"}))
+	require.NoError(t, async.Notify(context.TODO(), Event{Pod: "pod_2"}))
+
+	// a hanging notifier is cancelled once Close stops waiting for it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, async.Close(ctx))
+}
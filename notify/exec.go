@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Exec runs a command for every Event, with the Event fields passed as environment variables
+// the command is not run by a shell, so it works in images without one (eg: distroless)
+type Exec struct {
+	path    string
+	args    []string
+	timeout time.Duration
+}
+
+// NewExec returns an Exec that runs command, split on spaces into a program and its arguments
+// commands are killed after timeout
+func NewExec(command string, timeout time.Duration) (*Exec, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("Command is empty")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("Timeout must be positive, got %v", timeout)
+	}
+	return &Exec{path: fields[0], args: fields[1:], timeout: timeout}, nil
+}
+
+// Notify runs the command and logs its output, returns error if the command fails or does not exit before the timeout
+// the command output is logged line by line, prefixed with the Pod it ran for
+func (x *Exec) Notify(ctx context.Context, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, x.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, x.path, x.args...)
+	cmd.Env = append(os.Environ(),
+		"POD_RESTARTER_POD="+event.Pod,
+		"POD_RESTARTER_NAMESPACE="+event.Namespace,
+		"POD_RESTARTER_OWNER="+event.Owner,
+		"POD_RESTARTER_REASON="+event.Reason,
+		"POD_RESTARTER_INSTANCE="+event.Instance,
+		"POD_RESTARTER_TIMESTAMP="+event.Timestamp.Format(time.RFC3339),
+	)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	// the command is killed on timeout, but processes it started can keep its output open
+	// so waiting for it to exit is bounded by the timeout too
+	done := make(chan error, 1)
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("Could not run %s for Pod %s/%s: %w", x.path, event.Namespace, event.Pod, err)
+	}
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("Could not run %s for Pod %s/%s: timed out after %v", x.path, event.Namespace, event.Pod, x.timeout)
+	}
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		log.Printf("%s %s/%s: %s", x.path, event.Namespace, event.Pod, scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("Could not run %s for Pod %s/%s: timed out after %v", x.path, event.Namespace, event.Pod, x.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not run %s for Pod %s/%s: %w", x.path, event.Namespace, event.Pod, err)
+	}
+	return nil
+}
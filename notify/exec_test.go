@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	writeScript := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+		return path
+	}
+	envFile := filepath.Join(dir, "env")
+
+	tests := map[string]struct {
+		command     string
+		expectError bool
+	}{
+		"Verify command gets the Event as environment variables": {
+			command: writeScript("env.sh", `echo "$POD_RESTARTER_NAMESPACE/$POD_RESTARTER_POD $POD_RESTARTER_OWNER $POD_RESTARTER_REASON" > "$1"`) + " " + envFile,
+		},
+		"Verify error is returned when command fails": {
+			command:     writeScript("fail.sh", "echo failed >&2; exit 1"),
+			expectError: true,
+		},
+		"Verify error is returned when command times out": {
+			command:     writeScript("sleep.sh", "sleep 5"),
+			expectError: true,
+		},
+		"Verify error is returned when command does not exist": {
+			command:     filepath.Join(dir, "missing.sh"),
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hook, err := NewExec(tc.command, 500*time.Millisecond)
+			require.NoError(t, err)
+			start := time.Now()
+			err = hook.Notify(context.TODO(), Event{Namespace: "default", Pod: "foo", Owner: "Deployment/default/web", Reason: "FailedCreatePodSandBox"})
+			assert.Less(t, time.Since(start), 2*time.Second)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			env, err := os.ReadFile(envFile)
+			require.NoError(t, err)
+			assert.Equal(t, "default/foo Deployment/default/web FailedCreatePodSandBox\n", string(env))
+		})
+	}
+
+	_, err := NewExec(" ", time.Second)
+	assert.Error(t, err)
+	// a command that is killed right away would always fail
+	_, err = NewExec("true", 0)
+	assert.Error(t, err)
+}
+
+func TestMulti(t *testing.T) {
+	var notified []string
+	ok := notifierFunc(func(ctx context.Context, event Event) error {
+		notified = append(notified, event.Pod)
+		return nil
+	})
+	failing := notifierFunc(func(ctx context.Context, event Event) error {
+		return assert.AnError
+	})
+
+	// a failing notifier does not stop the others
+	err := Multi(failing, ok).Notify(context.TODO(), Event{Pod: "foo"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"foo"}, notified)
+}
+
+// notifierFunc is a Notifier implemented by a function
+type notifierFunc func(ctx context.Context, event Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
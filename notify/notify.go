@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event holds a remediation action performed by pod-restarter
+type Event struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Owner     string    `json:"owner,omitempty"`
+	Reason    string    `json:"reason"`
+	Instance  string    `json:"instance"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier publishes remediation actions to a sink, eg: a webhook or a message bus
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// withFields sets Reason and Instance on Events that do not have them
+type withFields struct {
+	notifier Notifier
+	reason   string
+	instance string
+}
+
+// WithFields returns a Notifier that sets reason and instance on every Event before passing it to notifier
+func WithFields(notifier Notifier, reason, instance string) Notifier {
+	return &withFields{notifier: notifier, reason: reason, instance: instance}
+}
+
+func (w *withFields) Notify(ctx context.Context, event Event) error {
+	if event.Reason == "" {
+		event.Reason = w.reason
+	}
+	if event.Instance == "" {
+		event.Instance = w.instance
+	}
+	return w.notifier.Notify(ctx, event)
+}
+
+// multi passes Events to several Notifiers
+type multi []Notifier
+
+// Multi returns a Notifier that passes every Event to all notifiers, a failing notifier does not stop the others
+func Multi(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+func (m multi) Notify(ctx context.Context, event Event) error {
+	var errs []string
+	for _, notifier := range m {
+		err := notifier.Notify(ctx, event)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Webhook posts Events as JSON to a URL
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns a Webhook that posts Events to url, requests time out after timeout
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify posts event to the webhook, returns error if the webhook does not respond with a 2xx status code
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Could not notify webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Could not notify webhook: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
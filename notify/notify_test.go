@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	tests := map[string]struct {
+		statusCode  int
+		expectError bool
+	}{
+		"Verify Event is posted to webhook": {
+			statusCode: http.StatusOK,
+		},
+		"Verify error is returned when webhook fails": {
+			statusCode:  http.StatusInternalServerError,
+			expectError: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var received Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			timestamp := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+			notifier := WithFields(NewWebhook(server.URL, time.Second), "FailedCreatePodSandBox", "pod-restarter-0")
+			err := notifier.Notify(context.TODO(), Event{Namespace: "default", Pod: "foo", Timestamp: timestamp})
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, Event{
+				Namespace: "default",
+				Pod:       "foo",
+				Reason:    "FailedCreatePodSandBox",
+				Instance:  "pod-restarter-0",
+				Timestamp: timestamp,
+			}, received)
+		})
+	}
+}